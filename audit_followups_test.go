@@ -156,17 +156,17 @@ func TestChatController_LongHandlerKeepsLock(t *testing.T) {
 	defer cancel()
 
 	c := newChatController(ctx)
-	if !c.tryAcquire(7) {
+	if !c.tryAcquire(chatKey(7)) {
 		t.Fatal("first acquire must succeed")
 	}
 
 	// Several sweep ticks elapse, but the lock TTL is still ahead.
 	time.Sleep(50 * time.Millisecond)
 
-	if c.tryAcquire(7) {
+	if c.tryAcquire(chatKey(7)) {
 		t.Fatal("lock was evicted while it should have stayed alive")
 	}
-	c.release(7)
+	c.release(chatKey(7))
 }
 
 // --- B5: RegisterText / RegisterButton no longer clobber each other -------
@@ -14,6 +14,9 @@ import (
 // without requiring a real Telegram connection.
 type telegramAPI interface {
 	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	// Request is for API methods that do not return a Message, such as
+	// answerCallbackQuery.
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
 	GetUpdatesChan(cfg tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
 	GetFileDirectURL(fileID string) (string, error)
 	StopReceivingUpdates()
@@ -22,31 +25,58 @@ type telegramAPI interface {
 
 // realTelegramAPI adapts *tgbotapi.BotAPI to the telegramAPI interface.
 // It exists because BotAPI exposes Self as a struct field, not a method, and
-// because BotAPI.StopReceivingUpdates panics if GetUpdatesChan has not been
-// called yet — we need to guard against that for safe defer-Stop semantics.
+// because the vendored tgbotapi types predate forum topics: updates are
+// polled here so message_thread_id and the forum topic service messages can
+// be decoded alongside each Update (see topics.go).
 type realTelegramAPI struct {
 	bot      *tgbotapi.BotAPI
+	logger   Logger
 	updating atomic.Bool
+	stop     chan struct{}
+	// topics holds the decoded topic fields by update ID until mainLoop
+	// reads the update.
+	topics sync.Map
 }
 
 func (r *realTelegramAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	if msg, ok := c.(threadMessage); ok {
+		return r.sendThreadMessage(msg)
+	}
+
 	return r.bot.Send(c)
 }
 
+func (r *realTelegramAPI) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	if action, ok := c.(threadChatAction); ok {
+		return r.requestThreadChatAction(action)
+	}
+
+	return r.bot.Request(c)
+}
+
+// GetUpdatesChan long-polls getUpdates until StopReceivingUpdates, like
+// BotAPI.GetUpdatesChan, which cannot be used: it decodes straight into
+// tgbotapi.Update and so drops the topic fields.
 func (r *realTelegramAPI) GetUpdatesChan(cfg tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
+	ch := make(chan tgbotapi.Update, r.bot.Buffer)
+	stop := make(chan struct{})
+	r.stop = stop
 	r.updating.Store(true)
-	return r.bot.GetUpdatesChan(cfg)
+
+	go r.pollUpdates(cfg, ch, stop)
+
+	return ch
 }
 
 func (r *realTelegramAPI) GetFileDirectURL(fileID string) (string, error) {
 	return r.bot.GetFileDirectURL(fileID)
 }
 
-// StopReceivingUpdates is a no-op if GetUpdatesChan was never invoked, since
-// the underlying BotAPI panics on a closed-channel double-close in that case.
+// StopReceivingUpdates ends the polling started by GetUpdatesChan; it is a
+// no-op if GetUpdatesChan was never invoked, and safe to call twice.
 func (r *realTelegramAPI) StopReceivingUpdates() {
 	if r.updating.CompareAndSwap(true, false) {
-		r.bot.StopReceivingUpdates()
+		close(r.stop)
 	}
 }
 
@@ -61,9 +91,10 @@ var _ ChatBot = &ChatBotImpl{}
 type ChatBotImpl struct {
 	tgbot telegramAPI
 
-	// chatHandlerLayers maps a chat id to a per-chat layer of one-time handlers
-	// installed by the previous SendMsg. Cleared on the next received message.
-	chatHandlerLayers map[int64]*HandlerLayer
+	// chatHandlerLayers maps a chat (or a user in a chat, see LayerScope) to
+	// the layer of one-time handlers installed by the previous SendMsg.
+	// Cleared on the next received message.
+	chatHandlerLayers map[scopeKey]*HandlerLayer
 	// defaultHandlerLayer is the always-present fallback layer used when no
 	// chat-specific layer matches. Never wiped automatically.
 	defaultHandlerLayer *HandlerLayer
@@ -75,6 +106,20 @@ type ChatBotImpl struct {
 	// across goroutines after SendMsg, so they need no separate lock.
 	defaultLayerMutex sync.RWMutex
 
+	// messageLayers remembers the layer behind each sent inline keyboard, so
+	// buttons on older messages keep working; see rememberMessageLayer.
+	// messageOrder lists each chat's remembered messages, oldest first.
+	messageLayers      map[messageKey]messageLayer
+	messageOrder       map[int64][]int
+	messageLayersMutex sync.Mutex
+	messageLayerTTL    time.Duration
+
+	// navStacks holds the screen history of PushLayer/PopLayer per chat, or
+	// per member in groups (see lockKey).
+	navStacks      map[scopeKey]navStack
+	navMutex       sync.Mutex
+	backButtonText string
+
 	middlewaresMutex sync.RWMutex
 	middlewares      []MiddlewareFunc
 
@@ -83,6 +128,48 @@ type ChatBotImpl struct {
 
 	logger Logger
 
+	// sessionStore keeps per-chat state for Form and friends. Defaults to an
+	// in-memory store; replace via WithSessionStore.
+	sessionStore SessionStore
+
+	// keyboardOptions are the default ReplyKeyboardOptions; replyKeyboards
+	// records which chats, or which members' layers (see LayerScope), show a
+	// reply keyboard, for the automatic removal in sendLayer, until the
+	// cleaner expires them.
+	keyboardOptions     ReplyKeyboardOptions
+	replyKeyboards      map[scopeKey]shownReplyKeyboard
+	replyKeyboardsMutex sync.Mutex
+
+	// Stale inline buttons (no handler anywhere) are answered with
+	// staleButtonText instead of reaching the default handler.
+	staleButtonText     string
+	removeStaleKeyboard bool
+	staleButtonHook     StaleButtonHookFunc
+
+	// topicLayers holds RegisterTopic layers.
+	topicLayers      map[scopeKey]*HandlerLayer
+	topicLayersMutex sync.RWMutex
+
+	// callbackSecret, when set, HMAC-signs inline callback data; see
+	// WithCallbackSecret.
+	callbackSecret []byte
+
+	// templates are the WithTemplates message templates, nil without them.
+	templates *templateSet
+
+	// menus are the WithMenus screens and RegisterMenuHandler handlers.
+	menus *menuSet
+
+	// catalog translates the bot, nil without WithCatalog.
+	catalog *Catalog
+
+	// helpCommand registers HelpHandler as /help; see WithHelpCommand.
+	helpCommand bool
+
+	// plainTextFallback sends badly formatted texts without formatting; see
+	// WithPlainTextFallback.
+	plainTextFallback bool
+
 	debug             bool
 	parseMode         string
 	defaultTTL        time.Duration
@@ -104,11 +191,20 @@ func (b *ChatBotImpl) getErrorHandler() ErrorHandlerFunc {
 // is responsible for attaching a telegramAPI and starting background goroutines.
 func newSkeleton(opts []BotOption) *ChatBotImpl {
 	chatBot := &ChatBotImpl{
-		chatHandlerLayers:   make(map[int64]*HandlerLayer),
+		chatHandlerLayers:   make(map[scopeKey]*HandlerLayer),
 		defaultHandlerLayer: nil,
+		messageLayers:       make(map[messageKey]messageLayer),
+		messageOrder:        make(map[int64][]int),
+		messageLayerTTL:     defaultMessageLayerTTL,
+		navStacks:           make(map[scopeKey]navStack),
+		backButtonText:      defaultBackButtonText,
+		staleButtonText:     defaultStaleButtonText,
+		replyKeyboards:      make(map[scopeKey]shownReplyKeyboard),
+		topicLayers:         make(map[scopeKey]*HandlerLayer),
 		middlewares:         make([]MiddlewareFunc, 0),
 		errorHandler:        nil,
 		logger:              noopLogger{},
+		sessionStore:        NewMemorySessionStore(),
 		debug:               false,
 		parseMode:           tgbotapi.ModeHTML,
 		defaultTTL:          24 * time.Hour,
@@ -148,7 +244,7 @@ func NewBot(apikey string, opts ...BotOption) (*ChatBotImpl, error) {
 	}
 
 	chatBot := newSkeleton(opts)
-	chatBot.tgbot = &realTelegramAPI{bot: bot}
+	chatBot.tgbot = &realTelegramAPI{bot: bot, logger: chatBot.logger}
 	chatBot.finalise()
 	return chatBot, nil
 }
@@ -165,7 +261,7 @@ func NewBotWithEndpoint(apikey, endpoint string, opts ...BotOption) (*ChatBotImp
 	}
 
 	chatBot := newSkeleton(opts)
-	chatBot.tgbot = &realTelegramAPI{bot: bot}
+	chatBot.tgbot = &realTelegramAPI{bot: bot, logger: chatBot.logger}
 	chatBot.finalise()
 	return chatBot, nil
 }
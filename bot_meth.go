@@ -22,9 +22,16 @@ const maxLoaderTicks = 20
 func (b *ChatBotImpl) Start(ctx context.Context) error {
 	b.logger.Debugf("starting bot")
 
+	if err := b.loadTemplates(); err != nil {
+		return fmt.Errorf("failed to load templates: %w", err)
+	}
+	if err := b.LoadMenus(); err != nil {
+		return fmt.Errorf("failed to load menus: %w", err)
+	}
 	if err := b.validateConfiguration(); err != nil {
 		return fmt.Errorf("failed to validate configuration: %w", err)
 	}
+	b.registerHelp()
 	if b.tgbot == nil {
 		return errors.New("telegram client is nil; check the error returned by NewBot")
 	}
@@ -133,10 +140,23 @@ func (b *ChatBotImpl) loaderButtonLoop(ctx context.Context, chatID int64, sentMs
 	}
 }
 
-// RegisterIButton attaches an inline-button handler to the default layer.
-func (b *ChatBotImpl) RegisterIButton(btn string, handler HandlerFunc) {
+// RegisterIButton attaches an inline-button handler to the default layer;
+// opts describe it for Routes.
+func (b *ChatBotImpl) RegisterIButton(btn string, handler HandlerFunc, opts ...RouteOption) {
 	b.defaultLayerMutex.Lock()
-	b.defaultHandlerLayer.RegisterIButton(btn, handler)
+	id := b.defaultHandlerLayer.registerIButton(btn, handler)
+	h := b.defaultHandlerLayer.buttonHandler[id]
+	h.meta = newRouteMeta(opts)
+	b.defaultHandlerLayer.buttonHandler[id] = h
+	b.defaultLayerMutex.Unlock()
+}
+
+// RegisterIButtonRoute attaches a payload-route handler to the default layer.
+// It serves RegisterIButtonData buttons of that route on any message that no
+// chat layer claims, so buttons stay live on messages sent long ago.
+func (b *ChatBotImpl) RegisterIButtonRoute(route string, handler HandlerFunc) {
+	b.defaultLayerMutex.Lock()
+	b.defaultHandlerLayer.RegisterIButtonRoute(route, handler)
 	b.defaultLayerMutex.Unlock()
 }
 
@@ -145,10 +165,14 @@ func (b *ChatBotImpl) SelfUserName() string {
 	return b.tgbot.Self().UserName
 }
 
-// RegisterButton attaches a reply-keyboard button handler to the default layer.
-func (b *ChatBotImpl) RegisterButton(btn string, handler HandlerFunc) {
+// RegisterButton attaches a reply-keyboard button handler to the default
+// layer; opts describe it for Routes and the help.
+func (b *ChatBotImpl) RegisterButton(btn string, handler HandlerFunc, opts ...RouteOption) {
 	b.defaultLayerMutex.Lock()
 	b.defaultHandlerLayer.RegisterButton(btn, handler)
+	h := b.defaultHandlerLayer.buttonTextHandler[btn]
+	h.meta = newRouteMeta(opts)
+	b.defaultHandlerLayer.buttonTextHandler[btn] = h
 	b.defaultLayerMutex.Unlock()
 }
 
@@ -161,16 +185,69 @@ func (b *ChatBotImpl) RegisterAudio(handler HandlerFunc) {
 }
 
 // SendText sends a plain text message without affecting any chat layer.
-// The configured parse mode (WithParseMode) is applied just like in SendMsg.
+// The configured parse mode (WithParseMode) is applied just like in SendMsg,
+// and text over MaxTextLength is split into several messages (see SplitText).
+// In forum supergroups it posts to the general topic; see ReplyText.
 func (b *ChatBotImpl) SendText(chatID int64, text string) error {
-	msg := tgbotapi.NewMessage(chatID, text)
-	msg.ParseMode = b.parseMode
-	if _, err := b.tgbot.Send(msg); err != nil {
+	return b.sendText(chatID, 0, text)
+}
+
+// ReplyText is SendText to the chat and forum topic event came from.
+func (b *ChatBotImpl) ReplyText(event Event, text string) error {
+	return b.sendText(event.ChatID, event.ThreadID, text)
+}
+
+func (b *ChatBotImpl) sendText(chatID int64, thread int, text string) error {
+	if _, err := b.sendChunks(chatID, thread, text, nil, nil); err != nil {
 		return fmt.Errorf("failed to send text: %w", err)
 	}
 	return nil
 }
 
+// sendChunks validates text (see formatText) and sends it split by SplitText,
+// in order, stopping at the first failure. entities (ModeEntities) follow
+// the text into the chunks. Only the last message carries markup; it is
+// returned.
+func (b *ChatBotImpl) sendChunks(
+	chatID int64, thread int, text string, entities []tgbotapi.MessageEntity, markup any,
+) (tgbotapi.Message, error) {
+	text, parseMode, err := b.formatText(text)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+	chunks := SplitText(text, MaxTextLength, parseMode)
+	var chunkEnts [][]tgbotapi.MessageEntity
+	if len(entities) > 0 {
+		chunkEnts = chunkEntities(text, chunks, entities)
+	}
+
+	var sent tgbotapi.Message
+	for i, chunk := range chunks {
+		msg := tgbotapi.NewMessage(chatID, chunk)
+		msg.ParseMode = parseMode
+		if chunkEnts != nil {
+			msg.Entities = chunkEnts[i]
+		}
+		if i == len(chunks)-1 {
+			msg.ReplyMarkup = markup
+		}
+
+		sent, err = b.tgbot.Send(inThread(msg, thread))
+		if err != nil && b.retryPlain(err, chunk, parseMode) {
+			msg.Text, msg.ParseMode = StripFormatting(chunk, parseMode), ""
+			sent, err = b.tgbot.Send(inThread(msg, thread))
+		}
+		if err != nil {
+			if len(chunks) > 1 {
+				return tgbotapi.Message{}, fmt.Errorf("part %d of %d: %w", i+1, len(chunks), err)
+			}
+			return tgbotapi.Message{}, err
+		}
+	}
+
+	return sent, nil
+}
+
 // NewLayer constructs a fresh layer carrying optional message text.
 // The layer is not yet bound to any chat — pass it to SendMsg to install it.
 // msgText is joined with a single space; an empty msgText yields an empty text.
@@ -184,10 +261,12 @@ func (b *ChatBotImpl) NewLayer(msgText ...any) *HandlerLayer {
 
 	return &HandlerLayer{
 		text:                text,
+		parseMode:           b.parseMode,
 		commandHandler:      make(map[string]CommandHandler),
 		textHandler:         make(map[string]TextHandler),
 		buttonTextHandler:   make(map[string]TextHandler),
 		buttonHandler:       make(map[string]InlineButtonHandler),
+		routeHandler:        make(map[string]HandlerFunc),
 		audioHandler:        nil,
 		layerDefaultHandler: nil,
 		ttl:                 time.Now().Add(b.defaultTTL),
@@ -197,52 +276,234 @@ func (b *ChatBotImpl) NewLayer(msgText ...any) *HandlerLayer {
 
 // SendMsg renders the layer (text + buttons), sends it to the chat and
 // installs the layer as the next-message expectation for chatID.
-// Returns an error if layer is nil.
+// Text over MaxTextLength goes out as several messages with the keyboard on
+// the last; the layer is installed once all of them are delivered.
+// Returns an error if layer is nil. In forum supergroups the layer goes to
+// the general topic unless it is scoped to a topic (ScopeThread); see Reply.
 func (b *ChatBotImpl) SendMsg(chatID int64, layer *HandlerLayer) error {
+	return b.sendMsg(recipient{chatID: chatID}, layer)
+}
+
+// Reply is SendMsg to the chat and forum topic event came from: the layer is
+// posted to that topic, matches the topic's next event and shows WithCatalog
+// labels in the sender's locale.
+func (b *ChatBotImpl) Reply(event Event, layer *HandlerLayer) error {
+	return b.sendMsg(eventRecipient(event), layer)
+}
+
+// recipient is where a layer is shown: a chat, its forum topic (0 outside
+// forums) and the locale of its labels ("" for the catalog's fallback).
+type recipient struct {
+	chatID int64
+	thread int
+	locale string
+}
+
+func eventRecipient(event Event) recipient {
+	return recipient{chatID: event.ChatID, thread: event.ThreadID, locale: event.locale}
+}
+
+// contextRecipient is chatID in the topic and locale of the event ctx was
+// handed with.
+func contextRecipient(ctx context.Context, chatID int64) recipient {
+	return recipient{chatID: chatID, thread: contextThread(ctx), locale: Locale(ctx)}
+}
+
+// sendMsg implements SendMsg and Reply.
+func (b *ChatBotImpl) sendMsg(to recipient, layer *HandlerLayer) error {
+	_, err := b.sendInstalled(to, layer)
+	return err
+}
+
+// sendInstalled sends the layer and installs it, returning the message that
+// carries its keyboard.
+func (b *ChatBotImpl) sendInstalled(to recipient, layer *HandlerLayer) (tgbotapi.Message, error) {
 	if layer == nil {
-		return errors.New("SendMsg: layer is nil")
+		return tgbotapi.Message{}, errors.New("SendMsg: layer is nil")
 	}
 
-	message := tgbotapi.NewMessage(chatID, layer.text)
-	sortedIButtonsSlice := layer.sortedIButtonsSlice()
-	rawIButtons := make([]tgbotapi.InlineKeyboardButton, 0, len(sortedIButtonsSlice))
+	sent, err := b.sendLayer(to, layer)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
 
-	sortedButtonsSlice := layer.sortedButtonsSlice()
-	rawButtons := make([]tgbotapi.KeyboardButton, 0, len(sortedButtonsSlice))
+	b.rememberMessageLayer(to.chatID, sent.MessageID, layer)
+	if layer.forceReply == nil {
+		b.setLayer(layer, to.chatID, to.thread)
+	}
 
-	for _, button := range sortedIButtonsSlice {
-		rawIButtons = append(rawIButtons, button.button)
+	return sent, nil
+}
+
+// EditMsg replaces the text and inline keyboard of an earlier bot message
+// with the layer and installs the layer for chatID, like SendMsg. Use it to
+// update a menu in place instead of sending a new message; messageID usually
+// comes from Event.MessageID of an inline-button event.
+//
+// Telegram cannot edit a message into one with a reply keyboard, so a layer
+// with RegisterButton buttons is rejected.
+func (b *ChatBotImpl) EditMsg(chatID int64, messageID int, layer *HandlerLayer) error {
+	return b.editMsg(recipient{chatID: chatID}, messageID, layer)
+}
+
+// editMsg implements EditMsg for a message shown to a recipient.
+func (b *ChatBotImpl) editMsg(to recipient, messageID int, layer *HandlerLayer) error {
+	if layer == nil {
+		return errors.New("EditMsg: layer is nil")
 	}
 
-	for _, button := range sortedButtonsSlice {
-		rawButtons = append(rawButtons, tgbotapi.NewKeyboardButton(button.text))
+	if err := b.editLayer(to, messageID, layer); err != nil {
+		return err
 	}
 
-	isInline := len(rawIButtons) > 0
-	if isInline {
-		message.ReplyMarkup = b.buildInlineKeyboard(rawIButtons, layer.rowMode)
+	b.setLayer(layer, to.chatID, to.thread)
+	b.rememberMessageLayer(to.chatID, messageID, layer)
+
+	return nil
+}
+
+// sendLayer renders and sends the layer without installing it, returning
+// the message that carries the keyboard.
+func (b *ChatBotImpl) sendLayer(to recipient, layer *HandlerLayer) (tgbotapi.Message, error) {
+	chatID := to.chatID
+	markup, err := b.layerMarkup(to, layer)
+	if err != nil {
+		return tgbotapi.Message{}, err
 	}
 
-	isRegular := len(rawButtons) > 0
-	if isRegular {
-		message.ReplyMarkup = tgbotapi.NewReplyKeyboard(rawButtons)
+	// Keyboards are tracked per layer scope: a member's layers only ever
+	// remove that member's keyboard, never the one shown to the whole chat.
+	keyboard := scopeKey{chatID: chatID, userID: layer.scope.userID}
+	if markup == nil {
+		if removal, ok := b.replyKeyboardRemoval(keyboard, layer); ok {
+			markup = removal
+		}
+	}
+
+	sent, err := b.sendChunks(chatID, layerThread(layer, to.thread), layer.text, layer.entities, markup)
+	if err != nil {
+		return tgbotapi.Message{}, fmt.Errorf("failed to send message: %w", err)
 	}
+	b.trackReplyKeyboard(keyboard, markup)
+
+	return sent, nil
+}
 
-	if isInline && isRegular {
-		return errors.New("can't send both inline and regular buttons")
+// editLayer renders the layer onto an existing message without installing it.
+func (b *ChatBotImpl) editLayer(to recipient, messageID int, layer *HandlerLayer) error {
+	markup, err := b.layerMarkup(to, layer)
+	if err != nil {
+		return err
 	}
 
-	message.ParseMode = b.parseMode
+	text, parseMode, err := b.formatText(layer.text)
+	if err != nil {
+		return fmt.Errorf("failed to edit message: %w", err)
+	}
 
-	if _, err := b.tgbot.Send(message); err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+	edit := tgbotapi.NewEditMessageText(to.chatID, messageID, text)
+	switch m := markup.(type) {
+	case nil:
+	case tgbotapi.InlineKeyboardMarkup:
+		edit.ReplyMarkup = &m
+	default:
+		return errors.New("can't edit a message into one with a reply keyboard")
 	}
+	edit.ParseMode = parseMode
+	edit.Entities = layer.entities
 
-	b.setLayer(layer, chatID)
+	_, err = b.tgbot.Send(edit)
+	if err != nil && b.retryPlain(err, text, parseMode) {
+		edit.Text, edit.ParseMode = StripFormatting(text, parseMode), ""
+		_, err = b.tgbot.Send(edit)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to edit message: %w", err)
+	}
+
+	return nil
+}
+
+// editMarkup re-renders only the inline keyboard of an earlier message, e.g.
+// after a widget relabelled one of the layer's buttons.
+func (b *ChatBotImpl) editMarkup(to recipient, messageID int, layer *HandlerLayer) error {
+	markup, err := b.layerMarkup(to, layer)
+	if err != nil {
+		return err
+	}
+
+	inline, ok := markup.(tgbotapi.InlineKeyboardMarkup)
+	if !ok {
+		inline = tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	}
+
+	if _, err := b.tgbot.Send(tgbotapi.NewEditMessageReplyMarkup(to.chatID, messageID, inline)); err != nil {
+		return fmt.Errorf("failed to edit message markup: %w", err)
+	}
 
 	return nil
 }
 
+// layerMarkup renders the layer's buttons for the recipient, with labels in
+// its locale: nil when there are none, an InlineKeyboardMarkup or a
+// ReplyKeyboardMarkup.
+func (b *ChatBotImpl) layerMarkup(to recipient, layer *HandlerLayer) (any, error) {
+	label := b.labelTranslator(to.locale)
+
+	sortedIButtonsSlice := layer.sortedIButtonsSlice()
+	rawIButtons := make([]tgbotapi.InlineKeyboardButton, 0, len(sortedIButtonsSlice))
+
+	sortedButtonsSlice := layer.sortedButtonsSlice()
+	rawButtons := make([]tgbotapi.KeyboardButton, 0, len(sortedButtonsSlice))
+
+	for _, button := range sortedIButtonsSlice {
+		signed, err := b.signButton(to.chatID, layer.scope, button.button)
+		if err != nil {
+			return nil, err
+		}
+		signed.Text = label(signed.Text)
+		rawIButtons = append(rawIButtons, signed)
+	}
+
+	for _, button := range sortedButtonsSlice {
+		raw := button.keyboardButton()
+		raw.Text = label(raw.Text)
+		rawButtons = append(rawButtons, raw)
+	}
+
+	isInline := len(rawIButtons) > 0
+	isRegular := len(rawButtons) > 0
+
+	switch {
+	case isInline && isRegular:
+		return nil, errors.New("can't send both inline and regular buttons")
+	case layer.removeKeyboard && (isInline || isRegular):
+		return nil, errors.New("can't remove the reply keyboard in a message with buttons")
+	case layer.forceReply != nil && (isInline || isRegular):
+		return nil, errors.New("can't force a reply in a message with buttons")
+	case layer.forceReply != nil:
+		return *layer.forceReply, nil
+	case isInline && layer.inlineLayout.isSet():
+		labels := make([]string, 0, len(rawIButtons))
+		for _, button := range rawIButtons {
+			labels = append(labels, button.Text)
+		}
+		sizes := layer.inlineLayout.rowSizes(labels, false, maxInlineRowButtons)
+		return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: splitRows(rawIButtons, sizes)}, nil
+	case isInline:
+		return b.buildInlineKeyboard(rawIButtons, layer.rowMode), nil
+	case isRegular:
+		labels := make([]string, 0, len(rawButtons))
+		for _, button := range rawButtons {
+			labels = append(labels, button.Text)
+		}
+		sizes := layer.replyLayout.rowSizes(labels, true, 0)
+		return b.replyKeyboardMarkup(layer, splitRows(rawButtons, sizes)), nil
+	}
+
+	return nil, nil
+}
+
 // RetryLastLayer re-sends the layer that was active during the previous
 // message in the chat. If newText is non-empty it overrides the layer's
 // text without mutating the original layer.
@@ -258,17 +519,22 @@ func (b *ChatBotImpl) RetryLastLayer(event Event, newText string) error {
 		// a pointer to the original (e.g. the default layer).
 		layerCopy := *previousLayer
 		layerCopy.text = newText
+		layerCopy.entities = nil
 		previousLayer = &layerCopy
 	}
 
-	return b.SendMsg(event.ChatID, previousLayer)
+	return b.Reply(event, previousLayer)
 }
 
 // RegisterCommand attaches a slash-command handler to the default layer.
-// command must include the leading slash (e.g. "/start").
-func (b *ChatBotImpl) RegisterCommand(command string, handler HandlerFunc) {
+// command must include the leading slash (e.g. "/start"); opts describe it
+// for Routes and the help.
+func (b *ChatBotImpl) RegisterCommand(command string, handler HandlerFunc, opts ...RouteOption) {
 	b.defaultLayerMutex.Lock()
-	b.defaultHandlerLayer.RegisterCommand(command, handler)
+	b.defaultHandlerLayer.commandHandler[command] = CommandHandler{
+		handlerFunc: handler,
+		meta:        newRouteMeta(opts),
+	}
 	b.defaultLayerMutex.Unlock()
 }
 
@@ -298,13 +564,14 @@ func (b *ChatBotImpl) RegisterDefaultHandler(handler HandlerFunc) {
 	b.defaultLayerMutex.Unlock()
 }
 
-// findAndWipeChatLayerHandler returns the chat-specific layer (consuming it)
-// or the default layer when no chat-specific layer is installed.
-func (b *ChatBotImpl) findAndWipeChatLayerHandler(chatID int64) *HandlerLayer {
-	layer, ok := b.getAndDeleteLayer(chatID)
-	if !ok {
-		return b.defaultHandlerLayer
+// findAndWipeLayer returns the layer installed for the event's sender or, failing
+// that, for its chat (consuming it), or the default layer when neither is set.
+func (b *ChatBotImpl) findAndWipeLayer(event Event) *HandlerLayer {
+	for _, key := range eventKeys(event) {
+		if layer, ok := b.getAndDeleteLayer(key); ok {
+			return layer
+		}
 	}
 
-	return layer
+	return b.defaultHandlerLayer
 }
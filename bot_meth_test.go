@@ -68,7 +68,7 @@ func TestSendMsg_InlineButtons(t *testing.T) {
 
 	// Layer should now be installed for chat 7.
 	bot.layersMutex.RLock()
-	_, present := bot.chatHandlerLayers[7]
+	_, present := bot.chatHandlerLayers[chatKey(7)]
 	bot.layersMutex.RUnlock()
 	if !present {
 		t.Fatal("layer not installed after SendMsg")
@@ -112,6 +112,44 @@ func TestSendMsg_PropagatesSendError(t *testing.T) {
 	}
 }
 
+func TestEditMsg_EditsInPlaceAndInstallsLayer(t *testing.T) {
+	bot, mock := newTestBot()
+	l := bot.NewLayer("updated")
+	l.RegisterIButton("OK", func(_ context.Context, _ Event) error { return nil })
+
+	if err := bot.EditMsg(7, 99, l); err != nil {
+		t.Fatal(err)
+	}
+
+	edit, ok := mock.lastSent().(tgbotapi.EditMessageTextConfig)
+	if !ok || edit.MessageID != 99 || edit.Text != "updated" || edit.ReplyMarkup == nil {
+		t.Fatalf("unexpected edit: %+v", mock.lastSent())
+	}
+	if edit.ParseMode != tgbotapi.ModeHTML {
+		t.Fatalf("parse mode not applied: %q", edit.ParseMode)
+	}
+
+	bot.layersMutex.RLock()
+	installed := bot.chatHandlerLayers[chatKey(7)]
+	bot.layersMutex.RUnlock()
+	if installed != l {
+		t.Fatal("layer not installed after EditMsg")
+	}
+}
+
+func TestEditMsg_RejectsReplyKeyboardAndNil(t *testing.T) {
+	bot, _ := newTestBot()
+	l := bot.NewLayer("x")
+	l.RegisterButton("A", func(_ context.Context, _ Event) error { return nil })
+
+	if err := bot.EditMsg(7, 1, l); err == nil {
+		t.Fatal("expected error editing into a reply keyboard")
+	}
+	if err := bot.EditMsg(7, 1, nil); err == nil {
+		t.Fatal("expected error for nil layer")
+	}
+}
+
 func TestRetryLastLayer_NoPrevious(t *testing.T) {
 	bot, _ := newTestBot()
 	if err := bot.RetryLastLayer(Event{ChatID: 1}, ""); err == nil {
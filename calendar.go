@@ -0,0 +1,247 @@
+package bf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	daysInWeek = 7
+	// timeSlotColumns is how many time-slot buttons share a row.
+	timeSlotColumns = 4
+	// calendarNavButtons is the ‹ Month › header row.
+	calendarNavButtons = 3
+)
+
+// CalendarLocale holds the names a DatePicker renders and the first day of
+// the week. Weekdays is indexed by time.Weekday (Sunday first), whatever
+// WeekStart is.
+type CalendarLocale struct {
+	Months    [12]string
+	Weekdays  [7]string
+	WeekStart time.Weekday
+}
+
+// CalendarLocaleEnglish is the default DatePicker locale: English names,
+// weeks starting on Monday.
+var CalendarLocaleEnglish = CalendarLocale{
+	Months: [12]string{
+		"January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December",
+	},
+	Weekdays:  [7]string{"Su", "Mo", "Tu", "We", "Th", "Fr", "Sa"},
+	WeekStart: time.Monday,
+}
+
+// DatePickerDoneFunc receives the picked date (midnight in the clock's
+// location) or, with time slots, the picked date and time.
+type DatePickerDoneFunc func(ctx context.Context, event Event, picked time.Time) error
+
+// DatePicker is an inline-keyboard calendar with month navigation, optional
+// min/max bounds and disabled days, followed by an optional time-slot grid.
+// Navigation edits the message in place.
+//
+// The current date comes from SetClock (time.Now by default), so tests can
+// pin the rendered month.
+type DatePicker struct {
+	bot        *ChatBotImpl
+	prompt     string
+	onDone     DatePickerDoneFunc
+	locale     CalendarLocale
+	minDate    time.Time
+	maxDate    time.Time
+	disabled   func(day time.Time) bool
+	slots      []time.Duration
+	slotPrompt string
+	now        func() time.Time
+}
+
+// NewDatePicker creates a calendar prompting with prompt.
+func (b *ChatBotImpl) NewDatePicker(prompt string, onDone DatePickerDoneFunc) *DatePicker {
+	return &DatePicker{
+		bot:    b,
+		prompt: prompt,
+		onDone: onDone,
+		locale: CalendarLocaleEnglish,
+		now:    time.Now,
+	}
+}
+
+// SetLocale sets month/weekday names and the first day of the week.
+func (d *DatePicker) SetLocale(locale CalendarLocale) {
+	d.locale = locale
+}
+
+// SetBounds limits pickable days to [minDate, maxDate] (compared by calendar
+// day). A zero bound is open.
+func (d *DatePicker) SetBounds(minDate, maxDate time.Time) {
+	d.minDate = minDate
+	d.maxDate = maxDate
+}
+
+// SetDisabled marks individual days (e.g. weekends, fully booked days) as
+// not pickable. day is midnight in the clock's location.
+func (d *DatePicker) SetDisabled(disabled func(day time.Time) bool) {
+	d.disabled = disabled
+}
+
+// SetTimeSlots adds a second step after the day is picked: a grid of times,
+// given as offsets from midnight (e.g. 9*time.Hour + 30*time.Minute).
+// Slots outside the bounds are hidden.
+func (d *DatePicker) SetTimeSlots(prompt string, slots ...time.Duration) {
+	d.slotPrompt = prompt
+	d.slots = slots
+}
+
+// SetClock replaces time.Now as the source of "today".
+func (d *DatePicker) SetClock(now func() time.Time) {
+	d.now = now
+}
+
+// Show renders the month containing today, clamped into the bounds.
+func (d *DatePicker) Show(_ context.Context, event Event) error {
+	if d.onDone == nil {
+		return errors.New("date picker has no done handler")
+	}
+
+	today := d.now()
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+	if !d.minDate.IsZero() && month.Before(firstOfMonth(d.minDate, today.Location())) {
+		month = firstOfMonth(d.minDate, today.Location())
+	}
+	if !d.maxDate.IsZero() && month.After(firstOfMonth(d.maxDate, today.Location())) {
+		month = firstOfMonth(d.maxDate, today.Location())
+	}
+
+	return d.bot.presentLayer(event, d.monthLayer(month))
+}
+
+func firstOfMonth(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+}
+
+// dayAllowed reports whether day (midnight) can be picked.
+func (d *DatePicker) dayAllowed(day time.Time) bool {
+	if !d.minDate.IsZero() && day.Before(dayOf(d.minDate, day.Location())) {
+		return false
+	}
+	if !d.maxDate.IsZero() && day.After(dayOf(d.maxDate, day.Location())) {
+		return false
+	}
+
+	return d.disabled == nil || !d.disabled(day)
+}
+
+func dayOf(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// monthLayer renders one month: a ‹ Month Year › row, the weekday header and
+// one row per week. Inert cells keep the layer alive when tapped.
+func (d *DatePicker) monthLayer(month time.Time) *HandlerLayer {
+	layer := d.bot.NewLayer()
+	layer.AddText(d.prompt)
+	keep := d.bot.keepLayerHandler(layer)
+
+	prev := month.AddDate(0, -1, 0)
+	next := month.AddDate(0, 1, 0)
+	canPrev := d.minDate.IsZero() || !month.AddDate(0, 0, -1).Before(dayOf(d.minDate, month.Location()))
+	canNext := d.maxDate.IsZero() || !next.After(dayOf(d.maxDate, month.Location()))
+
+	d.navButton(layer, canPrev, "‹", prev, keep)
+	layer.RegisterIButton(d.locale.Months[month.Month()-1]+" "+strconv.Itoa(month.Year()), keep)
+	d.navButton(layer, canNext, "›", next, keep)
+
+	for i := range daysInWeek {
+		layer.RegisterIButton(d.locale.Weekdays[(int(d.locale.WeekStart)+i)%daysInWeek], keep)
+	}
+
+	rows := []int{calendarNavButtons, daysInWeek}
+	lead := (int(month.Weekday()) - int(d.locale.WeekStart) + daysInWeek) % daysInWeek
+	for range lead {
+		layer.RegisterIButton(" ", keep)
+	}
+
+	cells := lead
+	for day := month; day.Month() == month.Month(); day = day.AddDate(0, 0, 1) {
+		if d.dayAllowed(day) {
+			layer.RegisterIButton(strconv.Itoa(day.Day()), d.pickDay(day))
+		} else {
+			layer.RegisterIButton("×", keep)
+		}
+		cells++
+	}
+	for ; cells%daysInWeek != 0; cells++ {
+		layer.RegisterIButton(" ", keep)
+	}
+	for range cells / daysInWeek {
+		rows = append(rows, daysInWeek)
+	}
+	layer.SetIButtonRows(rows...)
+
+	return layer
+}
+
+func (d *DatePicker) navButton(layer *HandlerLayer, enabled bool, label string, month time.Time, keep HandlerFunc) {
+	if !enabled {
+		layer.RegisterIButton(" ", keep)
+		return
+	}
+
+	layer.RegisterIButton(label, func(_ context.Context, event Event) error {
+		return d.bot.presentLayer(event, d.monthLayer(month))
+	})
+}
+
+func (d *DatePicker) pickDay(day time.Time) HandlerFunc {
+	return func(ctx context.Context, event Event) error {
+		if len(d.slots) == 0 {
+			return d.done(ctx, event, day)
+		}
+
+		return d.bot.presentLayer(event, d.slotLayer(day))
+	}
+}
+
+// done finishes the picker: its message's buttons go stale, so the date
+// cannot be picked twice, and onDone gets the result.
+func (d *DatePicker) done(ctx context.Context, event Event, at time.Time) error {
+	d.bot.forgetMessageLayer(event.ChatID, event.MessageID)
+	return d.onDone(ctx, event, at)
+}
+
+// slotLayer renders the time-slot grid for day with a ‹ back to the month.
+func (d *DatePicker) slotLayer(day time.Time) *HandlerLayer {
+	layer := d.bot.NewLayer()
+	layer.AddText(d.slotPrompt)
+
+	count := 0
+	for _, slot := range d.slots {
+		at := day.Add(slot)
+		if (!d.minDate.IsZero() && at.Before(d.minDate)) || (!d.maxDate.IsZero() && at.After(d.maxDate)) {
+			continue
+		}
+		label := fmt.Sprintf("%02d:%02d", at.Hour(), at.Minute())
+		layer.RegisterIButton(label, func(ctx context.Context, event Event) error {
+			return d.done(ctx, event, at)
+		})
+		count++
+	}
+
+	rows := make([]int, 0, count/timeSlotColumns+2)
+	for ; count > 0; count -= timeSlotColumns {
+		rows = append(rows, min(count, timeSlotColumns))
+	}
+	layer.SetIButtonRows(append(rows, 1)...)
+
+	layer.RegisterIButton("‹", func(_ context.Context, event Event) error {
+		return d.bot.presentLayer(event, d.monthLayer(firstOfMonth(day, day.Location())))
+	})
+
+	return layer
+}
@@ -0,0 +1,158 @@
+package bf
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fixedClock pins "today" to 10 Feb 2026; February 2026 starts on a Sunday.
+func fixedClock() time.Time {
+	return time.Date(2026, time.February, 10, 15, 4, 0, 0, time.UTC)
+}
+
+// lastKeyboard returns the inline keyboard of the last sent or edited message.
+func lastKeyboard(t *testing.T, mock *mockTelegramAPI) tgbotapi.InlineKeyboardMarkup {
+	t.Helper()
+	if edit, ok := mock.lastSent().(tgbotapi.EditMessageTextConfig); ok {
+		return *edit.ReplyMarkup
+	}
+	return mock.lastMarkup(t)
+}
+
+func TestDatePicker_MonthLayoutMondayStart(t *testing.T) {
+	bot, mock := newTestBot()
+	d := bot.NewDatePicker("Pick a day", func(context.Context, Event, time.Time) error { return nil })
+	d.SetClock(fixedClock)
+
+	if err := d.Show(context.Background(), Event{ChatID: 42}); err != nil {
+		t.Fatal(err)
+	}
+
+	kb := mock.lastMarkup(t).InlineKeyboard
+	if len(kb) != 7 {
+		t.Fatalf("want nav + header + 5 weeks, got %d rows", len(kb))
+	}
+	if got := strings.Join(rowTexts(kb[0]), "|"); got != "‹|February 2026|›" {
+		t.Fatalf("nav row: %q", got)
+	}
+	if got := strings.Join(rowTexts(kb[1]), " "); got != "Mo Tu We Th Fr Sa Su" {
+		t.Fatalf("header: %q", got)
+	}
+	if got := strings.Join(rowTexts(kb[2]), "|"); got != " | | | | | |1" {
+		t.Fatalf("first week: %q", got)
+	}
+	if got := strings.Join(rowTexts(kb[6]), "|"); got != "23|24|25|26|27|28| " {
+		t.Fatalf("last week: %q", got)
+	}
+}
+
+func TestDatePicker_LocaleWeekStart(t *testing.T) {
+	bot, mock := newTestBot()
+	d := bot.NewDatePicker("Pick", func(context.Context, Event, time.Time) error { return nil })
+	d.SetClock(fixedClock)
+	locale := CalendarLocaleEnglish
+	locale.WeekStart = time.Sunday
+	d.SetLocale(locale)
+
+	if err := d.Show(context.Background(), Event{ChatID: 42}); err != nil {
+		t.Fatal(err)
+	}
+
+	kb := mock.lastMarkup(t).InlineKeyboard
+	if got := strings.Join(rowTexts(kb[1]), " "); got != "Su Mo Tu We Th Fr Sa" {
+		t.Fatalf("header: %q", got)
+	}
+	if kb[2][0].Text != "1" || len(kb) != 6 {
+		t.Fatalf("Sunday-start February 2026 should be 4 full weeks, got %d rows starting %q", len(kb)-2, kb[2][0].Text)
+	}
+}
+
+func TestDatePicker_BoundsAndDisabledDays(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+	d := bot.NewDatePicker("Pick", func(context.Context, Event, time.Time) error { return nil })
+	d.SetClock(fixedClock)
+	d.SetBounds(fixedClock(), time.Date(2026, time.March, 5, 0, 0, 0, 0, time.UTC))
+	d.SetDisabled(func(day time.Time) bool { return day.Weekday() == time.Saturday })
+
+	if err := d.Show(context.Background(), Event{ChatID: 42}); err != nil {
+		t.Fatal(err)
+	}
+
+	kb := mock.lastMarkup(t).InlineKeyboard
+	if kb[0][0].Text != " " {
+		t.Fatalf("prev must be disabled at the min month, got %q", kb[0][0].Text)
+	}
+	// Week of 9 Feb: Mo 9 is before min, Tu 10 is min, Sa 14 is disabled.
+	if got := strings.Join(rowTexts(kb[4]), "|"); got != "×|10|11|12|13|×|15" {
+		t.Fatalf("second week: %q", got)
+	}
+
+	// Tapping a disabled day keeps the calendar alive.
+	bot.handleUpdate(context.Background(), c, withMessageID(tapUpdate(42, mock.lastMarkup(t), "×"), 9))
+	bot.handleUpdate(context.Background(), c, withMessageID(tapUpdate(42, mock.lastMarkup(t), "›"), 9))
+
+	kb = lastKeyboard(t, mock).InlineKeyboard
+	if got := strings.Join(rowTexts(kb[0]), "|"); got != "‹|March 2026| " {
+		t.Fatalf("March nav row: %q", got)
+	}
+	if got := strings.Join(rowTexts(kb[2]), "|"); got != " | | | | | |1" {
+		t.Fatalf("March first week: %q", got)
+	}
+	if got := kb[3][4].Text; got != "×" {
+		t.Fatalf("6 March is past max, got %q", got)
+	}
+}
+
+func TestDatePicker_DayThenTimeSlot(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	var picked time.Time
+	d := bot.NewDatePicker("Pick", func(_ context.Context, _ Event, at time.Time) error {
+		picked = at
+		return nil
+	})
+	d.SetClock(fixedClock)
+	d.SetTimeSlots("When?", 9*time.Hour, 9*time.Hour+30*time.Minute, 10*time.Hour, 11*time.Hour, 14*time.Hour)
+
+	if err := d.Show(context.Background(), Event{ChatID: 42}); err != nil {
+		t.Fatal(err)
+	}
+	bot.handleUpdate(context.Background(), c, withMessageID(tapUpdate(42, mock.lastMarkup(t), "12"), 9))
+
+	edit, ok := mock.lastSent().(tgbotapi.EditMessageTextConfig)
+	if !ok || edit.Text != "When?" {
+		t.Fatalf("want slot grid edited in place, got %T", mock.lastSent())
+	}
+	kb := edit.ReplyMarkup.InlineKeyboard
+	if len(kb) != 3 || len(kb[0]) != 4 || len(kb[1]) != 1 || kb[2][0].Text != "‹" {
+		t.Fatalf("bad slot grid: %v", kb)
+	}
+
+	slot := withMessageID(tapUpdate(42, *edit.ReplyMarkup, "09:30"), 9)
+	bot.handleUpdate(context.Background(), c, slot)
+
+	want := time.Date(2026, time.February, 12, 9, 30, 0, 0, time.UTC)
+	if !picked.Equal(want) {
+		t.Fatalf("picked %v, want %v", picked, want)
+	}
+
+	// The finished picker's buttons are stale.
+	picked = time.Time{}
+	bot.handleUpdate(context.Background(), c, slot)
+	if !picked.IsZero() {
+		t.Fatal("a finished picker picked again")
+	}
+}
+
+func TestDatePicker_RequiresDoneHandler(t *testing.T) {
+	bot, _ := newTestBot()
+	if err := bot.NewDatePicker("Pick", nil).Show(context.Background(), Event{ChatID: 1}); err == nil {
+		t.Fatal("expected error without done handler")
+	}
+}
@@ -14,6 +14,16 @@ const (
 	EventKindInlineButton EventKind = "buttonInline"
 	EventKindCommand      EventKind = "command"
 	EventKindVoice        EventKind = "audio"
+	EventKindContact      EventKind = "contact"
+	EventKindLocation     EventKind = "location"
+	EventKindForumTopic   EventKind = "forumTopic"
+)
+
+// Telegram's length limits, in characters: MaxTextLength for message texts,
+// MaxCaptionLength for media captions. See SplitText.
+const (
+	MaxTextLength    = 4096
+	MaxCaptionLength = 1024
 )
 
 // Loader timing.
@@ -23,8 +33,28 @@ const (
 // racing the goroutines that read it.
 var loaderTickDelay atomic.Int64
 
+// progressEditDelay is the minimum gap between two edits of a Progress
+// message, keeping well under Telegram's edit rate limit. progressActionDelay
+// is how often a chat-action Progress repeats its action; Telegram shows
+// one for about five seconds. streamEditDelay is the same gap for
+// TextStream edits.
+var (
+	progressEditDelay   atomic.Int64
+	progressActionDelay atomic.Int64
+	streamEditDelay     atomic.Int64
+)
+
 func init() {
 	loaderTickDelay.Store(int64(2 * time.Second))
+	progressEditDelay.Store(int64(time.Second))
+	progressActionDelay.Store(int64(4 * time.Second))
+	streamEditDelay.Store(int64(time.Second))
 }
 
 func loaderTick() time.Duration { return time.Duration(loaderTickDelay.Load()) }
+
+func progressEditGap() time.Duration { return time.Duration(progressEditDelay.Load()) }
+
+func progressActionTick() time.Duration { return time.Duration(progressActionDelay.Load()) }
+
+func streamEditGap() time.Duration { return time.Duration(streamEditDelay.Load()) }
@@ -0,0 +1,219 @@
+package bf
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultAskTimeout bounds how long Conversation.Ask waits for an answer when
+// no AskTimeout option is given, so a forgotten conversation releases its
+// chat; the sweeper leaves a waiting conversation's lock alone.
+const defaultAskTimeout = 5 * time.Minute
+
+// ConversationCancelCommand is the command that aborts a pending Ask with
+// ErrConversationCancelled.
+const ConversationCancelCommand = "/cancel"
+
+// Conversation runs a linear dialog with one chat from inside a handler:
+//
+//	conv := bot.Conversation(event)
+//	name, err := conv.Ask(ctx, "Your name?")
+//	if err != nil {
+//	    return err
+//	}
+//	age, err := conv.Ask(ctx, "Your age?", bf.AskValidate(isNumber))
+//
+// Every Ask installs a one-shot layer for the chat (the same mechanism as
+// SendMsg) and blocks until the chat's next event is routed to that layer;
+// in groups, AskScope(ScopeUser(id)) waits for one member only. The handler
+// keeps its chatController lock for the whole dialog, so other updates from
+// the same sender never run concurrently with it.
+//
+// A Conversation is bound to the handler's goroutine; do not share it.
+type Conversation struct {
+	bot      *ChatBotImpl
+	chatID   int64
+	threadID int
+	lock     scopeKey
+}
+
+// Conversation starts a dialog with the chat (and forum topic) the event
+// came from. Pass the ctx received by the handler to every Ask.
+func (b *ChatBotImpl) Conversation(event Event) *Conversation {
+	return &Conversation{bot: b, chatID: event.ChatID, threadID: event.ThreadID, lock: lockKey(event)}
+}
+
+// AskOption customises a single Conversation.Ask call.
+type AskOption func(cfg *askConfig)
+
+type askConfig struct {
+	timeout  time.Duration
+	validate func(answer string) error
+	buttons  []string
+	scope    LayerScope
+}
+
+// AskValidate checks the answer before Ask returns it. A non-nil error is
+// sent back to the user together with the prompt, and Ask keeps waiting.
+func AskValidate(validate func(answer string) error) AskOption {
+	return func(cfg *askConfig) {
+		cfg.validate = validate
+	}
+}
+
+// AskTimeout overrides how long Ask waits for an answer. A non-positive d
+// disables the timeout; Ask then returns only on an answer, /cancel or ctx.
+func AskTimeout(d time.Duration) AskOption {
+	return func(cfg *askConfig) {
+		cfg.timeout = d
+	}
+}
+
+// AskButtons offers quick answers as inline buttons. Tapping one answers
+// with the button label, exactly as if the user had typed it.
+func AskButtons(labels ...string) AskOption {
+	return func(cfg *askConfig) {
+		cfg.buttons = append(cfg.buttons, labels...)
+	}
+}
+
+// AskScope sets whose answer Ask accepts; see LayerScope. In a group,
+// AskScope(ScopeUser(event.UserTGID)) ignores every other member.
+func AskScope(scope LayerScope) AskOption {
+	return func(cfg *askConfig) {
+		cfg.scope = scope
+	}
+}
+
+// Ask sends prompt and waits for a text answer or an AskButtons tap.
+// Non-text events (voice, other commands) re-send the prompt.
+//
+// Returns ErrConversationCancelled on ConversationCancelCommand,
+// ErrConversationTimeout when the timeout elapses, or ctx.Err() when the
+// handler context is cancelled.
+func (c *Conversation) Ask(ctx context.Context, prompt string, opts ...AskOption) (string, error) {
+	event, err := c.ask(ctx, prompt, true, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return answerText(event), nil
+}
+
+// AskEvent behaves like Ask but returns the whole answering event, which
+// lets the caller accept voice messages or inspect the inline button id.
+// Validation (AskValidate) is applied to the text answer only.
+func (c *Conversation) AskEvent(ctx context.Context, prompt string, opts ...AskOption) (Event, error) {
+	return c.ask(ctx, prompt, false, opts)
+}
+
+// ask implements Ask and AskEvent; textOnly makes non-answer events re-prompt.
+func (c *Conversation) ask(ctx context.Context, prompt string, textOnly bool, opts []AskOption) (Event, error) {
+	cfg := &askConfig{timeout: defaultAskTimeout}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var deadline <-chan time.Time
+	if cfg.timeout > 0 {
+		timer := time.NewTimer(cfg.timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	text := prompt
+	for {
+		event, err := c.await(ctx, text, cfg, deadline)
+		if err != nil {
+			return Event{}, err
+		}
+
+		if event.Kind == EventKindCommand && "/"+event.Command == ConversationCancelCommand {
+			return event, ErrConversationCancelled
+		}
+
+		text = prompt
+		isAnswer := event.Kind == EventKindText || event.Kind == EventKindInlineButton
+		if !isAnswer {
+			if textOnly {
+				continue
+			}
+			return event, nil
+		}
+
+		if cfg.validate == nil {
+			return event, nil
+		}
+
+		if verr := cfg.validate(answerText(event)); verr != nil {
+			text = verr.Error() + "\n" + prompt
+			continue
+		}
+
+		return event, nil
+	}
+}
+
+// await sends one prompt layer and blocks until an event is delivered to it.
+// While waiting, the user's lock is parked on the prompt layer, so the answer
+// is not dropped as "chat busy".
+func (c *Conversation) await(
+	ctx context.Context, text string, cfg *askConfig, deadline <-chan time.Time,
+) (Event, error) {
+	answers := make(chan Event, 1)
+	deliver := func(_ context.Context, event Event) error {
+		select {
+		case answers <- event:
+		default:
+		}
+		return nil
+	}
+
+	layer := c.bot.NewLayer()
+	layer.AddText(text)
+	for _, label := range cfg.buttons {
+		layer.RegisterIButton(label, deliver)
+	}
+	layer.layerDefaultHandler = deliver
+	scope := cfg.scope
+	if scope.threadID == 0 {
+		scope.threadID = c.threadID
+	}
+	layer.SetScope(scope)
+
+	if control, ok := chatControllerFromContext(ctx); ok {
+		control.park(c.lock, layer)
+		defer control.unpark(c.lock)
+	}
+
+	sent, err := c.bot.sendInstalled(recipient{chatID: c.chatID, thread: c.threadID, locale: Locale(ctx)}, layer)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to send prompt: %w", err)
+	}
+	// Once await returns nobody reads answers: uninstall the prompt, so later
+	// messages reach the next layer and taps on its buttons get the
+	// stale-button answer instead of vanishing.
+	defer func() {
+		c.bot.dropLayer(c.chatID, c.threadID, layer)
+		c.bot.forgetMessageLayer(c.chatID, sent.MessageID)
+	}()
+
+	select {
+	case event := <-answers:
+		return event, nil
+	case <-deadline:
+		return Event{}, ErrConversationTimeout
+	case <-ctx.Done():
+		return Event{}, fmt.Errorf("conversation aborted: %w", ctx.Err())
+	}
+}
+
+// answerText extracts the user's answer: the typed text or the tapped label.
+func answerText(event Event) string {
+	if event.Kind == EventKindInlineButton {
+		return event.ButtonText
+	}
+
+	return event.Text
+}
@@ -0,0 +1,405 @@
+package bf
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func cmdUpdate(chatID int64, command string) tgbotapi.Update {
+	return tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			Text:     command,
+			Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+			Chat:     &tgbotapi.Chat{ID: chatID},
+			From:     &tgbotapi.User{ID: 1},
+		},
+	}
+}
+
+// runConversation dispatches /talk to handler through handleUpdate and
+// returns a channel closed when the handler returns.
+func runConversation(t *testing.T, bot *ChatBotImpl, c chatController, handler HandlerFunc) <-chan struct{} {
+	t.Helper()
+	bot.RegisterCommand("/talk", handler)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bot.handleUpdate(context.Background(), c, cmdUpdate(42, "/talk"))
+	}()
+	return done
+}
+
+// waitSent blocks until the mock has recorded at least n sends.
+func waitSent(t *testing.T, mock *mockTelegramAPI, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for mock.sentCount() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("want %d sends, got %d", n, mock.sentCount())
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+func waitDone(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("conversation handler did not return")
+	}
+}
+
+func TestConversation_AskReceivesNextMessageDespiteChatLock(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	var name, age string
+	done := runConversation(t, bot, c, func(ctx context.Context, ev Event) error {
+		conv := bot.Conversation(ev)
+		var err error
+		if name, err = conv.Ask(ctx, "Your name?"); err != nil {
+			return err
+		}
+		age, err = conv.Ask(ctx, "Your age?")
+		return err
+	})
+
+	waitSent(t, mock, 1)
+	bot.handleUpdate(context.Background(), c, msgUpdate("Ada"))
+	waitSent(t, mock, 2)
+	bot.handleUpdate(context.Background(), c, msgUpdate("36"))
+	waitDone(t, done)
+
+	if name != "Ada" || age != "36" {
+		t.Fatalf("got name=%q age=%q", name, age)
+	}
+	if !c.tryAcquire(lockKey(Event{ChatID: 42, UserTGID: 1})) {
+		t.Fatal("chat lock not released after the conversation")
+	}
+}
+
+func TestConversation_ValidationReprompts(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	var got string
+	done := runConversation(t, bot, c, func(ctx context.Context, ev Event) error {
+		var err error
+		got, err = bot.Conversation(ev).Ask(ctx, "Number?", AskValidate(func(answer string) error {
+			if answer != "7" {
+				return errors.New("not seven")
+			}
+			return nil
+		}))
+		return err
+	})
+
+	waitSent(t, mock, 1)
+	bot.handleUpdate(context.Background(), c, msgUpdate("six"))
+	waitSent(t, mock, 2)
+
+	reprompt, ok := mock.lastSent().(tgbotapi.MessageConfig)
+	if !ok || reprompt.Text != "not seven\nNumber?" {
+		t.Fatalf("unexpected re-prompt: %+v", mock.lastSent())
+	}
+
+	bot.handleUpdate(context.Background(), c, msgUpdate("7"))
+	waitDone(t, done)
+	if got != "7" {
+		t.Fatalf("want 7, got %q", got)
+	}
+}
+
+func TestConversation_CancelCommand(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	errCh := make(chan error, 1)
+	done := runConversation(t, bot, c, func(ctx context.Context, ev Event) error {
+		_, err := bot.Conversation(ev).Ask(ctx, "Name?")
+		errCh <- err
+		return nil
+	})
+
+	waitSent(t, mock, 1)
+	bot.handleUpdate(context.Background(), c, cmdUpdate(42, ConversationCancelCommand))
+	waitDone(t, done)
+
+	if err := <-errCh; !errors.Is(err, ErrConversationCancelled) {
+		t.Fatalf("want ErrConversationCancelled, got %v", err)
+	}
+}
+
+func TestConversation_TimeoutDropsPromptLayer(t *testing.T) {
+	bot, _ := newTestBot()
+	c := newChatController(context.Background())
+
+	errCh := make(chan error, 1)
+	done := runConversation(t, bot, c, func(ctx context.Context, ev Event) error {
+		_, err := bot.Conversation(ev).Ask(ctx, "Name?", AskTimeout(20*time.Millisecond))
+		errCh <- err
+		return nil
+	})
+	waitDone(t, done)
+
+	if err := <-errCh; !errors.Is(err, ErrConversationTimeout) {
+		t.Fatalf("want ErrConversationTimeout, got %v", err)
+	}
+	bot.layersMutex.RLock()
+	_, present := bot.chatHandlerLayers[chatKey(42)]
+	bot.layersMutex.RUnlock()
+	if present {
+		t.Fatal("prompt layer left installed after timeout")
+	}
+}
+
+func TestConversation_AskButtonsAnswerWithLabel(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	var got string
+	done := runConversation(t, bot, c, func(ctx context.Context, ev Event) error {
+		var err error
+		got, err = bot.Conversation(ev).Ask(ctx, "Pick", AskButtons("Yes", "No"))
+		return err
+	})
+
+	waitSent(t, mock, 1)
+	msg := mock.lastSent().(tgbotapi.MessageConfig)
+	markup := msg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	no := markup.InlineKeyboard[1][0]
+
+	bot.handleUpdate(context.Background(), c, tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			Data: *no.CallbackData,
+			From: &tgbotapi.User{ID: 1},
+			Message: &tgbotapi.Message{
+				Chat:        &tgbotapi.Chat{ID: 42},
+				ReplyMarkup: &markup,
+			},
+		},
+	})
+	waitDone(t, done)
+
+	if got != "No" {
+		t.Fatalf("want No, got %q", got)
+	}
+}
+
+func TestChatController_ParkHandsOffOnce(t *testing.T) {
+	c := newChatController(context.Background())
+	c.tryAcquire(chatKey(1))
+	prompt := &HandlerLayer{}
+	c.park(chatKey(1), prompt)
+
+	if c.claimParked(chatKey(1), &HandlerLayer{}) {
+		t.Fatal("a park must hand off only to its prompt layer")
+	}
+	if !c.claimParked(chatKey(1), prompt) {
+		t.Fatal("parked chat must be claimable")
+	}
+	if c.claimParked(chatKey(1), prompt) {
+		t.Fatal("a park must hand off only one update")
+	}
+}
+
+// answerUpdate is a text message from the user who sent /talk.
+func answerUpdate(text string) tgbotapi.Update {
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		Text: text,
+		Chat: &tgbotapi.Chat{ID: 42},
+		From: &tgbotapi.User{ID: 1},
+	}}
+}
+
+func TestConversation_ReplacedPromptKeepsLock(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	done := runConversation(t, bot, c, func(ctx context.Context, ev Event) error {
+		_, err := bot.Conversation(ev).Ask(ctx, "Name?", AskTimeout(100*time.Millisecond))
+		return err
+	})
+	waitSent(t, mock, 1)
+
+	// A broadcast replaces the prompt while the dialog waits: its handler
+	// must not run past the conversation's lock.
+	var ran bool
+	news := bot.NewLayer("news")
+	news.layerDefaultHandler = func(context.Context, Event) error {
+		ran = true
+		return nil
+	}
+	if err := bot.SendMsg(42, news); err != nil {
+		t.Fatal(err)
+	}
+	bot.handleUpdate(context.Background(), c, answerUpdate("Ada"))
+	waitDone(t, done)
+
+	if ran {
+		t.Fatal("an update bypassed the lock of a waiting conversation")
+	}
+}
+
+func TestConversation_WaitingAskFreesDispatcherSlot(t *testing.T) {
+	bot, mock := newTestBot()
+	bot.updateConcurrency = 1
+
+	answered := make(chan string, 1)
+	bot.RegisterCommand("/talk", func(ctx context.Context, ev Event) error {
+		answer, err := bot.Conversation(ev).Ask(ctx, "Name?")
+		answered <- answer
+		return err
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	loopDone := make(chan struct{})
+	go func() {
+		_ = bot.mainLoop(ctx, mock.updates)
+		close(loopDone)
+	}()
+
+	mock.updates <- cmdUpdate(42, "/talk")
+	waitSent(t, mock, 1)
+	// With one slot, the answer is only dispatched if the waiting Ask gave
+	// its slot back.
+	mock.updates <- answerUpdate("Ada")
+
+	select {
+	case got := <-answered:
+		if got != "Ada" {
+			t.Fatalf("want Ada, got %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("answer dropped while the conversation held the only slot")
+	}
+	cancel()
+	<-loopDone
+}
+
+func TestChatController_SweeperKeepsParkedLock(t *testing.T) {
+	defer withShortTickers(t)()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := newChatController(ctx)
+	c.tryAcquire(chatKey(1))
+	c.park(chatKey(1), &HandlerLayer{})
+	c.tryAcquire(chatKey(2))
+
+	time.Sleep(50 * time.Millisecond)
+	if c.tryAcquire(chatKey(1)) {
+		t.Fatal("sweeper evicted a parked lock")
+	}
+	if !c.tryAcquire(chatKey(2)) {
+		t.Fatal("sweeper kept an expired lock")
+	}
+}
+
+func TestConversation_OldMessageTapKeepsPark(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	var tapped bool
+	old := bot.NewLayer("old")
+	old.RegisterIButton("Old", func(context.Context, Event) error {
+		tapped = true
+		return nil
+	})
+	mock.sendResp = tgbotapi.Message{MessageID: 900}
+	if err := bot.SendMsg(42, old); err != nil {
+		t.Fatal(err)
+	}
+	oldMarkup := mock.lastMarkup(t)
+	mock.sendResp = tgbotapi.Message{}
+
+	var got string
+	done := runConversation(t, bot, c, func(ctx context.Context, ev Event) error {
+		var err error
+		got, err = bot.Conversation(ev).Ask(ctx, "Name?", AskTimeout(0))
+		return err
+	})
+	waitSent(t, mock, 2)
+
+	bot.handleUpdate(context.Background(), c, withMessageID(tapUpdate(42, oldMarkup, "Old"), 900))
+	bot.handleUpdate(context.Background(), c, answerUpdate("Ada"))
+	waitDone(t, done)
+
+	if got != "Ada" {
+		t.Fatalf("want Ada, got %q", got)
+	}
+	if tapped {
+		t.Fatal("an old message's button must not run in the middle of the dialog")
+	}
+}
+
+func TestConversation_StaleTapKeepsPark(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	old := bot.NewLayer("old")
+	old.RegisterIButton("Old", func(context.Context, Event) error { return nil })
+	if err := bot.SendMsg(42, old); err != nil {
+		t.Fatal(err)
+	}
+	oldMarkup := mock.lastMarkup(t)
+
+	var got string
+	done := runConversation(t, bot, c, func(ctx context.Context, ev Event) error {
+		var err error
+		got, err = bot.Conversation(ev).Ask(ctx, "Name?", AskTimeout(0))
+		return err
+	})
+	waitSent(t, mock, 2)
+
+	stale := tapUpdate(42, oldMarkup, "Old")
+	stale.CallbackQuery.ID = "cb"
+	bot.handleUpdate(context.Background(), c, stale)
+	bot.handleUpdate(context.Background(), c, answerUpdate("Ada"))
+	waitDone(t, done)
+
+	if got != "Ada" {
+		t.Fatalf("want Ada, got %q", got)
+	}
+	if len(mock.requests) != 1 {
+		t.Fatalf("stale tap must be answered, got %d requests", len(mock.requests))
+	}
+}
+
+func TestConversation_AnsweredPromptButtonsAreStale(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+	mock.sendResp = tgbotapi.Message{MessageID: 10}
+
+	done := runConversation(t, bot, c, func(ctx context.Context, ev Event) error {
+		_, err := bot.Conversation(ev).Ask(ctx, "Pick", AskButtons("Yes", "No"))
+		return err
+	})
+	waitSent(t, mock, 1)
+	markup := mock.lastMarkup(t)
+	bot.handleUpdate(context.Background(), c, answerUpdate("maybe"))
+	waitDone(t, done)
+
+	tap := withMessageID(tapUpdate(42, markup, "Yes"), 10)
+	tap.CallbackQuery.ID = "late"
+	bot.handleUpdate(context.Background(), c, tap)
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	for _, req := range mock.requests {
+		if cb, ok := req.(tgbotapi.CallbackConfig); ok && cb.CallbackQueryID == "late" {
+			if cb.Text != bot.staleButtonText {
+				t.Fatalf("late tap answered %q", cb.Text)
+			}
+			return
+		}
+	}
+	t.Fatal("late tap on the answered prompt was not answered as stale")
+}
@@ -48,7 +48,7 @@ func TestCleaner_RemovesExpiredOnTick(t *testing.T) {
 
 	expired := bot.NewLayer()
 	expired.ttl = time.Now().Add(-time.Hour)
-	bot.setLayer(expired, 1)
+	bot.setLayer(expired, 1, 0)
 
 	go bot.cleaner()
 	defer bot.Stop()
@@ -56,7 +56,7 @@ func TestCleaner_RemovesExpiredOnTick(t *testing.T) {
 	deadline := time.Now().Add(time.Second)
 	for time.Now().Before(deadline) {
 		bot.layersMutex.RLock()
-		_, present := bot.chatHandlerLayers[1]
+		_, present := bot.chatHandlerLayers[chatKey(1)]
 		bot.layersMutex.RUnlock()
 		if !present {
 			return
@@ -96,13 +96,13 @@ func TestChatController_CleanOldEvictsStaleLocks(t *testing.T) {
 
 	// Insert a stale lock manually.
 	c.mux.Lock()
-	c.userInWork[42] = time.Now().Add(-time.Hour)
+	c.userInWork[chatKey(42)] = time.Now().Add(-time.Hour)
 	c.mux.Unlock()
 
 	deadline := time.Now().Add(time.Second)
 	for time.Now().Before(deadline) {
 		c.mux.Lock()
-		_, present := c.userInWork[42]
+		_, present := c.userInWork[chatKey(42)]
 		c.mux.Unlock()
 		if !present {
 			return
@@ -116,7 +116,7 @@ func TestChatController_CleanOldExitsOnCtx(t *testing.T) {
 	defer withShortTickers(t)()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	c := chatController{userInWork: map[int64]time.Time{}, mux: &sync.Mutex{}}
+	c := chatController{userInWork: map[scopeKey]time.Time{}, mux: &sync.Mutex{}}
 
 	done := make(chan struct{})
 	go func() {
@@ -202,24 +202,24 @@ func TestChatBotRegisterIButton_AddsToDefaultLayer(t *testing.T) {
 	}
 }
 
-// --- findAndWipeChatLayerHandler happy path --------------------------------
+// --- findAndWipeLayer happy path --------------------------------
 
 func TestFindAndWipeChatLayerHandler(t *testing.T) {
 	bot, _ := newTestBot()
 
 	// No chat-specific layer → returns default.
-	if got := bot.findAndWipeChatLayerHandler(123); got != bot.defaultHandlerLayer {
+	if got := bot.findAndWipeLayer(Event{ChatID: 123}); got != bot.defaultHandlerLayer {
 		t.Fatal("expected default layer fallback")
 	}
 
 	// Chat-specific layer is consumed.
 	custom := bot.NewLayer()
-	bot.setLayer(custom, 5)
-	if got := bot.findAndWipeChatLayerHandler(5); got != custom {
+	bot.setLayer(custom, 5, 0)
+	if got := bot.findAndWipeLayer(Event{ChatID: 5}); got != custom {
 		t.Fatal("expected chat-specific layer")
 	}
 	// And subsequent lookup returns default again.
-	if got := bot.findAndWipeChatLayerHandler(5); got != bot.defaultHandlerLayer {
+	if got := bot.findAndWipeLayer(Event{ChatID: 5}); got != bot.defaultHandlerLayer {
 		t.Fatal("layer not wiped after lookup")
 	}
 }
@@ -513,6 +513,9 @@ func (e errOnEditAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
 	}
 	return e.inner.Send(c)
 }
+func (e errOnEditAPI) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return e.inner.Request(c)
+}
 func (e errOnEditAPI) GetUpdatesChan(cfg tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
 	return e.inner.GetUpdatesChan(cfg)
 }
@@ -2,6 +2,23 @@ package bf
 
 import "errors"
 
-// ErrUnparsedEvent is returned when an incoming Telegram update cannot be
-// translated into an Event the framework understands.
-var ErrUnparsedEvent = errors.New("unparsed event")
+var (
+	// ErrUnparsedEvent is returned when an incoming Telegram update cannot be
+	// translated into an Event the framework understands.
+	ErrUnparsedEvent = errors.New("unparsed event")
+
+	// ErrConversationCancelled is returned by Conversation.Ask when the user
+	// sends the cancel command instead of an answer.
+	ErrConversationCancelled = errors.New("conversation cancelled")
+	// ErrConversationTimeout is returned by Conversation.Ask when no answer
+	// arrives before the ask timeout.
+	ErrConversationTimeout = errors.New("conversation timed out")
+
+	// ErrForgedCallback is passed to the error handler when an inline-button
+	// callback fails WithCallbackSecret verification. No handler runs.
+	ErrForgedCallback = errors.New("forged callback data")
+
+	// ErrInvalidMarkup is returned when a text fails ValidateText for the
+	// bot's parse mode; nothing is sent. See WithPlainTextFallback.
+	ErrInvalidMarkup = errors.New("invalid markup")
+)
@@ -8,20 +8,42 @@ import (
 )
 
 // Event is a normalised representation of a Telegram update consumed by handlers.
+//
+// MessageID is the incoming message, or for inline buttons the bot message
+// that carries the tapped keyboard (the one EditMsg can update in place).
+// Payload is set for inline buttons registered with RegisterIButtonData.
+// ReplyToMessageID is the bot message a user message replies to, if any.
+// ThreadID is the forum topic the event comes from (0 outside topics);
+// ForumTopic is set for EventKindForumTopic. LanguageCode is the user's
+// Telegram language, as given by the client; see WithCatalog.
 type Event struct {
 	Kind             EventKind `json:"kind"`
 	Text             string    `json:"text"`
 	Command          string    `json:"command"`
 	Button           string    `json:"button"`
 	ButtonText       string    `json:"buttonText"`
+	CallbackQueryID  string    `json:"callbackQueryID,omitempty"`
 	ChatID           int64     `json:"chatID"`
+	MessageID        int       `json:"messageID"`
+	ReplyToMessageID int       `json:"replyToMessageID,omitempty"`
+	ThreadID         int       `json:"threadID,omitempty"`
 	UserTGID         int64     `json:"userTGID"`
 	FirstName        string    `json:"firstName"`
 	LastName         string    `json:"lastName"`
 	CommandArguments string    `json:"commandArguments"`
 	Username         string    `json:"username"`
+	LanguageCode     string    `json:"languageCode,omitempty"`
 	lastLayer        *HandlerLayer
-	Voice            *tgbotapi.Voice `json:"-"`
+	Voice            *tgbotapi.Voice    `json:"-"`
+	Contact          *tgbotapi.Contact  `json:"contact,omitempty"`
+	Location         *tgbotapi.Location `json:"location,omitempty"`
+	Payload          *CallbackPayload   `json:"payload,omitempty"`
+	ForumTopic       *ForumTopicEvent   `json:"forumTopic,omitempty"`
+
+	// labelKeys are the catalog keys whose translations equal Text; locale
+	// is the sender's WithCatalog locale.
+	labelKeys []string
+	locale    string
 }
 
 // String renders the event in Go syntax for debug logging.
@@ -61,6 +83,25 @@ func newEvent(update tgbotapi.Update) (Event, bool) {
 		event.Kind = EventKindVoice
 		event.Voice = update.Message.Voice
 		event.ChatID = update.Message.Chat.ID
+		event.MessageID = update.Message.MessageID
+		from = update.Message.From
+	case update.Message != nil && update.Message.Contact != nil:
+		if update.Message.Chat == nil {
+			return event, false
+		}
+		event.Kind = EventKindContact
+		event.Contact = update.Message.Contact
+		event.ChatID = update.Message.Chat.ID
+		event.MessageID = update.Message.MessageID
+		from = update.Message.From
+	case update.Message != nil && update.Message.Location != nil:
+		if update.Message.Chat == nil {
+			return event, false
+		}
+		event.Kind = EventKindLocation
+		event.Location = update.Message.Location
+		event.ChatID = update.Message.Chat.ID
+		event.MessageID = update.Message.MessageID
 		from = update.Message.From
 	case update.Message != nil && update.Message.IsCommand():
 		if update.Message.Chat == nil {
@@ -69,6 +110,7 @@ func newEvent(update tgbotapi.Update) (Event, bool) {
 		event.Kind = EventKindCommand
 		event.Command = update.Message.Command()
 		event.ChatID = update.Message.Chat.ID
+		event.MessageID = update.Message.MessageID
 		event.CommandArguments = update.Message.CommandArguments()
 		from = update.Message.From
 	case update.Message != nil:
@@ -78,14 +120,20 @@ func newEvent(update tgbotapi.Update) (Event, bool) {
 		event.Kind = EventKindText
 		event.Text = update.Message.Text
 		event.ChatID = update.Message.Chat.ID
+		event.MessageID = update.Message.MessageID
 		from = update.Message.From
 	case update.CallbackQuery != nil:
 		event.Kind = EventKindInlineButton
 		event.Button = update.CallbackQuery.Data
+		event.CallbackQueryID = update.CallbackQuery.ID
 		event.ButtonText = lookupCallbackButtonText(update.CallbackQuery)
+		if payload, ok := decodePayload(update.CallbackQuery.Data); ok {
+			event.Payload = &payload
+		}
 
 		if update.CallbackQuery.Message != nil && update.CallbackQuery.Message.Chat != nil {
 			event.ChatID = update.CallbackQuery.Message.Chat.ID
+			event.MessageID = update.CallbackQuery.Message.MessageID
 		}
 		from = update.CallbackQuery.From
 
@@ -93,11 +141,16 @@ func newEvent(update tgbotapi.Update) (Event, bool) {
 		return event, false
 	}
 
+	if update.Message != nil && update.Message.ReplyToMessage != nil {
+		event.ReplyToMessageID = update.Message.ReplyToMessage.MessageID
+	}
+
 	if from != nil {
 		event.UserTGID = from.ID
 		event.FirstName = from.FirstName
 		event.LastName = from.LastName
 		event.Username = from.UserName
+		event.LanguageCode = from.LanguageCode
 	}
 
 	return event, true
@@ -62,6 +62,28 @@ func TestNewEvent_Voice(t *testing.T) {
 	}
 }
 
+func TestNewEvent_ContactAndLocation(t *testing.T) {
+	ev, ok := newEvent(tgbotapi.Update{Message: &tgbotapi.Message{
+		Contact: &tgbotapi.Contact{PhoneNumber: "+1"},
+		Chat:    &tgbotapi.Chat{ID: 3},
+	}})
+	if !ok || ev.Kind != EventKindContact || ev.Contact.PhoneNumber != "+1" || ev.ChatID != 3 {
+		t.Fatalf("bad contact event: ok=%v %+v", ok, ev)
+	}
+
+	ev, ok = newEvent(tgbotapi.Update{Message: &tgbotapi.Message{
+		Location: &tgbotapi.Location{Latitude: 1, Longitude: 2},
+		Chat:     &tgbotapi.Chat{ID: 3},
+	}})
+	if !ok || ev.Kind != EventKindLocation || ev.Location.Longitude != 2 {
+		t.Fatalf("bad location event: ok=%v %+v", ok, ev)
+	}
+
+	if _, ok := newEvent(tgbotapi.Update{Message: &tgbotapi.Message{Contact: &tgbotapi.Contact{}}}); ok {
+		t.Fatal("contact without chat must be rejected")
+	}
+}
+
 func TestNewEvent_CallbackQuery_FindsButtonText(t *testing.T) {
 	data := "btn_id"
 	u := tgbotapi.Update{
@@ -84,6 +106,22 @@ func TestNewEvent_CallbackQuery_FindsButtonText(t *testing.T) {
 	}
 }
 
+func TestNewEvent_MessageID(t *testing.T) {
+	u := msgUpdate("hi")
+	u.Message.MessageID = 5
+	if ev, _ := newEvent(u); ev.MessageID != 5 {
+		t.Fatalf("text MessageID: want 5, got %d", ev.MessageID)
+	}
+
+	cb := tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		Data:    "x",
+		Message: &tgbotapi.Message{MessageID: 8, Chat: &tgbotapi.Chat{ID: 1}},
+	}}
+	if ev, _ := newEvent(cb); ev.MessageID != 8 {
+		t.Fatalf("callback MessageID: want 8, got %d", ev.MessageID)
+	}
+}
+
 func TestNewEvent_CallbackQuery_NilMessageSafe(t *testing.T) {
 	u := tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Data: "x", From: &tgbotapi.User{ID: 1}}}
 	ev, ok := newEvent(u)
@@ -129,3 +167,14 @@ func TestEvent_StringAndJSON(t *testing.T) {
 		t.Fatalf("json missing text: %q", j)
 	}
 }
+
+func TestNewEvent_ReplyToMessageID(t *testing.T) {
+	u := msgUpdate("answer")
+	u.Message.ReplyToMessage = &tgbotapi.Message{MessageID: 31}
+	if ev, _ := newEvent(u); ev.ReplyToMessageID != 31 {
+		t.Fatalf("ReplyToMessageID: want 31, got %d", ev.ReplyToMessageID)
+	}
+	if ev, _ := newEvent(msgUpdate("plain")); ev.ReplyToMessageID != 0 {
+		t.Fatalf("ReplyToMessageID: want 0, got %d", ev.ReplyToMessageID)
+	}
+}
@@ -219,7 +219,7 @@ func getStringFromButtonOrPrompt(event bf.Event) (string, error) {
 		return event.Text, nil
 	case bf.EventKindInlineButton:
 		return event.ButtonText, nil
-	case bf.EventKindCommand, bf.EventKindVoice:
+	case bf.EventKindCommand, bf.EventKindVoice, bf.EventKindContact, bf.EventKindLocation, bf.EventKindForumTopic:
 		return "", errors.New("unexpected event kind")
 	}
 	return "", errors.New("unexpected event kind")
@@ -142,7 +142,7 @@ func BenchmarkDispatcher_NoMiddleware(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		bot.handleUpdate(context.Background(), c, upd)
-		c.release(1) // re-arm the lock for next iteration
+		c.release(chatKey(1)) // re-arm the lock for next iteration
 	}
 }
 
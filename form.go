@@ -0,0 +1,695 @@
+package bf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// FormFieldKind identifies what a form field collects and how it is rendered.
+type FormFieldKind string
+
+// Supported form field kinds.
+const (
+	// FormFieldText accepts any text message.
+	FormFieldText FormFieldKind = "text"
+	// FormFieldNumber accepts a decimal number (comma or dot separator).
+	FormFieldNumber FormFieldKind = "number"
+	// FormFieldChoice offers fixed options as inline buttons.
+	FormFieldChoice FormFieldKind = "choice"
+	// FormFieldContact asks for the user's phone via a reply-keyboard button.
+	FormFieldContact FormFieldKind = "contact"
+	// FormFieldLocation asks for a location via a reply-keyboard button.
+	FormFieldLocation FormFieldKind = "location"
+	// FormFieldDate accepts a date typed in the form's date layout.
+	FormFieldDate FormFieldKind = "date"
+)
+
+// defaultFormDateLayout is the layout FormFieldDate answers are parsed with.
+const defaultFormDateLayout = "2006-01-02"
+
+// FormValidator checks a parsed field value before it is stored. The value is
+// string (text, choice), float64 (number), time.Time (date),
+// *tgbotapi.Contact or *tgbotapi.Location. The error text is shown to the
+// user and the field is asked again.
+type FormValidator func(value any) error
+
+// FormCompleteFunc receives the confirmed answers of a form.
+type FormCompleteFunc func(ctx context.Context, event Event, result FormResult) error
+
+// FormTexts holds the user-visible strings a Form renders. Zero fields keep
+// the English defaults.
+type FormTexts struct {
+	Back          string
+	Skip          string
+	Confirm       string
+	Cancel        string
+	Edit          string
+	Summary       string
+	Cancelled     string
+	ShareContact  string
+	ShareLocation string
+	InvalidNumber string
+	InvalidDate   string
+}
+
+var defaultFormTexts = FormTexts{
+	Back:          "« Back",
+	Skip:          "Skip »",
+	Confirm:       "Confirm",
+	Cancel:        "Cancel",
+	Edit:          "Edit",
+	Summary:       "Please check your answers:",
+	Cancelled:     "Cancelled.",
+	ShareContact:  "Share contact",
+	ShareLocation: "Share location",
+	InvalidNumber: "Please send a number.",
+	InvalidDate:   "Please send a date like 2006-01-02.",
+}
+
+// FormField describes one step of a Form. Obtain it from one of the Form.Add*
+// methods and refine it with the chaining setters.
+type FormField struct {
+	name       string
+	label      string
+	prompt     string
+	kind       FormFieldKind
+	choices    []string
+	optional   bool
+	validators []FormValidator
+}
+
+// Optional lets the user skip the field with a Skip button.
+func (f *FormField) Optional() *FormField {
+	f.optional = true
+	return f
+}
+
+// Label sets the caption used in the summary. Defaults to the field name.
+func (f *FormField) Label(label string) *FormField {
+	f.label = label
+	return f
+}
+
+// Form is a declarative multi-step wizard: it asks each field in turn,
+// validates the answers, shows a summary with Edit buttons and calls the
+// completion handler once the user confirms.
+//
+// Every step is an ordinary HandlerLayer sent with SendMsg, so events a step
+// does not expect (e.g. /start) fall through to the default layer. Partial
+// answers live in the bot's SessionStore, so an abandoned form can be picked
+// up again with Resume, even after a restart when the store is persistent.
+//
+// Define fields before the first Start; a Form is safe to Start for many
+// chats concurrently afterwards. Started from a handler in a group, a form
+// belongs to the member who sent the event: every member has answers of
+// their own, and the steps listen to that member only.
+type Form struct {
+	bot        *ChatBotImpl
+	id         string
+	fields     []*FormField
+	onComplete FormCompleteFunc
+	texts      FormTexts
+	dateLayout string
+}
+
+// NewForm creates an empty form. id must be unique among the bot's forms: it
+// namespaces the stored partial answers.
+func (b *ChatBotImpl) NewForm(id string, onComplete FormCompleteFunc) *Form {
+	return &Form{
+		bot:        b,
+		id:         id,
+		onComplete: onComplete,
+		texts:      defaultFormTexts,
+		dateLayout: defaultFormDateLayout,
+	}
+}
+
+// AddText appends a free-text field.
+func (f *Form) AddText(name, prompt string, validators ...FormValidator) *FormField {
+	return f.add(name, prompt, FormFieldText, nil, validators)
+}
+
+// AddNumber appends a numeric field; the value is a float64.
+func (f *Form) AddNumber(name, prompt string, validators ...FormValidator) *FormField {
+	return f.add(name, prompt, FormFieldNumber, nil, validators)
+}
+
+// AddChoice appends a field answered by tapping one of choices.
+func (f *Form) AddChoice(name, prompt string, choices []string, validators ...FormValidator) *FormField {
+	return f.add(name, prompt, FormFieldChoice, choices, validators)
+}
+
+// AddContact appends a field answered by sharing a phone contact.
+func (f *Form) AddContact(name, prompt string, validators ...FormValidator) *FormField {
+	return f.add(name, prompt, FormFieldContact, nil, validators)
+}
+
+// AddLocation appends a field answered by sharing a location.
+func (f *Form) AddLocation(name, prompt string, validators ...FormValidator) *FormField {
+	return f.add(name, prompt, FormFieldLocation, nil, validators)
+}
+
+// AddDate appends a date field; see SetDateLayout for the accepted format.
+func (f *Form) AddDate(name, prompt string, validators ...FormValidator) *FormField {
+	return f.add(name, prompt, FormFieldDate, nil, validators)
+}
+
+func (f *Form) add(
+	name, prompt string, kind FormFieldKind, choices []string, validators []FormValidator,
+) *FormField {
+	field := &FormField{
+		name:       name,
+		label:      name,
+		prompt:     prompt,
+		kind:       kind,
+		choices:    choices,
+		validators: validators,
+	}
+	f.fields = append(f.fields, field)
+
+	return field
+}
+
+// SetTexts overrides the form's user-visible strings. Empty fields of texts
+// keep their current value.
+func (f *Form) SetTexts(texts FormTexts) {
+	merge := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	merge(&f.texts.Back, texts.Back)
+	merge(&f.texts.Skip, texts.Skip)
+	merge(&f.texts.Confirm, texts.Confirm)
+	merge(&f.texts.Cancel, texts.Cancel)
+	merge(&f.texts.Edit, texts.Edit)
+	merge(&f.texts.Summary, texts.Summary)
+	merge(&f.texts.Cancelled, texts.Cancelled)
+	merge(&f.texts.ShareContact, texts.ShareContact)
+	merge(&f.texts.ShareLocation, texts.ShareLocation)
+	merge(&f.texts.InvalidNumber, texts.InvalidNumber)
+	merge(&f.texts.InvalidDate, texts.InvalidDate)
+}
+
+// SetDateLayout sets the time.Parse layout for date fields ("2006-01-02" by default).
+func (f *Form) SetDateLayout(layout string) {
+	f.dateLayout = layout
+}
+
+// Start discards any stored answers for chatID and asks the first field;
+// called from a handler, the form runs in that event's forum topic and, in a
+// group, for the event's sender.
+func (f *Form) Start(ctx context.Context, chatID int64) error {
+	if err := f.validate(); err != nil {
+		return err
+	}
+
+	run := contextFormRun(ctx, chatID)
+	state := formState{Values: map[string]json.RawMessage{}}
+	if err := f.saveState(ctx, run, state); err != nil {
+		return err
+	}
+
+	return f.render(ctx, run, state)
+}
+
+// Resume re-asks the step the chat stopped at, using the stored answers.
+// Falls back to Start when nothing is stored.
+func (f *Form) Resume(ctx context.Context, chatID int64) error {
+	if err := f.validate(); err != nil {
+		return err
+	}
+
+	run := contextFormRun(ctx, chatID)
+	state, ok, err := f.loadState(ctx, run)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return f.Start(ctx, chatID)
+	}
+
+	return f.render(ctx, run, state)
+}
+
+func (f *Form) validate() error {
+	if len(f.fields) == 0 {
+		return fmt.Errorf("form %q has no fields", f.id)
+	}
+	if f.onComplete == nil {
+		return fmt.Errorf("form %q has no completion handler", f.id)
+	}
+
+	seen := make(map[string]struct{}, len(f.fields))
+	for _, field := range f.fields {
+		if _, dup := seen[field.name]; dup {
+			return fmt.Errorf("form %q: duplicate field %q", f.id, field.name)
+		}
+		seen[field.name] = struct{}{}
+
+		if field.kind == FormFieldChoice && len(field.choices) == 0 {
+			return fmt.Errorf("form %q: choice field %q has no choices", f.id, field.name)
+		}
+	}
+
+	return nil
+}
+
+// formState is the stored progress of one chat through a form. Step equal to
+// len(fields) means the summary is shown.
+type formState struct {
+	Step    int                        `json:"step"`
+	Editing bool                       `json:"editing,omitempty"`
+	Values  map[string]json.RawMessage `json:"values"`
+}
+
+// formRun identifies who fills in a form: a chat, or one member of a group
+// (userID != 0). The step handlers are bound to it when rendered.
+type formRun struct {
+	chatID int64
+	userID int64
+}
+
+// contextFormRun is the run a Start or Resume from ctx's handler refers to.
+func contextFormRun(ctx context.Context, chatID int64) formRun {
+	return formRun{chatID: chatID, userID: contextMember(ctx, chatID)}
+}
+
+func (f *Form) storeKey(run formRun) string {
+	key := "form:" + f.id + ":" + strconv.FormatInt(run.chatID, 10)
+	if run.userID != 0 {
+		key += ":" + strconv.FormatInt(run.userID, 10)
+	}
+
+	return key
+}
+
+func (f *Form) loadState(ctx context.Context, run formRun) (formState, bool, error) {
+	raw, ok, err := f.bot.sessionStore.Get(ctx, f.storeKey(run))
+	if err != nil {
+		return formState{}, false, fmt.Errorf("failed to load form state: %w", err)
+	}
+	if !ok {
+		return formState{}, false, nil
+	}
+
+	var state formState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return formState{}, false, fmt.Errorf("failed to decode form state: %w", err)
+	}
+	if state.Values == nil {
+		state.Values = map[string]json.RawMessage{}
+	}
+
+	return state, true, nil
+}
+
+func (f *Form) saveState(ctx context.Context, run formRun, state formState) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode form state: %w", err)
+	}
+	if err := f.bot.sessionStore.Set(ctx, f.storeKey(run), raw); err != nil {
+		return fmt.Errorf("failed to save form state: %w", err)
+	}
+
+	return nil
+}
+
+// render sends the layer for the state's current step to the forum topic, and
+// in the locale, of the event being handled. A member's layer listens to that
+// member only.
+func (f *Form) render(ctx context.Context, run formRun, state formState) error {
+	var layer *HandlerLayer
+	if state.Step >= len(f.fields) {
+		layer = f.summaryLayer(run, state)
+	} else {
+		layer = f.stepLayer(run, state.Step, state.Editing)
+	}
+	if run.userID != 0 {
+		layer.SetScope(ScopeUser(run.userID))
+	}
+
+	return f.bot.sendMsg(contextRecipient(ctx, run.chatID), layer)
+}
+
+func (f *Form) stepLayer(run formRun, step int, editing bool) *HandlerLayer {
+	field := f.fields[step]
+	layer := f.bot.NewLayer()
+	layer.AddText(field.prompt)
+
+	answer := f.answerHandler(run, step)
+	back := f.navHandler(run, step, -1)
+	skip := f.navHandler(run, step, 1)
+	hasBack := step > 0 || editing
+
+	switch field.kind {
+	case FormFieldContact, FormFieldLocation:
+		// Request buttons only exist on the reply keyboard, and one message
+		// cannot mix keyboards, so Back/Skip become reply buttons here too.
+		if field.kind == FormFieldContact {
+			layer.RegisterButtonContact(f.texts.ShareContact, answer)
+		} else {
+			layer.RegisterButtonLocation(f.texts.ShareLocation, answer)
+		}
+		if hasBack {
+			layer.RegisterButton(f.texts.Back, back)
+		}
+		if field.optional {
+			layer.RegisterButton(f.texts.Skip, skip)
+		}
+		layer.RegisterText(AnyText, f.reaskHandler(run))
+	case FormFieldChoice:
+		for _, choice := range field.choices {
+			layer.RegisterIButton(choice, answer)
+		}
+		f.addInlineNav(layer, hasBack, field.optional, back, skip)
+	case FormFieldText, FormFieldNumber, FormFieldDate:
+		layer.RegisterText(AnyText, answer)
+		f.addInlineNav(layer, hasBack, field.optional, back, skip)
+	}
+
+	return layer
+}
+
+func (f *Form) addInlineNav(layer *HandlerLayer, hasBack, optional bool, back, skip HandlerFunc) {
+	if hasBack {
+		layer.RegisterIButton(f.texts.Back, back)
+	}
+	if optional {
+		layer.RegisterIButton(f.texts.Skip, skip)
+	}
+	if hasBack && optional {
+		layer.SetIButtonRowMode()
+	}
+}
+
+func (f *Form) summaryLayer(run formRun, state formState) *HandlerLayer {
+	layer := f.bot.NewLayer()
+	layer.AddText(f.texts.Summary)
+
+	for i, field := range f.fields {
+		value := "—"
+		if raw, ok := state.Values[field.name]; ok {
+			if decoded, err := f.decode(field, raw); err == nil {
+				value = f.display(field, decoded)
+			}
+		}
+		// Answers are user input: never markup of the bot's parse mode.
+		layer.AddText(field.label + ": " + Escape(value, f.bot.apiParseMode()))
+		layer.RegisterIButton(f.texts.Edit+" "+field.label, f.editHandler(run, i))
+	}
+
+	layer.RegisterIButton(f.texts.Confirm, f.confirmHandler(run))
+	layer.RegisterIButton(f.texts.Cancel, f.cancelHandler(run))
+
+	return layer
+}
+
+// reask re-renders the run's current step, prefixed by an optional notice.
+func (f *Form) reask(ctx context.Context, run formRun, notice string) error {
+	state, ok, err := f.loadState(ctx, run)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	if notice != "" {
+		if err := f.bot.sendText(run.chatID, contextThread(ctx), notice); err != nil {
+			return err
+		}
+	}
+
+	return f.render(ctx, run, state)
+}
+
+// reaskHandler repeats the current step, e.g. when text arrives where a
+// contact was expected.
+func (f *Form) reaskHandler(run formRun) HandlerFunc {
+	return func(ctx context.Context, _ Event) error {
+		return f.reask(ctx, run, "")
+	}
+}
+
+func (f *Form) answerHandler(run formRun, step int) HandlerFunc {
+	return func(ctx context.Context, event Event) error {
+		field := f.fields[step]
+
+		value, err := f.parse(field, event)
+		if err == nil {
+			for _, validator := range field.validators {
+				if err = validator(value); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return f.reask(ctx, run, err.Error())
+		}
+
+		state, ok, err := f.loadState(ctx, run)
+		if err != nil || !ok {
+			return err
+		}
+
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode form value: %w", err)
+		}
+		state.Values[field.name] = raw
+
+		return f.advance(ctx, run, state, step+1)
+	}
+}
+
+// navHandler moves from step by delta (Back = -1, Skip = +1). Skipping clears
+// the field's value; editing mode always returns to the summary.
+func (f *Form) navHandler(run formRun, step, delta int) HandlerFunc {
+	return func(ctx context.Context, _ Event) error {
+		state, ok, err := f.loadState(ctx, run)
+		if err != nil || !ok {
+			return err
+		}
+
+		if delta > 0 {
+			delete(state.Values, f.fields[step].name)
+		}
+
+		return f.advance(ctx, run, state, step+delta)
+	}
+}
+
+func (f *Form) advance(ctx context.Context, run formRun, state formState, next int) error {
+	if state.Editing {
+		next = len(f.fields)
+		state.Editing = false
+	}
+	if next < 0 {
+		next = 0
+	}
+	state.Step = next
+
+	if err := f.saveState(ctx, run, state); err != nil {
+		return err
+	}
+
+	return f.render(ctx, run, state)
+}
+
+func (f *Form) editHandler(run formRun, step int) HandlerFunc {
+	return func(ctx context.Context, _ Event) error {
+		state, ok, err := f.loadState(ctx, run)
+		if err != nil || !ok {
+			return err
+		}
+
+		state.Step = step
+		state.Editing = true
+		if err := f.saveState(ctx, run, state); err != nil {
+			return err
+		}
+
+		return f.render(ctx, run, state)
+	}
+}
+
+func (f *Form) confirmHandler(run formRun) HandlerFunc {
+	return func(ctx context.Context, event Event) error {
+		state, ok, err := f.loadState(ctx, run)
+		if err != nil || !ok {
+			return err
+		}
+
+		result := FormResult{Values: make(map[string]any, len(state.Values))}
+		for _, field := range f.fields {
+			raw, ok := state.Values[field.name]
+			if !ok {
+				continue
+			}
+			value, err := f.decode(field, raw)
+			if err != nil {
+				return err
+			}
+			result.Values[field.name] = value
+		}
+
+		if err := f.bot.sessionStore.Delete(ctx, f.storeKey(run)); err != nil {
+			return fmt.Errorf("failed to delete form state: %w", err)
+		}
+
+		return f.onComplete(ctx, event, result)
+	}
+}
+
+func (f *Form) cancelHandler(run formRun) HandlerFunc {
+	return func(ctx context.Context, event Event) error {
+		if err := f.bot.sessionStore.Delete(ctx, f.storeKey(run)); err != nil {
+			return fmt.Errorf("failed to delete form state: %w", err)
+		}
+
+		return f.bot.ReplyText(event, f.texts.Cancelled)
+	}
+}
+
+// parse turns the answering event into the field's typed value.
+func (f *Form) parse(field *FormField, event Event) (any, error) {
+	switch field.kind {
+	case FormFieldText:
+		return event.Text, nil
+	case FormFieldChoice:
+		return event.ButtonText, nil
+	case FormFieldNumber:
+		number, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(event.Text), ",", "."), 64)
+		if err != nil {
+			return nil, errors.New(f.texts.InvalidNumber)
+		}
+		return number, nil
+	case FormFieldDate:
+		date, err := time.Parse(f.dateLayout, strings.TrimSpace(event.Text))
+		if err != nil {
+			return nil, errors.New(f.texts.InvalidDate)
+		}
+		return date, nil
+	case FormFieldContact:
+		if event.Contact == nil {
+			return nil, errors.New(field.prompt)
+		}
+		return event.Contact, nil
+	case FormFieldLocation:
+		if event.Location == nil {
+			return nil, errors.New(field.prompt)
+		}
+		return event.Location, nil
+	}
+
+	return nil, fmt.Errorf("unknown form field kind %q", field.kind)
+}
+
+// decode restores a stored value to the type parse produced.
+func (f *Form) decode(field *FormField, raw json.RawMessage) (any, error) {
+	var (
+		value any
+		err   error
+	)
+
+	switch field.kind {
+	case FormFieldText, FormFieldChoice:
+		var s string
+		err = json.Unmarshal(raw, &s)
+		value = s
+	case FormFieldNumber:
+		var n float64
+		err = json.Unmarshal(raw, &n)
+		value = n
+	case FormFieldDate:
+		var t time.Time
+		err = json.Unmarshal(raw, &t)
+		value = t
+	case FormFieldContact:
+		var c tgbotapi.Contact
+		err = json.Unmarshal(raw, &c)
+		value = &c
+	case FormFieldLocation:
+		var l tgbotapi.Location
+		err = json.Unmarshal(raw, &l)
+		value = &l
+	default:
+		err = fmt.Errorf("unknown form field kind %q", field.kind)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode form field %q: %w", field.name, err)
+	}
+
+	return value, nil
+}
+
+// display renders a decoded value for the summary.
+func (f *Form) display(field *FormField, value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(f.dateLayout)
+	case *tgbotapi.Contact:
+		return strings.TrimSpace(v.PhoneNumber + " " + v.FirstName + " " + v.LastName)
+	case *tgbotapi.Location:
+		return strconv.FormatFloat(v.Latitude, 'f', 6, 64) + ", " + strconv.FormatFloat(v.Longitude, 'f', 6, 64)
+	}
+
+	return fmt.Sprint(field.name, "=", value)
+}
+
+// FormResult carries the confirmed answers, keyed by field name. Skipped
+// optional fields are absent. The typed getters return the zero value for a
+// missing field or a field of another kind.
+type FormResult struct {
+	Values map[string]any
+}
+
+// Has reports whether the field was answered (not skipped).
+func (r FormResult) Has(name string) bool {
+	_, ok := r.Values[name]
+	return ok
+}
+
+// String returns a text or choice answer.
+func (r FormResult) String(name string) string {
+	v, _ := r.Values[name].(string)
+	return v
+}
+
+// Number returns a number answer.
+func (r FormResult) Number(name string) float64 {
+	v, _ := r.Values[name].(float64)
+	return v
+}
+
+// Time returns a date answer.
+func (r FormResult) Time(name string) time.Time {
+	v, _ := r.Values[name].(time.Time)
+	return v
+}
+
+// Contact returns a shared contact, or nil.
+func (r FormResult) Contact(name string) *tgbotapi.Contact {
+	v, _ := r.Values[name].(*tgbotapi.Contact)
+	return v
+}
+
+// Location returns a shared location, or nil.
+func (r FormResult) Location(name string) *tgbotapi.Location {
+	v, _ := r.Values[name].(*tgbotapi.Location)
+	return v
+}
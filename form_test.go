@@ -0,0 +1,260 @@
+package bf
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func lastText(t *testing.T, mock *mockTelegramAPI) string {
+	t.Helper()
+	msg, ok := mock.lastSent().(tgbotapi.MessageConfig)
+	if !ok {
+		t.Fatalf("last sent is %T, not a message", mock.lastSent())
+	}
+	return msg.Text
+}
+
+func TestForm_FullFlowWithValidationEditAndConfirm(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+	ctx := context.Background()
+
+	var result FormResult
+	form := bot.NewForm("signup", func(_ context.Context, _ Event, r FormResult) error {
+		result = r
+		return nil
+	})
+	form.AddText("name", "Your name?").Label("Name")
+	form.AddNumber("age", "Your age?", func(v any) error {
+		if v.(float64) < 18 {
+			return errors.New("too young")
+		}
+		return nil
+	}).Label("Age")
+	form.AddChoice("plan", "Plan?", []string{"Free", "Pro"}).Label("Plan")
+	form.AddDate("start", "Start date?").Optional()
+
+	if err := form.Start(ctx, 42); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastText(t, mock); got != "Your name?" {
+		t.Fatalf("first prompt: %q", got)
+	}
+
+	bot.handleUpdate(ctx, c, msgUpdate("Ada"))
+	bot.handleUpdate(ctx, c, msgUpdate("ten"))
+	if got := lastText(t, mock); got != "Your age?" {
+		t.Fatalf("want age re-asked after bad number, got %q", got)
+	}
+	bot.handleUpdate(ctx, c, msgUpdate("12"))
+	bot.handleUpdate(ctx, c, msgUpdate("36,5"))
+
+	bot.handleUpdate(ctx, c, tapUpdate(42, mock.lastMarkup(t), "Pro"))
+	bot.handleUpdate(ctx, c, tapUpdate(42, mock.lastMarkup(t), "Skip »"))
+
+	summary := lastText(t, mock)
+	if !strings.Contains(summary, "Name: Ada") || !strings.Contains(summary, "Age: 36.5") ||
+		!strings.Contains(summary, "start: —") {
+		t.Fatalf("unexpected summary:\n%s", summary)
+	}
+
+	// Edit the name, then land back on the summary.
+	bot.handleUpdate(ctx, c, tapUpdate(42, mock.lastMarkup(t), "Edit Name"))
+	if got := lastText(t, mock); got != "Your name?" {
+		t.Fatalf("edit prompt: %q", got)
+	}
+	bot.handleUpdate(ctx, c, msgUpdate("Grace"))
+	if !strings.Contains(lastText(t, mock), "Name: Grace") {
+		t.Fatalf("summary after edit:\n%s", lastText(t, mock))
+	}
+
+	bot.handleUpdate(ctx, c, tapUpdate(42, mock.lastMarkup(t), "Confirm"))
+
+	if result.String("name") != "Grace" || result.Number("age") != 36.5 || result.String("plan") != "Pro" {
+		t.Fatalf("unexpected result: %+v", result.Values)
+	}
+	if result.Has("start") {
+		t.Fatal("skipped field must be absent")
+	}
+	if _, ok, _ := bot.sessionStore.Get(ctx, form.storeKey(formRun{chatID: 42})); ok {
+		t.Fatal("state not deleted after confirm")
+	}
+}
+
+func TestForm_BackReturnsToPreviousStep(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+	ctx := context.Background()
+
+	form := bot.NewForm("f", func(context.Context, Event, FormResult) error { return nil })
+	form.AddText("a", "A?")
+	form.AddText("b", "B?")
+
+	if err := form.Start(ctx, 42); err != nil {
+		t.Fatal(err)
+	}
+	bot.handleUpdate(ctx, c, msgUpdate("x"))
+	bot.handleUpdate(ctx, c, tapUpdate(42, mock.lastMarkup(t), "« Back"))
+
+	if got := lastText(t, mock); got != "A?" {
+		t.Fatalf("want A? after Back, got %q", got)
+	}
+}
+
+func TestForm_SummaryEscapesAnswers(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+	ctx := context.Background()
+
+	form := bot.NewForm("note", func(context.Context, Event, FormResult) error { return nil })
+	form.AddText("note", "Note?").Label("<b>Note</b>")
+
+	if err := form.Start(ctx, 42); err != nil {
+		t.Fatal(err)
+	}
+	bot.handleUpdate(ctx, c, msgUpdate("Tom <3 Jerry & <i>co"))
+
+	if got := lastText(t, mock); !strings.Contains(got, "<b>Note</b>: Tom &lt;3 Jerry &amp; &lt;i&gt;co") {
+		t.Fatalf("summary:\n%s", got)
+	}
+}
+
+func TestForm_GroupMembersFillInTheirOwnForms(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+	ctx := context.Background()
+
+	results := map[int64]FormResult{}
+	form := bot.NewForm("poll", func(_ context.Context, ev Event, r FormResult) error {
+		results[ev.UserTGID] = r
+		return nil
+	})
+	form.AddText("name", "Name?")
+	form.AddText("city", "City?")
+	bot.RegisterCommand("/poll", func(ctx context.Context, ev Event) error {
+		return form.Start(ctx, ev.ChatID)
+	})
+
+	command := func(userID int64) tgbotapi.Update {
+		u := groupUpdate(userID, "/poll")
+		u.Message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Length: len("/poll")}}
+		return u
+	}
+	confirm := func(userID int64) {
+		u := tapUpdate(-100, mock.lastMarkup(t), "Confirm")
+		u.CallbackQuery.From = &tgbotapi.User{ID: userID}
+		u.CallbackQuery.Message.Chat.Type = "group"
+		bot.handleUpdate(ctx, c, u)
+	}
+
+	// The members' answers interleave; each lands in its own form.
+	bot.handleUpdate(ctx, c, command(1))
+	bot.handleUpdate(ctx, c, command(2))
+	bot.handleUpdate(ctx, c, groupUpdate(1, "Ada"))
+	bot.handleUpdate(ctx, c, groupUpdate(2, "Bob"))
+	bot.handleUpdate(ctx, c, groupUpdate(1, "Paris"))
+	confirm(1)
+	bot.handleUpdate(ctx, c, groupUpdate(2, "Oslo"))
+	confirm(2)
+
+	if results[1].String("name") != "Ada" || results[1].String("city") != "Paris" {
+		t.Fatalf("member 1: %+v", results[1].Values)
+	}
+	if results[2].String("name") != "Bob" || results[2].String("city") != "Oslo" {
+		t.Fatalf("member 2: %+v", results[2].Values)
+	}
+}
+
+func TestForm_ContactStepUsesRequestButton(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+	ctx := context.Background()
+
+	var phone string
+	form := bot.NewForm("c", func(_ context.Context, _ Event, r FormResult) error {
+		phone = r.Contact("phone").PhoneNumber
+		return nil
+	})
+	form.AddContact("phone", "Share your phone")
+
+	if err := form.Start(ctx, 42); err != nil {
+		t.Fatal(err)
+	}
+	msg := mock.lastSent().(tgbotapi.MessageConfig)
+	kb, ok := msg.ReplyMarkup.(tgbotapi.ReplyKeyboardMarkup)
+	if !ok || !kb.Keyboard[0][0].RequestContact {
+		t.Fatalf("want contact request button, got %+v", msg.ReplyMarkup)
+	}
+
+	bot.handleUpdate(ctx, c, tgbotapi.Update{Message: &tgbotapi.Message{
+		Chat:    &tgbotapi.Chat{ID: 42},
+		From:    &tgbotapi.User{ID: 1},
+		Contact: &tgbotapi.Contact{PhoneNumber: "+100", FirstName: "Ada"},
+	}})
+	if !strings.Contains(lastText(t, mock), "phone: +100 Ada") {
+		t.Fatalf("summary: %q", lastText(t, mock))
+	}
+
+	bot.handleUpdate(ctx, c, tapUpdate(42, mock.lastMarkup(t), "Confirm"))
+	if phone != "+100" {
+		t.Fatalf("want +100, got %q", phone)
+	}
+}
+
+func TestForm_ResumeContinuesFromStoredStep(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+	ctx := context.Background()
+
+	form := bot.NewForm("r", func(context.Context, Event, FormResult) error { return nil })
+	form.AddText("a", "A?")
+	form.AddDate("d", "When?")
+
+	if err := form.Start(ctx, 42); err != nil {
+		t.Fatal(err)
+	}
+	bot.handleUpdate(ctx, c, msgUpdate("x"))
+	bot.handleUpdate(ctx, c, cmdUpdate(42, "/start"))
+
+	if err := form.Resume(ctx, 42); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastText(t, mock); got != "When?" {
+		t.Fatalf("want When? on resume, got %q", got)
+	}
+
+	bot.handleUpdate(ctx, c, msgUpdate("2026-10-19"))
+	state, _, _ := form.loadState(ctx, formRun{chatID: 42})
+	d, err := form.decode(form.fields[1], state.Values["d"])
+	if err != nil || !d.(time.Time).Equal(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("date not stored: %v %v", d, err)
+	}
+}
+
+func TestForm_ValidateDefinition(t *testing.T) {
+	bot, _ := newTestBot()
+	ctx := context.Background()
+
+	empty := bot.NewForm("e", func(context.Context, Event, FormResult) error { return nil })
+	if err := empty.Start(ctx, 1); err == nil {
+		t.Fatal("form without fields must fail")
+	}
+
+	dup := bot.NewForm("d", func(context.Context, Event, FormResult) error { return nil })
+	dup.AddText("x", "?")
+	dup.AddText("x", "?")
+	if err := dup.Start(ctx, 1); err == nil {
+		t.Fatal("duplicate field names must fail")
+	}
+
+	choice := bot.NewForm("c", func(context.Context, Event, FormResult) error { return nil })
+	choice.AddChoice("x", "?", nil)
+	if err := choice.Start(ctx, 1); err == nil {
+		t.Fatal("choice without options must fail")
+	}
+}
@@ -21,5 +21,5 @@ func (b *ChatBotImpl) defaultEventHandler(_ context.Context, event Event) error
 		return fmt.Errorf("failed to marshal event to json: %w", err)
 	}
 
-	return b.SendText(event.ChatID, "I don't know what to do with this event: \n"+jsonView+"\n")
+	return b.ReplyText(event, "I don't know what to do with this event: \n"+jsonView+"\n")
 }
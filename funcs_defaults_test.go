@@ -3,6 +3,8 @@ package bf
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -10,14 +12,26 @@ import (
 
 type capturingLogger struct{ errs []string }
 
-func (c *capturingLogger) Debug(...any)                   {}
-func (c *capturingLogger) Debugf(string, ...any)          {}
-func (c *capturingLogger) Info(...any)                    {}
-func (c *capturingLogger) Infof(string, ...any)           {}
-func (c *capturingLogger) Warn(...any)                    {}
-func (c *capturingLogger) Warnf(string, ...any)           {}
-func (c *capturingLogger) Error(_ ...any)                 { c.errs = append(c.errs, "err") }
-func (c *capturingLogger) Errorf(format string, _ ...any) { c.errs = append(c.errs, format) }
+func (c *capturingLogger) Debug(...any)          {}
+func (c *capturingLogger) Debugf(string, ...any) {}
+func (c *capturingLogger) Info(...any)           {}
+func (c *capturingLogger) Infof(string, ...any)  {}
+func (c *capturingLogger) Warn(...any)           {}
+func (c *capturingLogger) Warnf(string, ...any)  {}
+func (c *capturingLogger) Error(_ ...any)        { c.errs = append(c.errs, "err") }
+func (c *capturingLogger) Errorf(format string, args ...any) {
+	c.errs = append(c.errs, fmt.Sprintf(format, args...))
+}
+
+// logged reports whether an error log line contains s.
+func (c *capturingLogger) logged(s string) bool {
+	for _, line := range c.errs {
+		if strings.Contains(line, s) {
+			return true
+		}
+	}
+	return false
+}
 
 func TestDefaultErrorHandler_LogsErr(t *testing.T) {
 	bot, _ := newTestBot()
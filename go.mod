@@ -7,4 +7,5 @@ toolchain go1.26.1
 require (
 	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
 	github.com/google/uuid v1.3.0
+	gopkg.in/yaml.v3 v3.0.1
 )
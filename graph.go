@@ -0,0 +1,299 @@
+package bf
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// GraphNodeKind tells what a ConversationGraph node stands for.
+type GraphNodeKind string
+
+// Kinds of ConversationGraph nodes.
+const (
+	// GraphNodeLayer is the default layer.
+	GraphNodeLayer GraphNodeKind = "layer"
+	// GraphNodeScreen is a WithMenus screen.
+	GraphNodeScreen GraphNodeKind = "screen"
+	// GraphNodeHandler is a handler whose next step is only known at run
+	// time, labelled with its Go function or RegisterMenuHandler name.
+	GraphNodeHandler GraphNodeKind = "handler"
+	// GraphNodeURL is a link opened by a URL button.
+	GraphNodeURL GraphNodeKind = "url"
+)
+
+// Node ID prefixes; the default layer is defaultLayerNode.
+const (
+	defaultLayerNode = "default"
+	screenNodePrefix = "screen:"
+)
+
+// GraphNode is a step of the conversation. Text is the first line of the
+// message a screen shows.
+type GraphNode struct {
+	ID    string
+	Kind  GraphNodeKind
+	Label string
+	Text  string
+}
+
+// GraphEdge is a command, button or text leading from one node to another;
+// Label is what the user sends or taps.
+type GraphEdge struct {
+	From  string
+	To    string
+	Label string
+}
+
+// ConversationGraph is the bot's conversation flow as far as it is known
+// before Start: see ChatBotImpl.Graph.
+type ConversationGraph struct {
+	Nodes []GraphNode
+	Edges []GraphEdge
+}
+
+// layerLink is one way out of a layer: target is a node ID when known
+// statically, else handler is where the flow continues.
+type layerLink struct {
+	label   string
+	target  string
+	handler HandlerFunc
+}
+
+func screenNode(name string) string { return screenNodePrefix + name }
+
+// Graph walks the commands and buttons of the default layer and the
+// WithMenus screens, which it compiles with LoadMenus first, so it works
+// before Start. Menu commands and buttons lead to the screens they open;
+// other handlers lead to a handler node named after their Go function,
+// since where they go is only decided at run time. Render it with DOT or
+// Mermaid.
+func (b *ChatBotImpl) Graph() (*ConversationGraph, error) {
+	if err := b.LoadMenus(); err != nil {
+		return nil, fmt.Errorf("failed to load menus: %w", err)
+	}
+
+	g := &ConversationGraph{}
+	seen := make(map[string]bool)
+	addNode := func(node GraphNode) {
+		if !seen[node.ID] {
+			seen[node.ID] = true
+			g.Nodes = append(g.Nodes, node)
+		}
+	}
+
+	var edges []GraphEdge
+	addLinks := func(from string, links []layerLink) {
+		for _, link := range links {
+			to := link.target
+			if to == "" {
+				to = "handler:" + handlerName(link.handler)
+			}
+			edges = append(edges, GraphEdge{From: from, To: to, Label: link.label})
+		}
+	}
+
+	if b.menus != nil {
+		b.menus.mu.RLock()
+		defer b.menus.mu.RUnlock()
+	}
+
+	b.defaultLayerMutex.RLock()
+	if b.defaultHandlerLayer != nil {
+		links := layerLinks(b.defaultHandlerLayer)
+		for i, link := range links {
+			if command, ok := b.menuCommand(link.label); ok {
+				links[i].target = screenNode(command.Screen)
+			}
+		}
+		addNode(GraphNode{ID: defaultLayerNode, Kind: GraphNodeLayer, Label: "default layer"})
+		addLinks(defaultLayerNode, links)
+	}
+	b.defaultLayerMutex.RUnlock()
+
+	if b.menus != nil {
+		for _, name := range slices.Sorted(maps.Keys(b.menus.screens)) {
+			screen := b.menus.screens[name]
+			addNode(GraphNode{ID: screenNode(name), Kind: GraphNodeScreen, Label: name, Text: firstLine(screen.Text)})
+			for _, button := range screen.Buttons {
+				edge := GraphEdge{From: screenNode(name), Label: button.Text}
+				switch {
+				case button.URL != "":
+					edge.To = "url:" + button.URL
+				case button.Handler != "":
+					edge.To = "handler:" + button.Handler
+				default:
+					edge.To = screenNode(button.Screen)
+				}
+				edges = append(edges, edge)
+			}
+		}
+	}
+
+	for _, edge := range edges {
+		kind, label, _ := strings.Cut(edge.To, ":")
+		switch kind {
+		case "screen":
+			addNode(GraphNode{ID: edge.To, Kind: GraphNodeScreen, Label: label})
+		case "url":
+			addNode(GraphNode{ID: edge.To, Kind: GraphNodeURL, Label: label})
+		default:
+			addNode(GraphNode{ID: edge.To, Kind: GraphNodeHandler, Label: label})
+		}
+	}
+	g.Edges = edges
+
+	return g, nil
+}
+
+// menuCommand is the WithMenus command behind a default-layer command, if
+// any; the caller holds the menus lock.
+func (b *ChatBotImpl) menuCommand(command string) (menuCommand, bool) {
+	if b.menus == nil {
+		return menuCommand{}, false
+	}
+	c, ok := b.menus.commands[command]
+
+	return c, ok
+}
+
+// layerLinks lists the commands, buttons and texts of layer, in the order
+// the user sees buttons and sorted otherwise.
+func layerLinks(layer *HandlerLayer) []layerLink {
+	var links []layerLink
+
+	for _, command := range slices.Sorted(maps.Keys(layer.commandHandler)) {
+		links = append(links, layerLink{label: command, handler: layer.commandHandler[command].handlerFunc})
+	}
+	for _, h := range layer.sortedButtonsSlice() {
+		links = append(links, layerLink{label: h.text, handler: h.handlerFunc})
+	}
+	for _, h := range layer.sortedIButtonsSlice() {
+		link := layerLink{label: h.button.Text, handler: h.handlerFunc}
+		if h.handlerFunc == nil {
+			if h.button.URL == nil {
+				continue
+			}
+			link.target = "url:" + *h.button.URL
+		}
+		links = append(links, link)
+	}
+	for _, route := range slices.Sorted(maps.Keys(layer.routeHandler)) {
+		links = append(links, layerLink{label: "route " + route, handler: layer.routeHandler[route]})
+	}
+	for _, text := range slices.Sorted(maps.Keys(layer.textHandler)) {
+		label := text
+		if text == AnyText {
+			label = "any text"
+		}
+		links = append(links, layerLink{label: label, handler: layer.textHandler[text].handlerFunc})
+	}
+	if layer.audioHandler != nil {
+		links = append(links, layerLink{label: "voice", handler: layer.audioHandler.handlerFunc})
+	}
+
+	return links
+}
+
+// handlerName is the Go name of h without its import path, e.g.
+// "main.settings" or "main.main.func2".
+func handlerName(h HandlerFunc) string {
+	if h == nil {
+		return "nil"
+	}
+	fn := runtime.FuncForPC(reflect.ValueOf(h).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	return strings.TrimSuffix(name, "-fm")
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	if runes := []rune(line); len(runes) > 40 {
+		line = string(runes[:39]) + "…"
+	}
+
+	return line
+}
+
+// nodeIndex numbers the nodes for the renderers, whose IDs must be plain.
+func (g *ConversationGraph) nodeIndex() map[string]string {
+	index := make(map[string]string, len(g.Nodes))
+	for i, node := range g.Nodes {
+		index[node.ID] = fmt.Sprintf("n%d", i)
+	}
+
+	return index
+}
+
+// DOT renders the graph in the Graphviz DOT language, e.g. for
+// "dot -Tsvg": layers as boxes, screens as rounded boxes, handlers as
+// ellipses and URLs as notes.
+func (g *ConversationGraph) DOT() string {
+	shapes := map[GraphNodeKind]string{
+		GraphNodeLayer:   "shape=box",
+		GraphNodeScreen:  `shape=box, style="rounded"`,
+		GraphNodeHandler: "shape=ellipse",
+		GraphNodeURL:     "shape=note",
+	}
+	quote := func(s string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+	}
+
+	index := g.nodeIndex()
+	var b strings.Builder
+	b.WriteString("digraph bot {\n\trankdir=LR;\n")
+	for _, node := range g.Nodes {
+		label := node.Label
+		if node.Text != "" {
+			label += "\n" + node.Text
+		}
+		fmt.Fprintf(&b, "\t%s [label=%s, %s];\n", index[node.ID], quote(label), shapes[node.Kind])
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "\t%s -> %s [label=%s];\n", index[edge.From], index[edge.To], quote(edge.Label))
+	}
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart, which GitHub and many
+// wikis display inline, with the node shapes of DOT.
+func (g *ConversationGraph) Mermaid() string {
+	shapes := map[GraphNodeKind][2]string{
+		GraphNodeLayer:   {"[", "]"},
+		GraphNodeScreen:  {"(", ")"},
+		GraphNodeHandler: {"([", "])"},
+		GraphNodeURL:     {">", "]"},
+	}
+	quote := func(s string) string {
+		return `"` + strings.NewReplacer(`"`, "#quot;", "\n", "<br/>").Replace(s) + `"`
+	}
+
+	index := g.nodeIndex()
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, node := range g.Nodes {
+		label := node.Label
+		if node.Text != "" {
+			label += "\n" + node.Text
+		}
+		shape := shapes[node.Kind]
+		fmt.Fprintf(&b, "\t%s%s%s%s\n", index[node.ID], shape[0], quote(label), shape[1])
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "\t%s -->|%s| %s\n", index[edge.From], quote(edge.Label), index[edge.To])
+	}
+
+	return b.String()
+}
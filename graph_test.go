@@ -0,0 +1,128 @@
+package bf
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func showHelp(context.Context, Event) error { return nil }
+
+// graphBot is configured but neither started nor loaded, like a bot whose
+// graph is exported from a test or a generator.
+func graphBot(t *testing.T) *ChatBotImpl {
+	t.Helper()
+	bot, _ := newTestBot()
+	WithMenus(fstest.MapFS{"help.yaml": {Data: []byte(helpMenu)}}, "*.yaml")(bot)
+	bot.RegisterMenuHandler("support", showHelp)
+	bot.RegisterCommand("/about", showHelp)
+	bot.defaultHandlerLayer.RegisterIButtonURL("Terms", "https://example.com/terms")
+
+	return bot
+}
+
+func graphOf(t *testing.T, bot *ChatBotImpl) *ConversationGraph {
+	t.Helper()
+	g, err := bot.Graph()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g
+}
+
+func TestGraph_NodesAndEdges(t *testing.T) {
+	g := graphOf(t, graphBot(t))
+
+	nodes := map[string]GraphNode{}
+	for _, node := range g.Nodes {
+		nodes[node.ID] = node
+	}
+	want := map[string]GraphNodeKind{
+		"default":                       GraphNodeLayer,
+		"screen:help":                   GraphNodeScreen,
+		"screen:faq":                    GraphNodeScreen,
+		"handler:support":               GraphNodeHandler,
+		"handler:bf.showHelp":           GraphNodeHandler,
+		"url:https://example.com/docs":  GraphNodeURL,
+		"url:https://example.com/terms": GraphNodeURL,
+	}
+	for id, kind := range want {
+		if nodes[id].Kind != kind {
+			t.Errorf("node %q: kind %q, want %q", id, nodes[id].Kind, kind)
+		}
+	}
+	if len(nodes) != len(want) {
+		t.Errorf("unexpected nodes: %+v", g.Nodes)
+	}
+	if nodes["screen:help"].Text != "How can we help?" {
+		t.Errorf("text %q", nodes["screen:help"].Text)
+	}
+
+	edges := map[string]bool{}
+	for _, e := range g.Edges {
+		edges[e.From+" -"+e.Label+"-> "+e.To] = true
+	}
+	for _, e := range []string{
+		"default -/about-> handler:bf.showHelp",
+		"default -/help-> screen:help",
+		"default -Terms-> url:https://example.com/terms",
+		"screen:help -FAQ-> screen:faq",
+		"screen:help -Talk to us-> handler:support",
+		"screen:faq -Home-> screen:help",
+	} {
+		if !edges[e] {
+			t.Errorf("missing edge %s in %v", e, edges)
+		}
+	}
+}
+
+func TestGraph_DOTAndMermaid(t *testing.T) {
+	g := graphOf(t, graphBot(t))
+
+	dot := g.DOT()
+	for _, want := range []string{
+		"digraph bot {\n\trankdir=LR;\n\tn0 [label=\"default layer\", shape=box];\n",
+		`[label="help\nHow can we help?", shape=box, style="rounded"]`,
+		`[label="bf.showHelp", shape=ellipse]`,
+		`n0 -> `,
+		`[label="/help"];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT lacks %q:\n%s", want, dot)
+		}
+	}
+
+	mermaid := g.Mermaid()
+	for _, want := range []string{
+		"flowchart LR\n\tn0[\"default layer\"]\n",
+		`("help<br/>How can we help?")`,
+		`(["support"])`,
+		`>"https://example.com/docs"]`,
+		`n0 -->|"/help"| `,
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("Mermaid lacks %q:\n%s", want, mermaid)
+		}
+	}
+}
+
+func TestGraph_BeforeStartAndAfterIt(t *testing.T) {
+	bot := graphBot(t)
+	first := graphOf(t, bot)
+	if err := bot.LoadMenus(); err != nil {
+		t.Fatalf("menus do not load again after Graph: %v", err)
+	}
+	if again := graphOf(t, bot); len(again.Edges) != len(first.Edges) {
+		t.Fatalf("edges changed across loads: %v, then %v", first.Edges, again.Edges)
+	}
+}
+
+func TestGraph_BadMenus(t *testing.T) {
+	bot, _ := newTestBot()
+	WithMenus(fstest.MapFS{"m.yaml": {Data: []byte("commands:\n  /go: nowhere")}}, "m.yaml")(bot)
+	if _, err := bot.Graph(); err == nil || !strings.Contains(err.Error(), `unknown screen "nowhere"`) {
+		t.Fatalf("want the menu error, got %v", err)
+	}
+}
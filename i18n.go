@@ -0,0 +1,464 @@
+package bf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pluralCategories are the CLDR plural categories a catalog message may
+// define forms for; "other" is required.
+var pluralCategories = []string{"zero", "one", "two", "few", "many", "other"}
+
+// catalogMessage is one translated message: a single text, or texts by
+// plural category.
+type catalogMessage struct {
+	text  string
+	forms map[string]string
+}
+
+// Catalog holds translated messages by locale, loaded with LoadCatalog.
+// It is immutable and safe for concurrent use.
+type Catalog struct {
+	fallback string
+	messages map[string]map[string]catalogMessage
+	// labels maps every translation of every message back to its keys, so
+	// localised reply-keyboard buttons match their handlers.
+	labels map[string][]string
+}
+
+// LoadCatalog reads message catalogs from the files of fsys matching the
+// patterns (fs.Glob syntax). Each file holds one locale, named after the
+// file: en.json, ru.yaml, pt-BR.yml. Files map keys to messages; nested
+// objects make dotted keys, and an object of CLDR plural categories (one,
+// few, many, other...) is a message with plural forms:
+//
+//	menu:
+//	  settings: Settings
+//	greeting: Hello, {name}!
+//	files:
+//	  one: "{count} file"
+//	  other: "{count} files"
+//
+// fallback is the locale used for users whose language has no catalog and
+// for keys a catalog lacks; it must be among the files. A key that exists in
+// another locale but not in fallback is reported as an error, as is a plural
+// message without an "other" form.
+func LoadCatalog(fsys fs.FS, fallback string, patterns ...string) (*Catalog, error) {
+	c := &Catalog{
+		fallback: normalizeLocale(fallback),
+		messages: make(map[string]map[string]catalogMessage),
+		labels:   make(map[string][]string),
+	}
+
+	for _, pattern := range patterns {
+		names, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to match catalogs %q: %w", pattern, err)
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("catalog pattern %q matches no files", pattern)
+		}
+		for _, name := range names {
+			if err := c.loadFile(fsys, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	for _, messages := range c.messages {
+		for key, msg := range messages {
+			for _, label := range msg.texts() {
+				if !slices.Contains(c.labels[label], key) {
+					c.labels[label] = append(c.labels[label], key)
+				}
+			}
+		}
+	}
+
+	return c, nil
+}
+
+func (c *Catalog) loadFile(fsys fs.FS, name string) error {
+	raw, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return fmt.Errorf("failed to read catalog %q: %w", name, err)
+	}
+
+	var tree map[string]any
+	switch ext := path.Ext(name); ext {
+	case ".json":
+		err = json.Unmarshal(raw, &tree)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, &tree)
+	default:
+		return fmt.Errorf("catalog %q: unsupported format %q, want .json, .yaml or .yml", name, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode catalog %q: %w", name, err)
+	}
+
+	locale := normalizeLocale(strings.TrimSuffix(path.Base(name), path.Ext(name)))
+	if c.messages[locale] == nil {
+		c.messages[locale] = make(map[string]catalogMessage)
+	}
+	if err := flattenCatalog(c.messages[locale], "", tree); err != nil {
+		return fmt.Errorf("catalog %q: %w", name, err)
+	}
+
+	return nil
+}
+
+// flattenCatalog adds the messages of tree to messages under prefix.
+func flattenCatalog(messages map[string]catalogMessage, prefix string, tree map[string]any) error {
+	for key, value := range tree {
+		key = prefix + key
+		switch v := value.(type) {
+		case string:
+			messages[key] = catalogMessage{text: v}
+		case map[string]any:
+			if forms, ok := pluralForms(v); ok {
+				if _, ok := forms["other"]; !ok {
+					return fmt.Errorf("plural message %q has no \"other\" form", key)
+				}
+				messages[key] = catalogMessage{forms: forms}
+				continue
+			}
+			if err := flattenCatalog(messages, key+".", v); err != nil {
+				return err
+			}
+		case nil:
+			return fmt.Errorf("message %q is empty", key)
+		default:
+			messages[key] = catalogMessage{text: fmt.Sprint(v)}
+		}
+	}
+
+	return nil
+}
+
+// pluralForms reports whether tree is a plural message: only plural
+// categories mapping to texts.
+func pluralForms(tree map[string]any) (map[string]string, bool) {
+	forms := make(map[string]string, len(tree))
+	for category, value := range tree {
+		text, ok := value.(string)
+		if !ok || !slices.Contains(pluralCategories, category) {
+			return nil, false
+		}
+		forms[category] = text
+	}
+
+	return forms, len(forms) > 0
+}
+
+func (c *Catalog) check() error {
+	fallback, ok := c.messages[c.fallback]
+	if !ok {
+		return fmt.Errorf("fallback locale %q has no catalog", c.fallback)
+	}
+
+	var extra []string
+	for locale, messages := range c.messages {
+		for key := range messages {
+			if _, ok := fallback[key]; !ok {
+				extra = append(extra, fmt.Sprintf("%s: %q", locale, key))
+			}
+		}
+	}
+	if len(extra) > 0 {
+		slices.Sort(extra)
+		return fmt.Errorf("keys missing from fallback locale %q: %s", c.fallback, strings.Join(extra, ", "))
+	}
+
+	return nil
+}
+
+func (m catalogMessage) texts() []string {
+	if m.forms == nil {
+		return []string{m.text}
+	}
+	texts := make([]string, 0, len(m.forms))
+	for _, text := range m.forms {
+		texts = append(texts, text)
+	}
+
+	return texts
+}
+
+// Locales returns the locales of the catalog, sorted.
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.messages))
+	for locale := range c.messages {
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+
+	return locales
+}
+
+// Has reports whether key is a message of the catalog.
+func (c *Catalog) Has(key string) bool {
+	_, ok := c.messages[c.fallback][key]
+	return ok
+}
+
+// Match returns the catalog locale for a Telegram language code such as
+// "pt-br" or "ru": the exact locale, else its base language, else fallback.
+func (c *Catalog) Match(languageCode string) string {
+	locale := normalizeLocale(languageCode)
+	if _, ok := c.messages[locale]; ok {
+		return locale
+	}
+	if base, _, ok := strings.Cut(locale, "-"); ok {
+		if _, ok := c.messages[base]; ok {
+			return base
+		}
+	}
+
+	return c.fallback
+}
+
+// Translate returns the message key in locale, with {name} placeholders
+// replaced by args, given as name/value pairs. The "count" argument selects
+// the plural form. A key missing from locale is taken from the fallback
+// locale; an unknown key is returned as is.
+func (c *Catalog) Translate(locale, key string, args ...any) string {
+	locale = c.Match(locale)
+	msg, ok := c.messages[locale][key]
+	if !ok {
+		if msg, ok = c.messages[c.fallback][key]; !ok {
+			return key
+		}
+		locale = c.fallback
+	}
+
+	values := make(map[string]string, len(args)/2)
+	for i := 0; i+1 < len(args); i += 2 {
+		values[fmt.Sprint(args[i])] = fmt.Sprint(args[i+1])
+	}
+
+	text := msg.text
+	if msg.forms != nil {
+		text = msg.forms["other"]
+		if count, err := strconv.ParseInt(values["count"], 10, 64); err == nil {
+			if form, ok := msg.forms[pluralCategory(locale, count)]; ok {
+				text = form
+			}
+		}
+	}
+
+	return replacePlaceholders(text, values)
+}
+
+func replacePlaceholders(text string, values map[string]string) string {
+	if len(values) == 0 || !strings.Contains(text, "{") {
+		return text
+	}
+
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(text, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(text[open:], '}')
+		if end < 0 {
+			break
+		}
+		value, ok := values[text[open+1:open+end]]
+		if !ok {
+			value = text[open : open+end+1]
+		}
+		b.WriteString(text[:open])
+		b.WriteString(value)
+		text = text[open+end+1:]
+	}
+	b.WriteString(text)
+
+	return b.String()
+}
+
+// keysForLabel returns the keys of the messages that translate to label.
+func (c *Catalog) keysForLabel(label string) []string {
+	return c.labels[label]
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+}
+
+// pluralCategory is the CLDR plural category of the integer n in locale, for
+// the languages bots most often speak; others use the English rule.
+func pluralCategory(locale string, n int64) string {
+	lang, _, _ := strings.Cut(locale, "-")
+	if n < 0 {
+		n = -n
+	}
+	mod10, mod100 := n%10, n%100
+	few := mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14)
+
+	switch lang {
+	case "ru", "uk", "be":
+		return [...]string{"one", "few", "many"}[slavicPluralForm(n)]
+	case "pl":
+		switch {
+		case n == 1:
+			return "one"
+		case few:
+			return "few"
+		}
+		return "many"
+	case "cs", "sk":
+		switch {
+		case n == 1:
+			return "one"
+		case n >= 2 && n <= 4:
+			return "few"
+		}
+		return "other"
+	case "fr":
+		if n == 0 || n == 1 {
+			return "one"
+		}
+		return "other"
+	case "ja", "zh", "ko", "vi", "th", "id", "ms":
+		return "other"
+	}
+
+	if n == 1 {
+		return "one"
+	}
+
+	return "other"
+}
+
+// localeKey is the context key of the localizer set for each event.
+type localeKey struct{}
+
+type localizer struct {
+	catalog *Catalog
+	locale  string
+}
+
+// T translates key into the locale of the event being handled, see
+// Catalog.Translate. Without WithCatalog it returns key.
+func T(ctx context.Context, key string, args ...any) string {
+	l, ok := ctx.Value(localeKey{}).(localizer)
+	if !ok {
+		return key
+	}
+
+	return l.catalog.Translate(l.locale, key, args...)
+}
+
+// Locale returns the locale of the event being handled, or "" without
+// WithCatalog.
+func Locale(ctx context.Context) string {
+	l, _ := ctx.Value(localeKey{}).(localizer)
+	return l.locale
+}
+
+// WithLocale returns ctx translating into locale, e.g. right after
+// SetUserLocale, whose change otherwise applies from the next event.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	l, ok := ctx.Value(localeKey{}).(localizer)
+	if !ok {
+		return ctx
+	}
+	l.locale = l.catalog.Match(locale)
+
+	return context.WithValue(ctx, localeKey{}, l)
+}
+
+// WithCatalog translates the bot with catalog: handlers get the user's
+// locale in ctx for T, and button labels that are catalog keys are shown
+// translated and matched in every locale. A user's locale is their stored
+// preference (SetUserLocale), else their Telegram language, else the
+// catalog's fallback. Labels follow the locale of the event a layer answers
+// (Reply, PushLayer, Form, Conversation); SendMsg uses the fallback.
+func WithCatalog(catalog *Catalog) BotOption {
+	return func(bot *ChatBotImpl) {
+		bot.catalog = catalog
+	}
+}
+
+// SetUserLocale stores userID's preferred locale in the SessionStore. It
+// applies from the user's next event; an empty locale clears it.
+func (b *ChatBotImpl) SetUserLocale(ctx context.Context, userID int64, locale string) error {
+	if b.catalog == nil {
+		return errors.New("no catalog configured; see WithCatalog")
+	}
+
+	var err error
+	if locale == "" {
+		err = b.sessionStore.Delete(ctx, localeStoreKey(userID))
+	} else {
+		err = b.sessionStore.Set(ctx, localeStoreKey(userID), []byte(b.catalog.Match(locale)))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store locale: %w", err)
+	}
+
+	return nil
+}
+
+func localeStoreKey(userID int64) string {
+	return "locale:" + strconv.FormatInt(userID, 10)
+}
+
+// localize resolves the event's locale, records it on the event and adds it
+// to ctx.
+func (b *ChatBotImpl) localize(ctx context.Context, event *Event) context.Context {
+	if b.catalog == nil {
+		return ctx
+	}
+
+	locale := b.catalog.Match(event.LanguageCode)
+	if event.UserTGID != 0 {
+		stored, ok, err := b.sessionStore.Get(ctx, localeStoreKey(event.UserTGID))
+		switch {
+		case err != nil:
+			b.logger.Errorf("failed to load locale of user %d: %s", event.UserTGID, err)
+		case ok:
+			locale = b.catalog.Match(string(stored))
+		}
+	}
+
+	event.locale = locale
+	if event.Kind == EventKindText {
+		event.labelKeys = b.catalog.keysForLabel(event.Text)
+	}
+
+	return context.WithValue(ctx, localeKey{}, localizer{catalog: b.catalog, locale: locale})
+}
+
+// labelTranslator returns the function translating button labels that are
+// catalog keys into locale; "" is the catalog's fallback.
+func (b *ChatBotImpl) labelTranslator(locale string) func(string) string {
+	if b.catalog == nil {
+		return func(label string) string { return label }
+	}
+
+	if locale == "" {
+		locale = b.catalog.fallback
+	}
+
+	return func(label string) string {
+		if !b.catalog.Has(label) {
+			return label
+		}
+		return b.catalog.Translate(locale, label)
+	}
+}
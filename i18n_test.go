@@ -0,0 +1,270 @@
+package bf
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func catalogFS() fstest.MapFS {
+	return fstest.MapFS{
+		"locales/en.json": {Data: []byte(`{
+			"greeting": "Hello, {name}!",
+			"menu": {"settings": "Settings", "back": "Back"},
+			"files": {"one": "{count} file", "other": "{count} files"}
+		}`)},
+		"locales/ru.yaml": {Data: []byte(`
+greeting: Привет, {name}!
+menu:
+  settings: Настройки
+files:
+  one: "{count} файл"
+  few: "{count} файла"
+  many: "{count} файлов"
+  other: "{count} файла"
+`)},
+	}
+}
+
+func testCatalog(t *testing.T) *Catalog {
+	t.Helper()
+	c, err := LoadCatalog(catalogFS(), "en", "locales/*.json", "locales/*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func textUpdate(chatID int64, text, languageCode string) tgbotapi.Update {
+	return tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			Text: text,
+			Chat: &tgbotapi.Chat{ID: chatID},
+			From: &tgbotapi.User{ID: 7, LanguageCode: languageCode},
+		},
+	}
+}
+
+func TestCatalog_Translate(t *testing.T) {
+	c := testCatalog(t)
+	if got := c.Locales(); strings.Join(got, ",") != "en,ru" {
+		t.Fatalf("locales %q", got)
+	}
+
+	tests := []struct {
+		locale, key string
+		args        []any
+		want        string
+	}{
+		{"en", "greeting", []any{"name", "Ann"}, "Hello, Ann!"},
+		{"ru", "greeting", []any{"name", "Аня"}, "Привет, Аня!"},
+		{"ru-RU", "menu.settings", nil, "Настройки"},
+		{"ru", "menu.back", nil, "Back"},
+		{"de", "menu.settings", nil, "Settings"},
+		{"en", "nope", nil, "nope"},
+		{"en", "files", []any{"count", 1}, "1 file"},
+		{"en", "files", []any{"count", 0}, "0 files"},
+		{"ru", "files", []any{"count", 1}, "1 файл"},
+		{"ru", "files", []any{"count", 3}, "3 файла"},
+		{"ru", "files", []any{"count", 11}, "11 файлов"},
+		{"ru", "files", []any{"count", 21}, "21 файл"},
+		{"ru", "files", nil, "{count} файла"},
+	}
+	for _, tt := range tests {
+		if got := c.Translate(tt.locale, tt.key, tt.args...); got != tt.want {
+			t.Errorf("Translate(%q, %q, %v) = %q, want %q", tt.locale, tt.key, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestPluralCategory(t *testing.T) {
+	tests := []struct {
+		locale string
+		n      int64
+		want   string
+	}{
+		{"en", 1, "one"}, {"en", 2, "other"},
+		{"pl", 1, "one"}, {"pl", 3, "few"}, {"pl", 13, "many"}, {"pl", 22, "few"},
+		{"cs", 4, "few"}, {"cs", 5, "other"},
+		{"fr", 0, "one"}, {"fr", 2, "other"},
+		{"ja", 1, "other"},
+		{"uk", 112, "many"},
+	}
+	for _, tt := range tests {
+		if got := pluralCategory(tt.locale, tt.n); got != tt.want {
+			t.Errorf("pluralCategory(%q, %d) = %q, want %q", tt.locale, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestLoadCatalog_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{"no fallback", fstest.MapFS{"ru.json": {Data: []byte(`{"a": "б"}`)}}, `fallback locale "en" has no catalog`},
+		{"extra key", fstest.MapFS{
+			"en.json": {Data: []byte(`{"a": "b"}`)},
+			"ru.json": {Data: []byte(`{"a": "б", "c": "д"}`)},
+		}, `keys missing from fallback locale "en": ru: "c"`},
+		{"no other", fstest.MapFS{"en.json": {Data: []byte(`{"n": {"one": "x"}}`)}},
+			`plural message "n" has no "other" form`},
+		{"bad json", fstest.MapFS{"en.json": {Data: []byte(`{`)}}, `failed to decode catalog "en.json"`},
+		{"format", fstest.MapFS{"en.toml": {Data: []byte(``)}}, `unsupported format ".toml"`},
+	}
+	for _, tt := range tests {
+		_, err := LoadCatalog(tt.files, "en", "*.*")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: want %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestCatalog_TInHandlerFollowsLanguageAndPreference(t *testing.T) {
+	bot, mock := newTestBot()
+	WithCatalog(testCatalog(t))(bot)
+	bot.RegisterCommand("/start", func(ctx context.Context, event Event) error {
+		return bot.SendMsg(event.ChatID, bot.NewLayer(T(ctx, "greeting", "name", event.FirstName)))
+	})
+	c := newChatController(context.Background())
+
+	update := cmdUpdate(42, "/start")
+	update.Message.From = &tgbotapi.User{ID: 7, FirstName: "Ann", LanguageCode: "ru"}
+	bot.handleUpdate(context.Background(), c, update)
+	if got := lastText(t, mock); got != "Привет, Ann!" {
+		t.Fatalf("got %q", got)
+	}
+
+	// A stored preference beats the Telegram language.
+	if err := bot.SetUserLocale(context.Background(), 7, "en-GB"); err != nil {
+		t.Fatal(err)
+	}
+	bot.handleUpdate(context.Background(), c, update)
+	if got := lastText(t, mock); got != "Hello, Ann!" {
+		t.Fatalf("got %q", got)
+	}
+
+	if err := bot.SetUserLocale(context.Background(), 7, ""); err != nil {
+		t.Fatal(err)
+	}
+	bot.handleUpdate(context.Background(), c, update)
+	if got := lastText(t, mock); got != "Привет, Ann!" {
+		t.Fatalf("preference not cleared: %q", got)
+	}
+}
+
+func TestCatalog_ButtonLabelsAreTranslatedAndMatched(t *testing.T) {
+	bot, mock := newTestBot()
+	WithCatalog(testCatalog(t))(bot)
+
+	var tapped int
+	menu := bot.NewLayer("menu")
+	menu.RegisterButton("menu.settings", func(context.Context, Event) error {
+		tapped++
+		return nil
+	})
+	bot.RegisterCommand("/menu", func(_ context.Context, event Event) error {
+		return bot.Reply(event, menu)
+	})
+	c := newChatController(context.Background())
+
+	update := cmdUpdate(42, "/menu")
+	update.Message.From = &tgbotapi.User{ID: 7, LanguageCode: "ru"}
+	bot.handleUpdate(context.Background(), c, update)
+	markup := mock.lastSent().(tgbotapi.MessageConfig).ReplyMarkup.(tgbotapi.ReplyKeyboardMarkup)
+	if got := markup.Keyboard[0][0].Text; got != "Настройки" {
+		t.Fatalf("label %q", got)
+	}
+
+	bot.handleUpdate(context.Background(), c, textUpdate(42, "Настройки", "ru"))
+	if tapped != 1 {
+		t.Fatal("translated label did not match the button")
+	}
+
+	update.Message.From.LanguageCode = "en"
+	bot.handleUpdate(context.Background(), c, update)
+	markup = mock.lastSent().(tgbotapi.MessageConfig).ReplyMarkup.(tgbotapi.ReplyKeyboardMarkup)
+	if got := markup.Keyboard[0][0].Text; got != "Settings" {
+		t.Fatalf("label %q", got)
+	}
+	bot.handleUpdate(context.Background(), c, textUpdate(42, "Settings", "en"))
+	if tapped != 2 {
+		t.Fatal("English label did not match the button")
+	}
+}
+
+// countingStore counts the Gets of a MemorySessionStore.
+type countingStore struct {
+	SessionStore
+	gets atomic.Int32
+}
+
+func (s *countingStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.gets.Add(1)
+	return s.SessionStore.Get(ctx, key)
+}
+
+func TestCatalog_LabelsFollowTheHandledEvent(t *testing.T) {
+	bot, mock := newTestBot()
+	WithCatalog(testCatalog(t))(bot)
+	store := &countingStore{SessionStore: NewMemorySessionStore()}
+	bot.sessionStore = store
+
+	menu := bot.NewLayer("menu")
+	menu.RegisterButton("menu.settings", func(context.Context, Event) error { return nil })
+	release := make(chan struct{})
+	bot.RegisterDefaultHandler(func(_ context.Context, event Event) error {
+		if event.UserTGID == 1 {
+			<-release
+		}
+		return bot.Reply(event, menu)
+	})
+	c := newChatController(context.Background())
+	from := func(userID int64, lang string) tgbotapi.Update {
+		u := groupUpdate(userID, "hi")
+		u.Message.From.LanguageCode = lang
+		return u
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bot.handleUpdate(context.Background(), c, from(1, "en"))
+	}()
+	waitGets := time.Now().Add(time.Second)
+	for store.gets.Load() == 0 && time.Now().Before(waitGets) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Another member speaks Russian meanwhile, and user 1 is dropped as busy.
+	bot.handleUpdate(context.Background(), c, from(2, "ru"))
+	bot.handleUpdate(context.Background(), c, from(1, "ru"))
+	if got := store.gets.Load(); got != 2 {
+		t.Fatalf("want the locale loaded for handled events only, got %d loads", got)
+	}
+
+	close(release)
+	waitDone(t, done)
+	markup := mock.lastSent().(tgbotapi.MessageConfig).ReplyMarkup.(tgbotapi.ReplyKeyboardMarkup)
+	if got := markup.Keyboard[0][0].Text; got != "Settings" {
+		t.Fatalf("user 1 got label %q", got)
+	}
+}
+
+func TestT_WithoutCatalog(t *testing.T) {
+	ctx := context.Background()
+	if T(ctx, "greeting") != "greeting" || Locale(ctx) != "" || WithLocale(ctx, "ru") != ctx {
+		t.Fatal("T must return the key without a catalog")
+	}
+
+	bot, _ := newTestBot()
+	if err := bot.SetUserLocale(ctx, 7, "ru"); err == nil {
+		t.Fatal("want an error without WithCatalog")
+	}
+}
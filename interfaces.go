@@ -15,28 +15,70 @@ type ChatBot interface {
 	Stop()
 
 	// SendMsg renders the layer (text + buttons), sends it and installs
-	// the layer as the next-message expectation for chatID.
+	// the layer as the next-message expectation for chatID. In forum
+	// supergroups it posts to the general topic unless the layer is scoped
+	// to a topic (ScopeThread).
 	SendMsg(chatID int64, layer *HandlerLayer) error
+	// Reply is SendMsg to the chat and forum topic of event.
+	Reply(event Event, layer *HandlerLayer) error
+
+	// EditMsg replaces an earlier message's text and inline keyboard with the
+	// layer and installs the layer for chatID.
+	EditMsg(chatID int64, messageID int, layer *HandlerLayer) error
+
+	// PushLayer shows layer as a new screen on the chat's navigation stack,
+	// adding an automatic Back button on nested screens.
+	PushLayer(event Event, layer *HandlerLayer) error
+	// PopLayer returns to the previous screen of the navigation stack.
+	PopLayer(event Event) error
+	// ResetNavigation forgets the navigation stacks of the chat and its members.
+	ResetNavigation(chatID int64)
+	// ShowMenu pushes a WithMenus screen onto the chat's navigation stack.
+	ShowMenu(ctx context.Context, event Event, screen string) error
+	// RegisterMenuHandler binds a handler named in WithMenus files.
+	RegisterMenuHandler(name string, handler HandlerFunc)
+	// Routes lists the default layer's commands and buttons.
+	Routes() []Route
+	// HelpHandler replies with the visible commands and buttons of Routes.
+	HelpHandler(ctx context.Context, event Event) error
 
 	// SendText sends a one-off plain text message without affecting any layer.
+	// Like SendMsg, it posts to the general topic of a forum.
 	SendText(chatID int64, text string) error
+	// ReplyText is SendText to the chat and forum topic of event.
+	ReplyText(event Event, text string) error
 
 	// RegisterDefaultHandler installs the fallback handler for the default layer.
 	RegisterDefaultHandler(handler HandlerFunc)
 	// RegisterCommand binds a slash command to a handler on the default layer.
-	RegisterCommand(command string, handler HandlerFunc)
+	RegisterCommand(command string, handler HandlerFunc, opts ...RouteOption)
 	// RegisterIButton adds an inline-keyboard button on the default layer.
-	RegisterIButton(btn string, handler HandlerFunc)
+	RegisterIButton(btn string, handler HandlerFunc, opts ...RouteOption)
+	// RegisterIButtonRoute serves payload buttons of a route on the default layer.
+	RegisterIButtonRoute(route string, handler HandlerFunc)
 	// RegisterButton adds a reply-keyboard button on the default layer.
-	RegisterButton(btn string, handler HandlerFunc)
+	RegisterButton(btn string, handler HandlerFunc, opts ...RouteOption)
 	// RegisterAudio binds a voice-message handler on the default layer.
 	RegisterAudio(handler HandlerFunc)
+	// RegisterForumTopic binds a forum topic change handler on the default layer.
+	RegisterForumTopic(handler HandlerFunc)
+	// RegisterTopic installs a layer serving one forum topic of a chat.
+	RegisterTopic(chatID int64, threadID int, layer *HandlerLayer)
 
 	// RegisterMiddleware appends a middleware applied to every handler.
 	RegisterMiddleware(middleware MiddlewareFunc)
 
 	// NewLayer constructs a fresh layer carrying optional message text.
 	NewLayer(msgText ...any) *HandlerLayer
+	// NewTemplateLayer constructs a layer whose text is a rendered template.
+	NewTemplateLayer(name string, data any) (*HandlerLayer, error)
+	// RenderTemplate executes a WithTemplates template with data.
+	RenderTemplate(name string, data any) (string, error)
+	// SetUserLocale stores a user's preferred WithCatalog locale.
+	SetUserLocale(ctx context.Context, userID int64, locale string) error
+
+	// Conversation starts a blocking, linear dialog with the event's chat.
+	Conversation(event Event) *Conversation
 
 	// RetryLastLayer re-sends the layer that was active during the previous
 	// message in the chat, optionally overriding the layer text.
@@ -50,6 +92,12 @@ type ChatBot interface {
 
 	// LoaderButton sends an animated placeholder; cancel via the returned func.
 	LoaderButton(chatID int64, loadScreen []string) context.CancelFunc
+	// Progress sends a progress-bar placeholder, replaced by Progress.Done.
+	Progress(ctx context.Context, chatID int64, title string) (*Progress, error)
+	// ProgressAction repeats a chat action ("typing") until Progress.Done.
+	ProgressAction(ctx context.Context, chatID int64, action string) *Progress
+	// StreamText returns an io.WriteCloser showing written text as it grows.
+	StreamText(chatID int64) *TextStream
 
 	// GetFileURL resolves a Telegram fileID to a directly downloadable URL.
 	GetFileURL(fileID string) (string, error)
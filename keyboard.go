@@ -0,0 +1,164 @@
+package bf
+
+import (
+	"slices"
+	"unicode/utf8"
+)
+
+const (
+	// maxInlineRowButtons is the most buttons Telegram shows in one inline
+	// keyboard row.
+	maxInlineRowButtons = 8
+	// defaultFitWidth is the label budget per row for autofit layouts.
+	defaultFitWidth = 24
+)
+
+// keyboardLayout arranges a keyboard's buttons, in registration order, into
+// rows. Explicit row sizes win; otherwise the buttons between row breaks are
+// laid out in a fixed number of columns, fitted by label width, or with the
+// keyboard's default shape.
+type keyboardLayout struct {
+	rows     []int
+	breaks   []int
+	columns  int
+	fitWidth int
+}
+
+func (l keyboardLayout) clone() keyboardLayout {
+	l.rows = slices.Clone(l.rows)
+	l.breaks = slices.Clone(l.breaks)
+
+	return l
+}
+
+// isSet reports whether anything beyond the default shape was requested.
+func (l keyboardLayout) isSet() bool {
+	return len(l.rows) > 0 || len(l.breaks) > 0 || l.columns > 0 || l.fitWidth > 0
+}
+
+// rowSizes returns the size of each row for buttons with the given labels.
+// oneRow selects the default shape: every button in one row (reply
+// keyboards) or one button per row (inline keyboards). maxPerRow caps
+// columns and autofit rows; zero means no cap.
+func (l keyboardLayout) rowSizes(labels []string, oneRow bool, maxPerRow int) []int {
+	if len(l.rows) > 0 {
+		return l.rows
+	}
+
+	sizes := make([]int, 0, len(labels))
+	start := 0
+	for _, end := range append(slices.Clone(l.breaks), len(labels)) {
+		if end <= start || end > len(labels) {
+			continue
+		}
+		sizes = append(sizes, l.segmentRows(labels[start:end], oneRow, maxPerRow)...)
+		start = end
+	}
+
+	return sizes
+}
+
+func (l keyboardLayout) segmentRows(labels []string, oneRow bool, maxPerRow int) []int {
+	switch {
+	case l.columns > 0:
+		columns := l.columns
+		if maxPerRow > 0 {
+			columns = min(columns, maxPerRow)
+		}
+		return chunkSizes(len(labels), columns)
+	case l.fitWidth > 0:
+		return fitRows(labels, l.fitWidth, maxPerRow)
+	case oneRow:
+		return []int{len(labels)}
+	}
+
+	return chunkSizes(len(labels), 1)
+}
+
+// chunkSizes splits n buttons into rows of size, the last row taking the rest.
+func chunkSizes(n, size int) []int {
+	sizes := make([]int, 0, n/size+1)
+	for ; n > 0; n -= size {
+		sizes = append(sizes, min(n, size))
+	}
+
+	return sizes
+}
+
+// fitRows packs buttons into rows while the labels' total length stays within
+// width (a row always takes at least one button) and the row has fewer than
+// maxPerRow buttons.
+func fitRows(labels []string, width, maxPerRow int) []int {
+	var sizes []int
+	count, used := 0, 0
+	for _, label := range labels {
+		w := utf8.RuneCountInString(label)
+		if count > 0 && (used+w > width || (maxPerRow > 0 && count >= maxPerRow)) {
+			sizes = append(sizes, count)
+			count, used = 0, 0
+		}
+		count++
+		used += w
+	}
+	if count > 0 {
+		sizes = append(sizes, count)
+	}
+
+	return sizes
+}
+
+// SetIButtonColumns lays the inline buttons out in a grid of n columns
+// (at most 8, Telegram's row limit), in registration order.
+func (hl *HandlerLayer) SetIButtonColumns(n int) {
+	hl.inlineLayout.columns = max(0, n)
+}
+
+// SetIButtonAutoFit packs inline buttons into rows by label length: a row
+// takes buttons while their labels total at most width characters (24 when
+// width is not positive), so short labels share a row and long ones get
+// their own.
+func (hl *HandlerLayer) SetIButtonAutoFit(width int) {
+	if width <= 0 {
+		width = defaultFitWidth
+	}
+	hl.inlineLayout.fitWidth = width
+}
+
+// SetIButtonRows gives the number of inline buttons in each row, in order.
+// Buttons beyond the listed rows get a row each. It overrides columns,
+// autofit and row breaks.
+func (hl *HandlerLayer) SetIButtonRows(sizes ...int) {
+	hl.inlineLayout.rows = sizes
+}
+
+// IButtonRowBreak ends the current inline row: the next registered inline
+// button starts a new one. Columns and autofit apply within each block.
+func (hl *HandlerLayer) IButtonRowBreak() {
+	hl.inlineLayout.breaks = append(hl.inlineLayout.breaks, len(hl.buttonHandler))
+}
+
+// SetButtonColumns lays the reply-keyboard buttons out in a grid of n
+// columns instead of the default single row.
+func (hl *HandlerLayer) SetButtonColumns(n int) {
+	hl.replyLayout.columns = max(0, n)
+}
+
+// SetButtonAutoFit packs reply-keyboard buttons into rows by label length,
+// like SetIButtonAutoFit.
+func (hl *HandlerLayer) SetButtonAutoFit(width int) {
+	if width <= 0 {
+		width = defaultFitWidth
+	}
+	hl.replyLayout.fitWidth = width
+}
+
+// SetButtonRows gives the number of reply-keyboard buttons in each row.
+func (hl *HandlerLayer) SetButtonRows(sizes ...int) {
+	hl.replyLayout.rows = sizes
+}
+
+// ButtonRowBreak ends the current reply-keyboard row: the next registered
+// reply button starts a new one.
+func (hl *HandlerLayer) ButtonRowBreak() {
+	hl.replyLayout.breaks = append(hl.replyLayout.breaks, len(hl.buttonTextHandler))
+}
@@ -0,0 +1,137 @@
+package bf
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func inlineShape(t *testing.T, bot *ChatBotImpl, layer *HandlerLayer) []int {
+	t.Helper()
+	markup, err := bot.layerMarkup(recipient{}, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shape []int
+	for _, row := range markup.(tgbotapi.InlineKeyboardMarkup).InlineKeyboard {
+		shape = append(shape, len(row))
+	}
+	return shape
+}
+
+func replyShape(t *testing.T, bot *ChatBotImpl, layer *HandlerLayer) []int {
+	t.Helper()
+	markup, err := bot.layerMarkup(recipient{}, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shape []int
+	for _, row := range markup.(tgbotapi.ReplyKeyboardMarkup).Keyboard {
+		shape = append(shape, len(row))
+	}
+	return shape
+}
+
+func addIButtons(layer *HandlerLayer, labels ...string) {
+	for _, label := range labels {
+		layer.RegisterIButton(label, func(context.Context, Event) error { return nil })
+	}
+}
+
+func TestKeyboardLayout_InlineColumnsAndBreaks(t *testing.T) {
+	bot, _ := newTestBot()
+
+	layer := bot.NewLayer("Grid")
+	layer.SetIButtonColumns(3)
+	addIButtons(layer, "1", "2", "3", "4", "5", "6", "7")
+	layer.IButtonRowBreak()
+	addIButtons(layer, "Cancel")
+
+	if got := inlineShape(t, bot, layer); !slices.Equal(got, []int{3, 3, 1, 1}) {
+		t.Fatalf("shape: %v", got)
+	}
+
+	markup, _ := bot.layerMarkup(recipient{}, layer)
+	kb := markup.(tgbotapi.InlineKeyboardMarkup).InlineKeyboard
+	if kb[0][0].Text != "1" || kb[1][2].Text != "6" || kb[3][0].Text != "Cancel" {
+		t.Fatalf("registration order not preserved: %v", kb)
+	}
+}
+
+func TestKeyboardLayout_InlineColumnsCappedAtTelegramLimit(t *testing.T) {
+	bot, _ := newTestBot()
+
+	layer := bot.NewLayer("Wide")
+	layer.SetIButtonColumns(20)
+	addIButtons(layer, "a", "b", "c", "d", "e", "f", "g", "h", "i", "j")
+
+	if got := inlineShape(t, bot, layer); !slices.Equal(got, []int{8, 2}) {
+		t.Fatalf("shape: %v", got)
+	}
+}
+
+func TestKeyboardLayout_AutoFitByLabelWidth(t *testing.T) {
+	bot, _ := newTestBot()
+
+	layer := bot.NewLayer("Fit")
+	layer.SetIButtonAutoFit(10)
+	addIButtons(layer, "Yes", "No", "Maybe", "A very long label", "OK", "Go")
+
+	// Yes+No+Maybe = 10; the long label exceeds the budget alone.
+	if got := inlineShape(t, bot, layer); !slices.Equal(got, []int{3, 1, 2}) {
+		t.Fatalf("shape: %v", got)
+	}
+}
+
+func TestKeyboardLayout_ExplicitRowsOverrideAndDefaults(t *testing.T) {
+	bot, _ := newTestBot()
+
+	layer := bot.NewLayer("Rows")
+	layer.SetIButtonColumns(2)
+	layer.SetIButtonRows(1, 3)
+	addIButtons(layer, "a", "b", "c", "d", "e")
+	if got := inlineShape(t, bot, layer); !slices.Equal(got, []int{1, 3, 1}) {
+		t.Fatalf("explicit rows: %v", got)
+	}
+
+	plain := bot.NewLayer("Default")
+	addIButtons(plain, "a", "b")
+	if got := inlineShape(t, bot, plain); !slices.Equal(got, []int{1, 1}) {
+		t.Fatalf("default inline: %v", got)
+	}
+}
+
+func TestKeyboardLayout_ReplyKeyboard(t *testing.T) {
+	bot, _ := newTestBot()
+	noop := func(context.Context, Event) error { return nil }
+
+	plain := bot.NewLayer("Default")
+	for _, label := range []string{"a", "b", "c"} {
+		plain.RegisterButton(label, noop)
+	}
+	if got := replyShape(t, bot, plain); !slices.Equal(got, []int{3}) {
+		t.Fatalf("default reply keyboard must stay one row, got %v", got)
+	}
+
+	grid := bot.NewLayer("Grid")
+	grid.SetButtonColumns(2)
+	for _, label := range []string{"1", "2", "3", "4", "5"} {
+		grid.RegisterButton(label, noop)
+	}
+	grid.ButtonRowBreak()
+	grid.RegisterButton("Back", noop)
+	if got := replyShape(t, bot, grid); !slices.Equal(got, []int{2, 2, 1, 1}) {
+		t.Fatalf("reply grid: %v", got)
+	}
+
+	rows := bot.NewLayer("Rows")
+	rows.SetButtonRows(2)
+	for _, label := range []string{"x", "y", "z"} {
+		rows.RegisterButton(label, noop)
+	}
+	if got := replyShape(t, bot, rows); !slices.Equal(got, []int{2, 1}) {
+		t.Fatalf("reply rows: %v", got)
+	}
+}
@@ -1,6 +1,9 @@
 package bf
 
 import (
+	"fmt"
+	"maps"
+	"slices"
 	"sort"
 	"time"
 
@@ -16,11 +19,20 @@ import (
 //  2. The bot's default layer, used whenever no chat-specific layer is set.
 //     The default layer is never wiped automatically.
 //
+// A layer's inline buttons also stay bound to the message that carries
+// them, so taps on older messages reach their handlers for
+// WithMessageLayerTTL without disturbing the chat's current layer; only a
+// chat's latest 100 keyboard messages are kept.
+//
 // HandlerLayer itself is not goroutine-safe; mutate it from one goroutine,
 // then hand it off to SendMsg. The bot's default layer mutations are
 // guarded internally by ChatBotImpl.
 type HandlerLayer struct {
 	text string
+	// entities format text in ModeEntities; parseMode is the bot's parse
+	// mode, used to render AddRichText. See richtext.go.
+	entities  []tgbotapi.MessageEntity
+	parseMode string
 
 	commandHandler map[string]CommandHandler
 	// textHandler matches incoming text messages — including reply-keyboard
@@ -32,12 +44,32 @@ type HandlerLayer struct {
 	// former and we log a warning rather than silently merging via one map.
 	buttonTextHandler map[string]TextHandler
 	buttonHandler     map[string]InlineButtonHandler
-	audioHandler      *AudioHandler
+	// routeHandler serves inline buttons by CallbackPayload route when no
+	// button with the exact callback data is registered.
+	routeHandler map[string]HandlerFunc
+	audioHandler *AudioHandler
+	// forumTopicHandler serves EventKindForumTopic; see RegisterForumTopic.
+	forumTopicHandler HandlerFunc
 
 	layerDefaultHandler HandlerFunc
 
 	ttl     time.Time
 	rowMode bool
+	// sticky layers survive the events they receive; see SetSticky.
+	sticky bool
+	// inlineLayout and replyLayout arrange the buttons into rows; see
+	// SetIButtonColumns and friends in keyboard.go.
+	inlineLayout keyboardLayout
+	replyLayout  keyboardLayout
+	// keyboardOptions overrides the bot's ReplyKeyboardOptions when set;
+	// removeKeyboard sends ReplyKeyboardRemove. See replykb.go.
+	keyboardOptions *ReplyKeyboardOptions
+	removeKeyboard  bool
+	// scope selects the layer slot within the chat; see LayerScope.
+	scope LayerScope
+	// forceReply sends the layer as a ForceReply prompt matched by reply
+	// instead of by chat; see SetForceReply.
+	forceReply *tgbotapi.ForceReply
 }
 
 // Handler returns the HandlerFunc that should process the given event,
@@ -49,6 +81,12 @@ func (hl *HandlerLayer) Handler(event Event) HandlerFunc {
 		if h, ok := hl.buttonTextHandler[event.Text]; ok {
 			return h.handlerFunc
 		}
+		// Buttons registered by catalog key match their translations.
+		for _, key := range event.labelKeys {
+			if h, ok := hl.buttonTextHandler[key]; ok {
+				return h.handlerFunc
+			}
+		}
 		if h, ok := hl.textHandler[event.Text]; ok {
 			return h.handlerFunc
 		}
@@ -60,18 +98,72 @@ func (hl *HandlerLayer) Handler(event Event) HandlerFunc {
 			return h.handlerFunc
 		}
 	case EventKindInlineButton:
-		if h, ok := hl.buttonHandler[event.Button]; ok {
-			return h.handlerFunc
+		if h := hl.inlineHandler(event); h != nil {
+			return h
 		}
 	case EventKindVoice:
 		if hl.audioHandler != nil {
 			return hl.audioHandler.handlerFunc
 		}
+	case EventKindContact:
+		if h, ok := hl.requestButtonHandler(TextHandlerKindContact); ok {
+			return h.handlerFunc
+		}
+	case EventKindLocation:
+		if h, ok := hl.requestButtonHandler(TextHandlerKindLocation); ok {
+			return h.handlerFunc
+		}
+	case EventKindForumTopic:
+		if hl.forumTopicHandler != nil {
+			return hl.forumTopicHandler
+		}
 	}
 
 	return hl.layerDefaultHandler
 }
 
+// inlineHandler returns the layer's handler for an inline-button event,
+// ignoring the layer's default handler.
+func (hl *HandlerLayer) inlineHandler(event Event) HandlerFunc {
+	if h, ok := hl.buttonHandler[event.Button]; ok && h.handlerFunc != nil {
+		return h.handlerFunc
+	}
+	if event.Payload != nil {
+		return hl.routeHandler[event.Payload.Route]
+	}
+
+	return nil
+}
+
+// requestButtonHandler finds the reply-keyboard button of the given request
+// kind. Telegram does not echo the label of a contact/location button, so the
+// first button of that kind handles the shared payload.
+func (hl *HandlerLayer) requestButtonHandler(kind TextHandlerKind) (TextHandler, bool) {
+	for _, h := range hl.sortedButtonsSlice() {
+		if h.kind == kind {
+			return h, true
+		}
+	}
+
+	return TextHandler{}, false
+}
+
+// clone returns a deep copy of the layer's handler maps, so the copy can be
+// extended (e.g. with a Back button) without touching the original.
+func (hl *HandlerLayer) clone() *HandlerLayer {
+	c := *hl
+	c.commandHandler = maps.Clone(hl.commandHandler)
+	c.textHandler = maps.Clone(hl.textHandler)
+	c.buttonTextHandler = maps.Clone(hl.buttonTextHandler)
+	c.buttonHandler = maps.Clone(hl.buttonHandler)
+	c.routeHandler = maps.Clone(hl.routeHandler)
+	c.entities = slices.Clip(hl.entities)
+	c.inlineLayout = hl.inlineLayout.clone()
+	c.replyLayout = hl.replyLayout.clone()
+
+	return &c
+}
+
 // IsExpired reports whether the layer's TTL has elapsed.
 func (hl *HandlerLayer) IsExpired() bool {
 	return time.Now().After(hl.ttl)
@@ -83,15 +175,30 @@ func (hl *HandlerLayer) IsEmpty() bool {
 		len(hl.buttonTextHandler) == 0 &&
 		len(hl.commandHandler) == 0 &&
 		len(hl.buttonHandler) == 0 &&
+		len(hl.routeHandler) == 0 &&
 		hl.audioHandler == nil &&
 		hl.layerDefaultHandler == nil
 }
 
+// hasCallbackButtons reports whether the layer renders any inline button
+// that sends a callback (as opposed to URL buttons).
+func (hl *HandlerLayer) hasCallbackButtons() bool {
+	for _, h := range hl.buttonHandler {
+		if h.button.CallbackData != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
 // InlineButtonHandler is one inline-keyboard button bound to a handler.
 type InlineButtonHandler struct {
 	handlerFunc HandlerFunc
 	orderWeight int
 	button      tgbotapi.InlineKeyboardButton
+	// meta describes default-layer handlers for Routes; see routes.go.
+	meta routeMeta
 }
 
 // TextHandlerKind discriminates plain text matches from reply-keyboard buttons.
@@ -99,8 +206,10 @@ type TextHandlerKind string
 
 // Text-handler kinds and the wildcard text token.
 const (
-	TextHandlerKindText   TextHandlerKind = "text"
-	TextHandlerKindButton TextHandlerKind = "button"
+	TextHandlerKindText     TextHandlerKind = "text"
+	TextHandlerKindButton   TextHandlerKind = "button"
+	TextHandlerKindContact  TextHandlerKind = "contact"
+	TextHandlerKindLocation TextHandlerKind = "location"
 	// AnyText is the wildcard text-handler key: when registered, it matches
 	// any incoming text message that has no exact handler.
 	AnyText = "*"
@@ -112,6 +221,8 @@ type TextHandler struct {
 	handlerFunc HandlerFunc
 	kind        TextHandlerKind
 	orderWeight int
+	// meta describes default-layer handlers for Routes; see routes.go.
+	meta routeMeta
 }
 
 // AudioHandler matches voice messages.
@@ -122,6 +233,8 @@ type AudioHandler struct {
 // CommandHandler matches a slash command (e.g. "/start").
 type CommandHandler struct {
 	handlerFunc HandlerFunc
+	// meta describes default-layer handlers for Routes; see routes.go.
+	meta routeMeta
 }
 
 // RegisterCommand binds a handler to a slash command (must include the slash).
@@ -151,9 +264,46 @@ func (hl *HandlerLayer) RegisterButton(text string, handler HandlerFunc) {
 	}
 }
 
+// RegisterButtonContact adds a reply-keyboard button that asks the user to
+// share their phone contact. handler receives an EventKindContact event.
+func (hl *HandlerLayer) RegisterButtonContact(text string, handler HandlerFunc) {
+	hl.buttonTextHandler[text] = TextHandler{
+		text:        text,
+		handlerFunc: handler,
+		kind:        TextHandlerKindContact,
+		orderWeight: len(hl.buttonTextHandler),
+	}
+}
+
+// RegisterButtonLocation adds a reply-keyboard button that asks the user to
+// share their location. handler receives an EventKindLocation event.
+func (hl *HandlerLayer) RegisterButtonLocation(text string, handler HandlerFunc) {
+	hl.buttonTextHandler[text] = TextHandler{
+		text:        text,
+		handlerFunc: handler,
+		kind:        TextHandlerKindLocation,
+		orderWeight: len(hl.buttonTextHandler),
+	}
+}
+
 // RegisterIButton adds an inline-keyboard button with a callback handler.
 func (hl *HandlerLayer) RegisterIButton(text string, handler HandlerFunc) {
+	hl.registerIButton(text, handler)
+}
+
+// registerIButton is RegisterIButton returning the callback id, for widgets
+// that render the button again (registerIButtonID).
+func (hl *HandlerLayer) registerIButton(text string, handler HandlerFunc) string {
 	id := uuid.NewString()
+	hl.registerIButtonID(id, text, handler)
+
+	return id
+}
+
+// registerIButtonID adds an inline button with the given callback id, so a
+// widget can render a fresh layer whose buttons keep the ids of the one
+// already sent.
+func (hl *HandlerLayer) registerIButtonID(id, text string, handler HandlerFunc) {
 	hl.buttonHandler[id] = InlineButtonHandler{
 		button:      tgbotapi.NewInlineKeyboardButtonData(text, id),
 		handlerFunc: handler,
@@ -161,6 +311,42 @@ func (hl *HandlerLayer) RegisterIButton(text string, handler HandlerFunc) {
 	}
 }
 
+// RegisterIButtonData adds an inline-keyboard button whose callback data is
+// the encoded payload rather than an opaque id; the handler receives it
+// decoded as Event.Payload. handler may be nil when a route handler
+// (RegisterIButtonRoute) serves the payload's route.
+//
+// Returns an error when the payload does not encode (see
+// CallbackPayload.Encode) or another button of the layer already carries
+// the same payload.
+func (hl *HandlerLayer) RegisterIButtonData(text string, payload CallbackPayload, handler HandlerFunc) error {
+	data, err := payload.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to register inline button %q: %w", text, err)
+	}
+	if _, ok := hl.buttonHandler[data]; ok {
+		return fmt.Errorf("failed to register inline button %q: payload %q is already used", text, data)
+	}
+
+	hl.buttonHandler[data] = InlineButtonHandler{
+		button:      tgbotapi.NewInlineKeyboardButtonData(text, data),
+		handlerFunc: handler,
+		orderWeight: len(hl.buttonHandler),
+	}
+
+	return nil
+}
+
+// RegisterIButtonRoute binds a handler to every inline button whose payload
+// has the given route, e.g. "item" for buttons built with
+// Payload("item", id). Exact buttons of the layer take precedence.
+func (hl *HandlerLayer) RegisterIButtonRoute(route string, handler HandlerFunc) {
+	if hl.routeHandler == nil {
+		hl.routeHandler = make(map[string]HandlerFunc)
+	}
+	hl.routeHandler[route] = handler
+}
+
 // RegisterIButtonURL adds an inline-keyboard button that opens a URL.
 // No handler is invoked when the user taps it.
 func (hl *HandlerLayer) RegisterIButtonURL(text, url string) {
@@ -193,6 +379,26 @@ func (hl *HandlerLayer) AddText(text string) {
 	hl.text += "\n" + text
 }
 
+// AddRichText appends formatted text as a line, like AddText, rendered for
+// the bot's parse mode. Use it for texts that include user input.
+func (hl *HandlerLayer) AddRichText(text *RichText) {
+	if hl.parseMode != ModeEntities {
+		hl.AddText(text.Render(hl.parseMode))
+		return
+	}
+
+	plain, entities := text.Entities()
+	if hl.text != "" {
+		hl.text += "\n"
+	}
+	offset := utf16Len(hl.text)
+	hl.text += plain
+	for _, e := range entities {
+		e.Offset += offset
+		hl.entities = append(hl.entities, e)
+	}
+}
+
 func (hl *HandlerLayer) sortedIButtonsSlice() []InlineButtonHandler {
 	res := make([]InlineButtonHandler, 0, len(hl.buttonHandler))
 	for _, v := range hl.buttonHandler {
@@ -206,6 +412,19 @@ func (hl *HandlerLayer) sortedIButtonsSlice() []InlineButtonHandler {
 	return res
 }
 
+// keyboardButton renders the reply-keyboard button for this handler.
+func (th TextHandler) keyboardButton() tgbotapi.KeyboardButton {
+	switch th.kind {
+	case TextHandlerKindContact:
+		return tgbotapi.NewKeyboardButtonContact(th.text)
+	case TextHandlerKindLocation:
+		return tgbotapi.NewKeyboardButtonLocation(th.text)
+	case TextHandlerKindText, TextHandlerKindButton:
+	}
+
+	return tgbotapi.NewKeyboardButton(th.text)
+}
+
 func (hl *HandlerLayer) sortedButtonsSlice() []TextHandler {
 	res := make([]TextHandler, 0, len(hl.buttonTextHandler))
 	for _, v := range hl.buttonTextHandler {
@@ -225,6 +444,30 @@ func (hl *HandlerLayer) SetIButtonRowMode() {
 	hl.rowMode = true
 }
 
+// SetSticky keeps the layer installed after it handles an event, instead of
+// the default one-shot behaviour. Widgets whose buttons are tapped several
+// times on the same message (toggles, pickers) use it. A sticky layer stays
+// until the next SendMsg/EditMsg for the chat replaces it or its TTL expires.
+func (hl *HandlerLayer) SetSticky() {
+	hl.sticky = true
+}
+
+// SetForceReply sends the layer's message with ForceReply: the user's client
+// opens a reply to it, showing placeholder in the input field. Use it for
+// prompts in groups, where privacy mode hides every message except replies
+// to the bot.
+//
+// A ForceReply layer is matched by the reply's reply_to_message instead of
+// being installed as the chat layer, so prompts sent to several group members
+// are answered independently. It is one-shot and must not have buttons.
+func (hl *HandlerLayer) SetForceReply(placeholder string) {
+	hl.forceReply = &tgbotapi.ForceReply{
+		ForceReply:            true,
+		InputFieldPlaceholder: placeholder,
+		Selective:             true,
+	}
+}
+
 // RegisterVoice binds a handler to incoming voice messages on this layer.
 func (hl *HandlerLayer) RegisterVoice(handler HandlerFunc) {
 	hl.audioHandler = &AudioHandler{handlerFunc: handler}
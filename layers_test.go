@@ -93,6 +93,28 @@ func TestLayer_DispatchVoice(t *testing.T) {
 	}
 }
 
+func TestLayer_RequestButtonsMatchSharedPayload(t *testing.T) {
+	l := newEmptyLayer()
+	hit := ""
+	l.RegisterButton("plain", func(_ context.Context, _ Event) error { hit = "plain"; return nil })
+	l.RegisterButtonContact("Phone", func(_ context.Context, _ Event) error { hit = "contact"; return nil })
+	l.RegisterButtonLocation("Where", func(_ context.Context, _ Event) error { hit = "location"; return nil })
+
+	_ = l.Handler(Event{Kind: EventKindContact})(context.Background(), Event{})
+	if hit != "contact" {
+		t.Fatalf("want contact handler, got %q", hit)
+	}
+	_ = l.Handler(Event{Kind: EventKindLocation})(context.Background(), Event{})
+	if hit != "location" {
+		t.Fatalf("want location handler, got %q", hit)
+	}
+
+	buttons := l.sortedButtonsSlice()
+	if !buttons[1].keyboardButton().RequestContact || !buttons[2].keyboardButton().RequestLocation {
+		t.Fatalf("request flags not rendered: %+v", buttons)
+	}
+}
+
 func TestLayer_FallbackToDefault(t *testing.T) {
 	l := newEmptyLayer()
 	l.layerDefaultHandler = func(_ context.Context, _ Event) error { return nil }
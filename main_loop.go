@@ -39,12 +39,37 @@ func chatControllerLockTTL() time.Duration {
 // channel cannot spawn an unbounded number of goroutines.
 const defaultUpdateConcurrency = 256
 
-// chatController serialises message processing per chat: while one update from
-// a given chat is being handled, subsequent updates from the same chat are
-// dropped. This prevents handler interleaving for the same user.
+// chatController serialises message processing per lock (see lockKey): per
+// chat in private chats, per member in groups. While one update under a lock
+// is being handled, subsequent updates under the same lock are dropped, so
+// handlers never interleave for the same user; two members of a group are
+// handled concurrently. State that belongs to one conversation (Form answers,
+// navigation stacks, shown reply keyboards) is kept per member accordingly.
+//
+// A handler blocked in Conversation.Ask keeps the chat lock but parks it on
+// its prompt layer: the next update that resolves to that layer is handed off
+// past the lock. A parked lock is never swept, and its handler gives its
+// dispatcher slot back while it waits.
 type chatController struct {
-	userInWork map[int64]time.Time
+	userInWork map[scopeKey]time.Time
+	parked     map[scopeKey]*HandlerLayer
 	mux        *sync.Mutex
+	// slots is mainLoop's worker semaphore, nil when updates are handled
+	// directly.
+	slots chan struct{}
+}
+
+// chatControllerKey is the context key under which handleUpdate exposes the
+// active chatController to handlers (see Conversation.Ask).
+type chatControllerKey struct{}
+
+func withChatController(ctx context.Context, c chatController) context.Context {
+	return context.WithValue(ctx, chatControllerKey{}, c)
+}
+
+func chatControllerFromContext(ctx context.Context) (chatController, bool) {
+	c, ok := ctx.Value(chatControllerKey{}).(chatController)
+	return c, ok
 }
 
 // cleanOld evicts stale chat locks. Stops when ctx is cancelled.
@@ -60,6 +85,9 @@ func (c chatController) cleanOld(ctx context.Context) {
 			ttl := chatControllerLockTTL()
 			c.mux.Lock()
 			for userID, lastTime := range c.userInWork {
+				if _, parked := c.parked[userID]; parked {
+					continue
+				}
 				if lastTime.Add(ttl).Before(time.Now()) {
 					delete(c.userInWork, userID)
 				}
@@ -72,26 +100,79 @@ func (c chatController) cleanOld(ctx context.Context) {
 // tryAcquire returns true if the caller acquired the per-chat lock and should
 // proceed; false means another goroutine is already processing that chat and
 // the caller should drop the update. Callers that get true must call release.
-func (c chatController) tryAcquire(chatID int64) bool {
+func (c chatController) tryAcquire(key scopeKey) bool {
 	c.mux.Lock()
 	defer c.mux.Unlock()
 
-	if _, ok := c.userInWork[chatID]; ok {
+	if _, ok := c.userInWork[key]; ok {
 		return false
 	}
-	c.userInWork[chatID] = time.Now()
+	c.userInWork[key] = time.Now()
 	return true
 }
 
-func (c chatController) release(chatID int64) {
+func (c chatController) release(key scopeKey) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	delete(c.userInWork, key)
+}
+
+// park marks the lock as waiting for an update to the prompt layer. The lock
+// holder keeps the lock, which the sweeper skips while parked, and frees its
+// dispatcher slot so waiting conversations cannot saturate the dispatcher.
+// Every park must be followed by unpark.
+func (c chatController) park(key scopeKey, prompt *HandlerLayer) {
+	c.mux.Lock()
+	c.parked[key] = prompt
+	if _, ok := c.userInWork[key]; ok {
+		c.userInWork[key] = time.Now()
+	}
+	c.mux.Unlock()
+
+	if c.slots != nil {
+		<-c.slots
+	}
+}
+
+// unpark clears the park and takes a dispatcher slot again, waiting for one
+// if the dispatcher is busy.
+func (c chatController) unpark(key scopeKey) {
+	c.mux.Lock()
+	delete(c.parked, key)
+	if _, ok := c.userInWork[key]; ok {
+		c.userInWork[key] = time.Now()
+	}
+	c.mux.Unlock()
+
+	if c.slots != nil {
+		c.slots <- struct{}{}
+	}
+}
+
+// parkedLayer returns the prompt layer the lock is parked on, or nil.
+func (c chatController) parkedLayer(key scopeKey) *HandlerLayer {
 	c.mux.Lock()
 	defer c.mux.Unlock()
-	delete(c.userInWork, chatID)
+	return c.parked[key]
+}
+
+// claimParked reports whether the lock is parked on prompt and clears the
+// mark, so only one update per park is handed off past the lock. The caller
+// must not call release: the lock still belongs to the parked goroutine.
+func (c chatController) claimParked(key scopeKey, prompt *HandlerLayer) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if prompt == nil || c.parked[key] != prompt {
+		return false
+	}
+	delete(c.parked, key)
+	return true
 }
 
 func newChatController(ctx context.Context) chatController {
 	blocker := chatController{
-		userInWork: make(map[int64]time.Time),
+		userInWork: make(map[scopeKey]time.Time),
+		parked:     make(map[scopeKey]*HandlerLayer),
 		mux:        &sync.Mutex{},
 	}
 	go blocker.cleanOld(ctx)
@@ -106,13 +187,14 @@ func (b *ChatBotImpl) mainLoop(ctx context.Context, updates tgbotapi.UpdatesChan
 	loopCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	control := newChatController(loopCtx)
-
 	// Bounded worker concurrency. We do not block in the producer loop —
 	// instead we drop the update with a log when the semaphore is full,
 	// keeping mainLoop responsive to ctx cancellation.
 	sem := make(chan struct{}, b.updateConcurrency)
 
+	control := newChatController(loopCtx)
+	control.slots = sem
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -121,11 +203,14 @@ func (b *ChatBotImpl) mainLoop(ctx context.Context, updates tgbotapi.UpdatesChan
 			if !ok {
 				return nil
 			}
+			// Read the event here rather than in the worker, so the topic
+			// fields of a dropped update are taken as well.
+			event, parsed := b.readEventSafely(loopCtx, update)
 			select {
 			case sem <- struct{}{}:
 				go func(u tgbotapi.Update) {
 					defer func() { <-sem }()
-					b.handleUpdate(loopCtx, control, u)
+					b.handleEvent(loopCtx, control, u, event, parsed)
 				}(update)
 			default:
 				b.logger.Warnf("dispatcher saturated; dropping update")
@@ -135,20 +220,51 @@ func (b *ChatBotImpl) mainLoop(ctx context.Context, updates tgbotapi.UpdatesChan
 }
 
 func (b *ChatBotImpl) handleUpdate(ctx context.Context, control chatController, update tgbotapi.Update) {
+	event, ok := b.readEvent(update)
+	b.handleEvent(ctx, control, update, event, ok)
+}
+
+// readEvent parses update and adds its forum topic fields; ok is false for
+// updates that carry no event.
+func (b *ChatBotImpl) readEvent(update tgbotapi.Update) (Event, bool) {
+	event, ok := newEvent(update)
+	b.applyTopic(&event, update)
+
+	return event, ok
+}
+
+// readEventSafely is readEvent for the dispatcher loop: a panic while
+// parsing is reported like a handler panic, and the update is dropped.
+func (b *ChatBotImpl) readEventSafely(ctx context.Context, update tgbotapi.Update) (event Event, ok bool) {
 	defer func() {
 		if r := recover(); r != nil {
-			err := fmt.Errorf("handler panic: %v", r)
-			b.logger.Errorf("recovered from panic in handler: %v\n%s", r, debug.Stack())
-			// Best-effort report to the registered error handler. The event we
-			// recovered with may itself be partially populated.
-			if eh := b.getErrorHandler(); eh != nil {
-				ev, _ := newEvent(update)
-				eh(ctx, ev, err)
-			}
+			b.reportPanic(ctx, Event{}, fmt.Errorf("update parsing panic: %v", r))
+			event, ok = Event{}, false
+		}
+	}()
+
+	return b.readEvent(update)
+}
+
+// reportPanic logs a recovered panic and reports it to the error handler.
+func (b *ChatBotImpl) reportPanic(ctx context.Context, event Event, err error) {
+	b.logger.Errorf("recovered from %s\n%s", err, debug.Stack())
+	// Best-effort report to the registered error handler.
+	if eh := b.getErrorHandler(); eh != nil {
+		eh(ctx, event, err)
+	}
+}
+
+// handleEvent routes the event read from update to its handler.
+func (b *ChatBotImpl) handleEvent(
+	ctx context.Context, control chatController, update tgbotapi.Update, event Event, ok bool,
+) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.reportPanic(ctx, event, fmt.Errorf("handler panic: %v", r))
 		}
 	}()
 
-	event, ok := newEvent(update)
 	if !ok {
 		// We deliberately do not call errorHandler here: the event is empty,
 		// so chatID is zero and there is nothing meaningful to send back.
@@ -157,14 +273,32 @@ func (b *ChatBotImpl) handleUpdate(ctx context.Context, control chatController,
 	}
 
 	b.logger.Debugf("got event: %#v", event)
+	ctx = withThread(ctx, event.ThreadID)
 
-	if !control.tryAcquire(event.ChatID) {
-		b.logger.Debugf("skip event (chat busy): %#v", event)
+	if err := b.authenticateCallback(&event); err != nil {
+		b.logger.Warnf("rejected inline button from chat %d: %s", event.ChatID, err)
+		if eh := b.getErrorHandler(); eh != nil {
+			eh(ctx, event, err)
+		}
+		return
+	}
+
+	lock := lockKey(event)
+	if control.tryAcquire(lock) {
+		defer control.release(lock)
+	} else if !b.handOff(ctx, control, lock, event) {
+		return
+	}
+	ctx = withLock(ctx, lock)
+
+	ctx = b.localize(ctx, &event)
+
+	if b.isStaleCallback(event) {
+		b.handleStaleCallback(ctx, event)
 		return
 	}
-	defer control.release(event.ChatID)
 
-	layer := b.findAndWipeChatLayerHandler(event.ChatID)
+	layer := b.layerForEvent(event)
 	b.logger.Debugf("got layer: %#v", layer)
 	event.lastLayer = layer
 
@@ -178,9 +312,29 @@ func (b *ChatBotImpl) handleUpdate(ctx context.Context, control chatController,
 		return
 	}
 
+	ctx = withChatController(ctx, control)
 	if err := b.applyMiddlewares(handlerFunc)(ctx, event); err != nil {
 		if eh := b.getErrorHandler(); eh != nil {
 			eh(ctx, event, err)
 		}
 	}
 }
+
+// handOff reports whether event may pass the lock held by a parked
+// conversation: only when it resolves to the prompt layer the conversation
+// waits on. A stale button is answered without using up the park; any other
+// event is dropped as "chat busy".
+func (b *ChatBotImpl) handOff(ctx context.Context, control chatController, lock scopeKey, event Event) bool {
+	if prompt := control.parkedLayer(lock); prompt != nil {
+		if b.isStaleCallback(event) {
+			b.handleStaleCallback(ctx, event)
+			return false
+		}
+		if b.peekLayerForEvent(event) == prompt && control.claimParked(lock, prompt) {
+			return true
+		}
+	}
+
+	b.logger.Debugf("skip event (chat busy): %#v", event)
+	return false
+}
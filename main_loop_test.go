@@ -2,6 +2,7 @@ package bf
 
 import (
 	"context"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -13,14 +14,14 @@ import (
 func TestChatController_TryAcquireRelease(t *testing.T) {
 	c := newChatController(context.Background())
 
-	if !c.tryAcquire(1) {
+	if !c.tryAcquire(chatKey(1)) {
 		t.Fatal("first acquire must succeed")
 	}
-	if c.tryAcquire(1) {
+	if c.tryAcquire(chatKey(1)) {
 		t.Fatal("second acquire on the same chat must report busy")
 	}
-	c.release(1)
-	if !c.tryAcquire(1) {
+	c.release(chatKey(1))
+	if !c.tryAcquire(chatKey(1)) {
 		t.Fatal("after release, acquire must succeed again")
 	}
 }
@@ -34,10 +35,10 @@ func TestChatController_Concurrent(t *testing.T) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if c.tryAcquire(42) {
+			if c.tryAcquire(chatKey(42)) {
 				atomic.AddInt64(&locks, 1)
 				time.Sleep(10 * time.Millisecond)
-				c.release(42)
+				c.release(chatKey(42))
 			}
 		}()
 	}
@@ -131,10 +132,56 @@ func TestMainLoop_DropsUnparseableUpdate(t *testing.T) {
 	}
 }
 
+// panickyTopics fails to read the topic of update 1.
+type panickyTopics struct{ *mockTelegramAPI }
+
+func (p panickyTopics) takeTopic(updateID int) (updateTopic, bool) {
+	if updateID == 1 {
+		panic("bad topic")
+	}
+	return p.mockTelegramAPI.takeTopic(updateID)
+}
+
+func TestMainLoop_ParsePanicReachesErrorHandler(t *testing.T) {
+	bot, mock := newTestBot()
+	bot.tgbot = panickyTopics{mock}
+
+	var errs atomic.Int32
+	bot.RegisterErrorHandler(func(_ context.Context, _ Event, err error) {
+		if strings.Contains(err.Error(), "bad topic") {
+			errs.Add(1)
+		}
+	})
+	var hit atomic.Int32
+	bot.RegisterCommand("/ping", func(context.Context, Event) error { hit.Add(1); return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = bot.mainLoop(ctx, mock.updates) }()
+
+	for id := 1; id <= 2; id++ {
+		mock.updates <- tgbotapi.Update{UpdateID: id, Message: &tgbotapi.Message{
+			Text:     "/ping",
+			Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 5}},
+			Chat:     &tgbotapi.Chat{ID: 1},
+			From:     &tgbotapi.User{ID: 1},
+		}}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for hit.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if errs.Load() != 1 || hit.Load() != 1 {
+		t.Fatalf("want the panic reported and the next update handled, got %d errors, %d hits",
+			errs.Load(), hit.Load())
+	}
+}
+
 func TestHandleUpdate_BusyChatSkipped(t *testing.T) {
 	bot, _ := newTestBot()
 	c := newChatController(context.Background())
-	c.tryAcquire(1)
+	c.tryAcquire(chatKey(1))
 
 	var hit atomic.Int32
 	bot.RegisterCommand("/x", func(_ context.Context, _ Event) error { hit.Add(1); return nil })
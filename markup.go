@@ -0,0 +1,232 @@
+package bf
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// htmlTags are the tags Telegram's HTML parse mode accepts.
+var htmlTags = map[string]bool{
+	"b": true, "strong": true, "i": true, "em": true, "u": true, "ins": true,
+	"s": true, "strike": true, "del": true, "span": true, "tg-spoiler": true,
+	"a": true, "code": true, "pre": true, "blockquote": true, "tg-emoji": true,
+}
+
+// markdownV2Reserved must be escaped in MarkdownV2 wherever they are not
+// markup.
+const markdownV2Reserved = "_*[]()~`>#+-=|{}.!"
+
+// ValidateText checks text against Telegram's rules for parseMode before it
+// is sent: in HTML, supported and balanced tags and known character
+// references; in MarkdownV2, closed entities and escaped reserved
+// characters. It reports the first problem found, wrapping ErrInvalidMarkup.
+// Other parse modes are not checked.
+//
+// The check is conservative: text it accepts may still be refused by
+// Telegram, but text it refuses would be.
+func ValidateText(text, parseMode string) error {
+	switch parseMode {
+	case tgbotapi.ModeHTML:
+		return validateHTML(text)
+	case tgbotapi.ModeMarkdownV2:
+		return validateMarkdownV2(text)
+	}
+
+	return nil
+}
+
+func markupError(offset int, format string, args ...any) error {
+	return fmt.Errorf("%w at byte %d: %s", ErrInvalidMarkup, offset, fmt.Sprintf(format, args...))
+}
+
+func validateHTML(text string) error {
+	var (
+		open   []string
+		starts []int
+		offset int
+	)
+	for _, tok := range tokenizeHTML(text) {
+		at := offset
+		offset += len(tok.text)
+
+		switch {
+		case tok.text == "<":
+			return markupError(at, `unescaped "<", use &lt;`)
+		case strings.HasPrefix(tok.text, "&") && len(tok.text) > 1:
+			if !validCharRef(tok.text) {
+				return markupError(at, "unsupported character reference %s", tok.text)
+			}
+		case !strings.HasPrefix(tok.text, "<"):
+		case strings.HasPrefix(tok.text, "</"):
+			name := htmlTagName(tok.text)
+			if len(open) == 0 || open[len(open)-1] != name {
+				return markupError(at, "unexpected closing tag %s", tok.text)
+			}
+			open, starts = open[:len(open)-1], starts[:len(starts)-1]
+		default:
+			name := htmlTagName(tok.text)
+			if !htmlTags[name] {
+				return markupError(at, "unsupported tag %s", tok.text)
+			}
+			if !strings.HasSuffix(tok.text, "/>") {
+				open, starts = append(open, name), append(starts, at)
+			}
+		}
+	}
+
+	if len(open) > 0 {
+		return markupError(starts[len(starts)-1], "tag <%s> is not closed", open[len(open)-1])
+	}
+
+	return nil
+}
+
+// validCharRef reports whether ref, "&...;", is a character reference
+// Telegram accepts: numeric, or one of four named ones. Anything else that
+// does not look like a reference is left to be shown as text.
+func validCharRef(ref string) bool {
+	name := strings.TrimSuffix(strings.TrimPrefix(ref, "&"), ";")
+	if name == "" || strings.ContainsAny(name, " \t\n&<>") {
+		return true
+	}
+	switch name {
+	case "lt", "gt", "amp", "quot":
+		return true
+	}
+	if num, ok := strings.CutPrefix(name, "#"); ok {
+		hex, isHex := strings.CutPrefix(strings.ToLower(num), "x")
+		digits := "0123456789"
+		if isHex {
+			num, digits = hex, "0123456789abcdef"
+		}
+		return num != "" && strings.Trim(num, digits) == ""
+	}
+
+	return false
+}
+
+func validateMarkdownV2(text string) error {
+	var (
+		offset int
+		starts = map[string]int{}
+	)
+	tokens := tokenizeMarkdown(text, true)
+	for i, tok := range tokens {
+		at := offset
+		offset += len(tok.text)
+
+		var before []string
+		if i > 0 {
+			before = tokens[i-1].open
+		}
+		for _, delim := range tok.open {
+			if !slices.Contains(before, delim) {
+				starts[delim] = at
+			}
+		}
+
+		if tok.units == 0 || len(tok.text) != 1 || inMarkdownCode(before) {
+			continue
+		}
+		if !strings.Contains(markdownV2Reserved, tok.text) {
+			continue
+		}
+		// A line-leading ">" starts a block quote.
+		if tok.text == ">" && (at == 0 || text[at-1] == '\n') {
+			continue
+		}
+		return markupError(at, `reserved character %q must be escaped as "\%s"`, tok.text, tok.text)
+	}
+
+	if len(tokens) > 0 {
+		if open := tokens[len(tokens)-1].open; len(open) > 0 {
+			delim := open[len(open)-1]
+			return markupError(starts[delim], "entity %q is not closed", delim)
+		}
+	}
+
+	return nil
+}
+
+func inMarkdownCode(open []string) bool {
+	return len(open) > 0 && (open[len(open)-1] == "`" || open[len(open)-1] == "```")
+}
+
+// StripFormatting returns the text a message in parseMode shows, without
+// its markup: tags and delimiters dropped, references and escapes resolved,
+// links reduced to their text. Texts in other parse modes are returned as is.
+func StripFormatting(text, parseMode string) string {
+	var tokens []splitToken
+	switch parseMode {
+	case tgbotapi.ModeHTML:
+		tokens = tokenizeHTML(text)
+	case tgbotapi.ModeMarkdown:
+		tokens = tokenizeMarkdown(text, false)
+	case tgbotapi.ModeMarkdownV2:
+		tokens = tokenizeMarkdown(text, true)
+	default:
+		return text
+	}
+
+	var b strings.Builder
+	lineStart := true
+	for i, tok := range tokens {
+		out := tok.text
+		switch {
+		case tok.units == 0:
+			out = ""
+		case parseMode == tgbotapi.ModeHTML && strings.HasPrefix(tok.text, "&"):
+			out = html.UnescapeString(tok.text)
+		case strings.HasPrefix(tok.text, `\`) && len(tok.text) > 1:
+			// Legacy Markdown keeps backslashes in code.
+			if parseMode == tgbotapi.ModeMarkdownV2 || i == 0 || !inMarkdownCode(tokens[i-1].open) {
+				out = tok.text[1:]
+			}
+		case parseMode == tgbotapi.ModeMarkdownV2 && tok.text == ">" && lineStart:
+			out = ""
+		}
+		if out != "" {
+			b.WriteString(out)
+			lineStart = strings.HasSuffix(out, "\n")
+		}
+	}
+
+	return b.String()
+}
+
+// isEntityParseError reports Telegram's refusal of a text whose markup it
+// cannot parse.
+func isEntityParseError(err error) bool {
+	return strings.Contains(err.Error(), "can't parse entities")
+}
+
+// formatText validates text for the bot's parse mode and returns it with the
+// parse mode to send it with. Invalid text fails, or with
+// WithPlainTextFallback is logged and returned stripped, with no parse mode.
+func (b *ChatBotImpl) formatText(text string) (string, string, error) {
+	parseMode := b.apiParseMode()
+	if err := ValidateText(text, parseMode); err != nil {
+		if !b.plainTextFallback {
+			return "", "", err
+		}
+		b.logger.Errorf("invalid %s text, sending it without formatting: %s: %q", parseMode, err, text)
+		return StripFormatting(text, parseMode), "", nil
+	}
+
+	return text, parseMode, nil
+}
+
+// retryPlain reports whether a send that failed with err should be retried
+// without formatting, logging the rejected text if so.
+func (b *ChatBotImpl) retryPlain(err error, text, parseMode string) bool {
+	if !b.plainTextFallback || parseMode == "" || !isEntityParseError(err) {
+		return false
+	}
+	b.logger.Errorf("Telegram rejected %s text, resending it without formatting: %s: %q", parseMode, err, text)
+
+	return true
+}
@@ -0,0 +1,144 @@
+package bf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestValidateText_HTML(t *testing.T) {
+	tests := []struct {
+		text string
+		want string // substring of the error; empty for valid text
+	}{
+		{`<b>bold <a href="https://x.io?a=1&amp;b=2">link</a></b> &lt;3 &#128512; &#x1F600;`, ""},
+		{`<span class="tg-spoiler">s</span> <pre><code class="language-go">x</code></pre>`, ""},
+		{"AT&T & friends; a > b", ""},
+		{"<br/>", "unsupported tag <br/>"},
+		{"<div>x</div>", "unsupported tag <div>"},
+		{"<b><i>x</b></i>", "byte 7: unexpected closing tag </b>"},
+		{"x</b>", "unexpected closing tag"},
+		{"<b>open", "byte 0: tag <b> is not closed"},
+		{"1 < 2", `unescaped "<"`},
+		{"&nbsp;", "unsupported character reference &nbsp;"},
+	}
+	for _, tt := range tests {
+		err := ValidateText(tt.text, tgbotapi.ModeHTML)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%q: unexpected error %v", tt.text, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%q: want error %q, got %v", tt.text, tt.want, err)
+		case err != nil && !errors.Is(err, ErrInvalidMarkup):
+			t.Errorf("%q: error does not wrap ErrInvalidMarkup", tt.text)
+		}
+	}
+}
+
+func TestValidateText_MarkdownV2(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`*bold* _it_ __u__ ~s~ ||sp|| [link](https://x.io/a\)b) \. \!`, ""},
+		{"`a.b!` ```go\nx := y - 1\n```", ""},
+		{">quote\n>more", ""},
+		{"Total: 5.", `byte 8: reserved character "."`},
+		{"a > b", `reserved character ">"`},
+		{"(x)", `reserved character "("`},
+		{"*bold", `byte 0: entity "*" is not closed`},
+		{"[link", `entity "[" is not closed`},
+		{"`code", "is not closed"},
+	}
+	for _, tt := range tests {
+		err := ValidateText(tt.text, tgbotapi.ModeMarkdownV2)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%q: unexpected error %v", tt.text, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%q: want error %q, got %v", tt.text, tt.want, err)
+		}
+	}
+
+	if err := ValidateText("1.5 <x>", tgbotapi.ModeMarkdown); err != nil {
+		t.Fatalf("legacy Markdown is not checked: %v", err)
+	}
+}
+
+func TestStripFormatting(t *testing.T) {
+	tests := []struct {
+		mode, text, want string
+	}{
+		{tgbotapi.ModeHTML, `<b>Hi</b> <a href="x">Ann</a> &lt;3 &amp; <i>more`, "Hi Ann <3 & more"},
+		{tgbotapi.ModeMarkdownV2, "*Hi* [Ann](tg://user?id=1) 5\\.0 `a\\`b`\n>quote", "Hi Ann 5.0 a`b\nquote"},
+		{tgbotapi.ModeMarkdown, `*Hi* _x\_y_ [Ann](u)`, "Hi x_y Ann"},
+		{"", "<b>as is</b>", "<b>as is</b>"},
+	}
+	for _, tt := range tests {
+		if got := StripFormatting(tt.text, tt.mode); got != tt.want {
+			t.Errorf("%q %q: got %q, want %q", tt.mode, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSendMsg_InvalidMarkupFailsBeforeSending(t *testing.T) {
+	bot, mock := newTestBot()
+
+	err := bot.SendMsg(42, bot.NewLayer("<b>unclosed"))
+	if !errors.Is(err, ErrInvalidMarkup) {
+		t.Fatalf("want ErrInvalidMarkup, got %v", err)
+	}
+	if mock.sentCount() != 0 {
+		t.Fatal("invalid text must not reach Telegram")
+	}
+	if bot.peekLayer(chatKey(42)) != nil {
+		t.Fatal("layer installed although nothing was sent")
+	}
+}
+
+func TestPlainTextFallback_StripsInvalidMarkup(t *testing.T) {
+	logger := &capturingLogger{}
+	bot, mock := newTestBot()
+	bot.logger = logger
+	WithPlainTextFallback()(bot)
+
+	if err := bot.SendMsg(42, bot.NewLayer("<b>Hi</b> <x>there")); err != nil {
+		t.Fatal(err)
+	}
+	msg := mock.lastSent().(tgbotapi.MessageConfig)
+	if msg.Text != "Hi there" || msg.ParseMode != "" {
+		t.Fatalf("sent %q with mode %q", msg.Text, msg.ParseMode)
+	}
+	if !logger.logged("<x>there") {
+		t.Fatal("offending text not logged")
+	}
+}
+
+func TestPlainTextFallback_RetriesEntityParseErrors(t *testing.T) {
+	bot, mock := newTestBot()
+	bot.logger = &capturingLogger{}
+	WithPlainTextFallback()(bot)
+	mock.sendErrs = []error{errors.New("Bad Request: can't parse entities: unsupported start tag")}
+
+	if err := bot.SendMsg(42, bot.NewLayer("<b>Hi</b> &amp; bye")); err != nil {
+		t.Fatal(err)
+	}
+	if mock.sentCount() != 2 {
+		t.Fatalf("want a retry, got %d sends", mock.sentCount())
+	}
+	msg := mock.lastSent().(tgbotapi.MessageConfig)
+	if msg.Text != "Hi & bye" || msg.ParseMode != "" {
+		t.Fatalf("retry sent %q with mode %q", msg.Text, msg.ParseMode)
+	}
+
+	// Other errors are not retried.
+	mock.sendErrs = []error{errors.New("Forbidden: bot was blocked by the user")}
+	if err := bot.SendMsg(42, bot.NewLayer("<b>Hi</b>")); err == nil {
+		t.Fatal("want the send error")
+	}
+	if mock.sentCount() != 3 {
+		t.Fatalf("unexpected retry: %d sends", mock.sentCount())
+	}
+}
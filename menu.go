@@ -0,0 +1,358 @@
+package bf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// menuSet holds the declarative menus configured by WithMenus.
+type menuSet struct {
+	fsys     fs.FS
+	patterns []string
+
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+	// screens and commands are compiled from the files by LoadMenus.
+	screens  map[string]*menuScreen
+	commands map[string]menuCommand
+}
+
+// menuFile is the format of a WithMenus file.
+type menuFile struct {
+	Commands map[string]menuCommand `json:"commands" yaml:"commands"`
+	Screens  map[string]*menuScreen `json:"screens" yaml:"screens"`
+}
+
+// menuCommand is a command of a menu file: the screen it opens, written
+// either alone or with what the help says about the command.
+type menuCommand struct {
+	Screen      string `json:"screen" yaml:"screen"`
+	Description string `json:"description" yaml:"description"`
+	Group       string `json:"group" yaml:"group"`
+	Hidden      bool   `json:"hidden" yaml:"hidden"`
+}
+
+// plainMenuCommand decodes the long form of a menuCommand.
+type plainMenuCommand menuCommand
+
+func (c *menuCommand) UnmarshalJSON(raw []byte) error {
+	if json.Unmarshal(raw, &c.Screen) == nil {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+
+	return dec.Decode((*plainMenuCommand)(c))
+}
+
+func (c *menuCommand) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&c.Screen)
+	}
+
+	// node.Decode ignores KnownFields, so decode a copy strictly.
+	raw, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+
+	return dec.Decode((*plainMenuCommand)(c))
+}
+
+// meta is what Routes and the help show for the command.
+func (c menuCommand) meta() routeMeta {
+	return routeMeta{description: c.Description, group: c.Group, hidden: c.Hidden}
+}
+
+// menuScreen is one screen of a menu: a text and inline buttons.
+type menuScreen struct {
+	Text    string        `json:"text" yaml:"text"`
+	Columns int           `json:"columns" yaml:"columns"`
+	Buttons []*menuButton `json:"buttons" yaml:"buttons"`
+}
+
+// menuButton is an inline button of a screen; exactly one of Screen, URL
+// and Handler is set.
+type menuButton struct {
+	Text    string `json:"text" yaml:"text"`
+	Screen  string `json:"screen" yaml:"screen"`
+	URL     string `json:"url" yaml:"url"`
+	Handler string `json:"handler" yaml:"handler"`
+
+	// handlerFunc is the RegisterMenuHandler handler named by Handler.
+	handlerFunc HandlerFunc
+}
+
+// WithMenus describes menus declaratively, in the JSON or YAML files of fsys
+// matching the patterns (fs.Glob syntax):
+//
+//	commands:
+//	  /help: help
+//	  /faq: {screen: faq, description: Common questions, group: Support}
+//	screens:
+//	  help:
+//	    text: How can we help?
+//	    columns: 2
+//	    buttons:
+//	      - {text: FAQ, screen: faq}
+//	      - {text: Docs, url: "https://example.com/docs"}
+//	      - {text: Talk to us, handler: support}
+//	  faq:
+//	    text: ...
+//
+// A command opens its screen as the root of the chat's navigation; its long
+// form adds the description, group and hidden flag of the help (see Routes).
+// A button opens its screen with PushLayer, so nested screens get a Back
+// button and edit the menu message in place. url buttons open a link, and
+// handler buttons call the HandlerFunc registered under that name with
+// RegisterMenuHandler, for the dynamic parts of a menu. Texts and labels
+// that are WithCatalog keys are translated.
+//
+// The files are compiled by LoadMenus, which Start runs before validating
+// the configuration and Graph runs too: unknown screens or handlers, buttons
+// that are not exactly one of screen, url or handler, screens or commands
+// defined twice and commands registered otherwise make it fail.
+func WithMenus(fsys fs.FS, patterns ...string) BotOption {
+	return func(bot *ChatBotImpl) {
+		handlers := map[string]HandlerFunc{}
+		if bot.menus != nil {
+			handlers = bot.menus.handlers
+		}
+		bot.menus = &menuSet{fsys: fsys, patterns: patterns, handlers: handlers}
+	}
+}
+
+// RegisterMenuHandler binds the handler named in WithMenus files. Register
+// every handler before Start.
+func (b *ChatBotImpl) RegisterMenuHandler(name string, handler HandlerFunc) {
+	if b.menus == nil {
+		b.menus = &menuSet{handlers: map[string]HandlerFunc{}}
+	}
+
+	b.menus.mu.Lock()
+	b.menus.handlers[name] = handler
+	b.menus.mu.Unlock()
+}
+
+// ShowMenu pushes the WithMenus screen onto the chat's navigation stack, see
+// PushLayer. Call ResetNavigation first to open it as a root.
+func (b *ChatBotImpl) ShowMenu(ctx context.Context, event Event, screen string) error {
+	layer, err := b.menuLayer(ctx, screen)
+	if err != nil {
+		return err
+	}
+
+	return b.PushLayer(event, layer)
+}
+
+// menuLayer builds the layer showing screen.
+func (b *ChatBotImpl) menuLayer(ctx context.Context, name string) (*HandlerLayer, error) {
+	if b.menus == nil {
+		return nil, errors.New("no menus configured; see WithMenus")
+	}
+
+	b.menus.mu.RLock()
+	screen, ok := b.menus.screens[name]
+	b.menus.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown menu screen %q", name)
+	}
+
+	layer := b.NewLayer(T(ctx, screen.Text))
+	if screen.Columns > 0 {
+		layer.SetIButtonColumns(screen.Columns)
+	}
+	for _, button := range screen.Buttons {
+		switch {
+		case button.URL != "":
+			layer.RegisterIButtonURL(button.Text, button.URL)
+		case button.Handler != "":
+			layer.RegisterIButton(button.Text, button.handlerFunc)
+		default:
+			target := button.Screen
+			layer.RegisterIButton(button.Text, func(ctx context.Context, event Event) error {
+				return b.ShowMenu(ctx, event, target)
+			})
+		}
+	}
+
+	return layer, nil
+}
+
+// LoadMenus compiles the WithMenus files, if any, and registers their
+// commands on the default layer, replacing those of an earlier load. Start
+// and Graph call it; call it yourself to check the files, e.g. in a test.
+func (b *ChatBotImpl) LoadMenus() error {
+	set := b.menus
+	if set == nil || set.fsys == nil {
+		return nil
+	}
+	if b.defaultHandlerLayer == nil {
+		return errors.New("default handler layer is not set")
+	}
+
+	screens := make(map[string]*menuScreen)
+	commands := make(map[string]menuCommand)
+	for _, pattern := range set.patterns {
+		names, err := fs.Glob(set.fsys, pattern)
+		if err != nil {
+			return fmt.Errorf("failed to match menus %q: %w", pattern, err)
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("menu pattern %q matches no files", pattern)
+		}
+		for _, name := range names {
+			if err := readMenuFile(set.fsys, name, screens, commands); err != nil {
+				return err
+			}
+		}
+	}
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	if err := b.checkMenus(screens, commands, set); err != nil {
+		return err
+	}
+	for _, screen := range screens {
+		for _, button := range screen.Buttons {
+			button.handlerFunc = set.handlers[button.Handler]
+		}
+	}
+
+	b.defaultLayerMutex.Lock()
+	for command := range set.commands {
+		delete(b.defaultHandlerLayer.commandHandler, command)
+	}
+	for command, c := range commands {
+		screen := c.Screen
+		b.defaultHandlerLayer.commandHandler[command] = CommandHandler{
+			handlerFunc: func(ctx context.Context, event Event) error {
+				b.resetNavigation(event)
+				return b.ShowMenu(ctx, event, screen)
+			},
+			meta: c.meta(),
+		}
+	}
+	b.defaultLayerMutex.Unlock()
+	set.screens, set.commands = screens, commands
+
+	return nil
+}
+
+// readMenuFile adds the screens and commands of the file name.
+func readMenuFile(fsys fs.FS, name string, screens map[string]*menuScreen, commands map[string]menuCommand) error {
+	raw, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return fmt.Errorf("failed to read menu %q: %w", name, err)
+	}
+
+	var file menuFile
+	switch ext := path.Ext(name); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&file)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(raw))
+		dec.KnownFields(true)
+		err = dec.Decode(&file)
+	default:
+		return fmt.Errorf("menu %q: unsupported format %q, want .json, .yaml or .yml", name, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode menu %q: %w", name, err)
+	}
+
+	for id, screen := range file.Screens {
+		if _, ok := screens[id]; ok {
+			return fmt.Errorf("menu %q: screen %q is already defined", name, id)
+		}
+		if screen == nil {
+			screen = &menuScreen{}
+		}
+		screens[id] = screen
+	}
+	for command, c := range file.Commands {
+		if _, ok := commands[command]; ok {
+			return fmt.Errorf("menu %q: command %q is already defined", name, command)
+		}
+		commands[command] = c
+	}
+
+	return nil
+}
+
+// checkMenus reports every dangling reference and malformed entry; the
+// commands of set's earlier load do not count as taken.
+func (b *ChatBotImpl) checkMenus(
+	screens map[string]*menuScreen,
+	commands map[string]menuCommand,
+	set *menuSet,
+) error {
+	handlers := set.handlers
+	var problems []string
+	for id, screen := range screens {
+		if screen.Text == "" {
+			problems = append(problems, fmt.Sprintf("screen %q has no text", id))
+		}
+		for i, button := range screen.Buttons {
+			where := fmt.Sprintf("screen %q button %d", id, i+1)
+			if button == nil || button.Text == "" {
+				problems = append(problems, where+" has no text")
+				continue
+			}
+			where = fmt.Sprintf("screen %q button %q", id, button.Text)
+
+			targets := 0
+			for _, target := range []string{button.Screen, button.URL, button.Handler} {
+				if target != "" {
+					targets++
+				}
+			}
+			switch {
+			case targets != 1:
+				problems = append(problems, where+" needs exactly one of screen, url and handler")
+			case button.Screen != "" && screens[button.Screen] == nil:
+				problems = append(problems, fmt.Sprintf("%s opens unknown screen %q", where, button.Screen))
+			case button.Handler != "" && handlers[button.Handler] == nil:
+				problems = append(problems, fmt.Sprintf("%s calls unregistered handler %q", where, button.Handler))
+			}
+		}
+	}
+
+	b.defaultLayerMutex.RLock()
+	for command, c := range commands {
+		_, taken := b.defaultHandlerLayer.commandHandler[command]
+		_, own := set.commands[command]
+		switch {
+		case !strings.HasPrefix(command, "/"):
+			problems = append(problems, fmt.Sprintf("command %q does not start with /", command))
+		case taken && !own:
+			problems = append(problems, fmt.Sprintf("command %q is already registered", command))
+		case screens[c.Screen] == nil:
+			problems = append(problems, fmt.Sprintf("command %q opens unknown screen %q", command, c.Screen))
+		}
+	}
+	b.defaultLayerMutex.RUnlock()
+
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return fmt.Errorf("failed to check menus: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
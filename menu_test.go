@@ -0,0 +1,213 @@
+package bf
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const helpMenu = `
+commands:
+  /help: help
+screens:
+  help:
+    text: How can we help?
+    columns: 2
+    buttons:
+      - {text: FAQ, screen: faq}
+      - {text: Docs, url: "https://example.com/docs"}
+      - {text: Talk to us, handler: support}
+  faq:
+    text: Frequently asked
+    buttons:
+      - {text: Home, screen: help}
+`
+
+func TestMenus_CommandsButtonsAndHandlers(t *testing.T) {
+	bot, mock := newTestBot()
+	WithMenus(fstest.MapFS{"menus/help.yaml": {Data: []byte(helpMenu)}}, "menus/*.yaml")(bot)
+	var supported bool
+	bot.RegisterMenuHandler("support", func(context.Context, Event) error {
+		supported = true
+		return nil
+	})
+	if err := bot.LoadMenus(); err != nil {
+		t.Fatal(err)
+	}
+	c := newChatController(context.Background())
+	ctx := context.Background()
+
+	bot.handleUpdate(ctx, c, cmdUpdate(42, "/help"))
+	msg := mock.lastSent().(tgbotapi.MessageConfig)
+	keyboard := msg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup).InlineKeyboard
+	if msg.Text != "How can we help?" || len(keyboard) != 2 || len(keyboard[0]) != 2 {
+		t.Fatalf("unexpected root screen %q %+v", msg.Text, keyboard)
+	}
+	if url := keyboard[0][1].URL; url == nil || *url != "https://example.com/docs" {
+		t.Fatalf("url button %+v", keyboard[0][1])
+	}
+
+	bot.handleUpdate(ctx, c, withMessageID(tapUpdate(42, mock.lastMarkup(t), "FAQ"), 500))
+	edit, ok := mock.lastSent().(tgbotapi.EditMessageTextConfig)
+	if !ok || edit.Text != "Frequently asked" {
+		t.Fatalf("want the faq screen in place, got %+v", mock.lastSent())
+	}
+	rows := edit.ReplyMarkup.InlineKeyboard
+	if rows[len(rows)-1][0].Text != defaultBackButtonText {
+		t.Fatalf("nested screen lacks Back button: %+v", rows)
+	}
+
+	bot.handleUpdate(ctx, c, withMessageID(tapUpdate(42, *edit.ReplyMarkup, defaultBackButtonText), 500))
+	edit = mock.lastSent().(tgbotapi.EditMessageTextConfig)
+	bot.handleUpdate(ctx, c, withMessageID(tapUpdate(42, *edit.ReplyMarkup, "Talk to us"), 500))
+	if !supported {
+		t.Fatal("named handler not called")
+	}
+}
+
+func TestMenus_JSONAndTranslation(t *testing.T) {
+	bot, mock := newTestBot()
+	WithCatalog(testCatalog(t))(bot)
+	WithMenus(fstest.MapFS{"menu.json": {Data: []byte(
+		`{"commands": {"/settings": "root"},
+		  "screens": {"root": {"text": "menu.settings", "buttons": [{"text": "menu.back", "url": "https://x.io"}]}}}`,
+	)}}, "*.json")(bot)
+	if err := bot.LoadMenus(); err != nil {
+		t.Fatal(err)
+	}
+
+	update := cmdUpdate(42, "/settings")
+	update.Message.From = &tgbotapi.User{ID: 7, LanguageCode: "ru"}
+	bot.handleUpdate(context.Background(), newChatController(context.Background()), update)
+	if got := lastText(t, mock); got != "Настройки" {
+		t.Fatalf("text %q", got)
+	}
+	if got := mock.lastMarkup(t).InlineKeyboard[0][0].Text; got != "Back" {
+		t.Fatalf("label %q", got)
+	}
+}
+
+func TestMenus_StartFailsOnBadDefinitions(t *testing.T) {
+	tests := []struct {
+		name string
+		menu string
+		want string
+	}{
+		{"dangling screen", "screens:\n  a:\n    text: A\n    buttons:\n      - {text: B, screen: b}",
+			`screen "a" button "B" opens unknown screen "b"`},
+		{"unknown handler", "screens:\n  a:\n    text: A\n    buttons:\n      - {text: B, handler: nope}",
+			`calls unregistered handler "nope"`},
+		{"two targets", "screens:\n  a:\n    text: A\n    buttons:\n      - {text: B, screen: a, url: u}",
+			"needs exactly one of screen, url and handler"},
+		{"dangling command", "commands:\n  /go: b\nscreens:\n  a:\n    text: A",
+			`command "/go" opens unknown screen "b"`},
+		{"taken command", "commands:\n  /start: a\nscreens:\n  a:\n    text: A",
+			`command "/start" is already registered`},
+		{"no text", "screens:\n  a:\n    buttons: []", `screen "a" has no text`},
+		{"unknown field", "screens:\n  a:\n    txt: A", "field txt not found"},
+	}
+	for _, tt := range tests {
+		bot, mock := newTestBot()
+		bot.RegisterCommand("/start", func(context.Context, Event) error { return nil })
+		WithMenus(fstest.MapFS{"menu.yml": {Data: []byte(tt.menu)}}, "*.yml")(bot)
+
+		err := bot.Start(context.Background())
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: want %q, got %v", tt.name, tt.want, err)
+		}
+		if mock.stopped.Load() {
+			t.Errorf("%s: Start must fail before polling", tt.name)
+		}
+	}
+}
+
+func TestShowMenu_Errors(t *testing.T) {
+	bot, _ := newTestBot()
+	if err := bot.ShowMenu(context.Background(), Event{ChatID: 42}, "help"); err == nil {
+		t.Fatal("want an error without WithMenus")
+	}
+
+	WithMenus(fstest.MapFS{"help.yaml": {Data: []byte(helpMenu)}}, "*.yaml")(bot)
+	bot.RegisterMenuHandler("support", func(context.Context, Event) error { return nil })
+	if err := bot.LoadMenus(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.ShowMenu(context.Background(), Event{ChatID: 42}, "nope"); err == nil {
+		t.Fatal("want an error for an unknown screen")
+	}
+}
+
+func TestMenus_LoadIsIdempotent(t *testing.T) {
+	bot, _ := newTestBot()
+	WithMenus(fstest.MapFS{"help.yaml": {Data: []byte(helpMenu)}}, "*.yaml")(bot)
+	bot.RegisterMenuHandler("support", func(context.Context, Event) error { return nil })
+
+	for i := 0; i < 2; i++ {
+		if err := bot.LoadMenus(); err != nil {
+			t.Fatalf("load %d: %v", i+1, err)
+		}
+	}
+	if _, ok := bot.defaultHandlerLayer.commandHandler["/help"]; !ok {
+		t.Fatal("/help lost on reload")
+	}
+}
+
+func TestMenus_CommandHelpFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"yaml", fstest.MapFS{"m.yaml": {Data: []byte(
+			"commands:\n  /faq: {screen: a, description: Questions, group: Support}\n" +
+				"  /debug: {screen: a, hidden: true}\nscreens:\n  a:\n    text: A",
+		)}}},
+		{"json", fstest.MapFS{"m.json": {Data: []byte(
+			`{"commands": {"/faq": {"screen": "a", "description": "Questions", "group": "Support"},
+			  "/debug": {"screen": "a", "hidden": true}},
+			  "screens": {"a": {"text": "A"}}}`,
+		)}}},
+	}
+	for _, tt := range tests {
+		bot, _ := newTestBot()
+		WithMenus(tt.files, "m.*")(bot)
+		if err := bot.LoadMenus(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		want := []Route{
+			{Kind: RouteCommand, Trigger: "/debug", Hidden: true},
+			{Kind: RouteCommand, Trigger: "/faq", Description: "Questions", Group: "Support"},
+		}
+		got := bot.Routes()
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Fatalf("%s: routes %+v", tt.name, got)
+		}
+	}
+}
+
+func TestMenus_UnknownCommandField(t *testing.T) {
+	for name, data := range map[string]string{
+		"m.yaml": "commands:\n  /faq: {screen: a, descr: Q}\nscreens:\n  a:\n    text: A",
+		"m.json": `{"commands": {"/faq": {"screen": "a", "descr": "Q"}}, "screens": {"a": {"text": "A"}}}`,
+	} {
+		bot, _ := newTestBot()
+		WithMenus(fstest.MapFS{name: {Data: []byte(data)}}, name)(bot)
+		if err := bot.LoadMenus(); err == nil || !strings.Contains(err.Error(), "descr") {
+			t.Fatalf("%s: want unknown field error, got %v", name, err)
+		}
+	}
+}
+
+func TestMenus_LoadedBeforeValidation(t *testing.T) {
+	bot, _ := newTestBot()
+	bot.errorHandler = nil
+	WithMenus(fstest.MapFS{"m.yaml": {Data: []byte("commands:\n  /go: b")}}, "m.yaml")(bot)
+
+	err := bot.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "failed to load menus") {
+		t.Fatalf("want the menu error first, got %v", err)
+	}
+}
@@ -17,12 +17,19 @@ type mockTelegramAPI struct {
 	sent     []tgbotapi.Chattable
 	sendErr  error
 	sendResp tgbotapi.Message
+	// sendErrs fail the next sends in order before sendErr applies.
+	sendErrs []error
+
+	requests []tgbotapi.Chattable
 
 	fileURLs   map[string]string
 	fileURLErr error
 
 	self    tgbotapi.User
 	stopped atomic.Bool
+
+	// topics are the forum topic fields by update ID, see topicReader.
+	topics map[int]updateTopic
 }
 
 func newMockTelegramAPI() *mockTelegramAPI {
@@ -37,9 +44,21 @@ func (m *mockTelegramAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.sent = append(m.sent, c)
+	if len(m.sendErrs) > 0 {
+		err := m.sendErrs[0]
+		m.sendErrs = m.sendErrs[1:]
+		return m.sendResp, err
+	}
 	return m.sendResp, m.sendErr
 }
 
+func (m *mockTelegramAPI) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, c)
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
 func (m *mockTelegramAPI) GetUpdatesChan(_ tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
 	return m.updates
 }
@@ -54,6 +73,13 @@ func (m *mockTelegramAPI) GetFileDirectURL(fileID string) (string, error) {
 	return "", nil
 }
 
+func (m *mockTelegramAPI) takeTopic(updateID int) (updateTopic, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	topic, ok := m.topics[updateID]
+	return topic, ok
+}
+
 func (m *mockTelegramAPI) StopReceivingUpdates() { m.stopped.Store(true) }
 func (m *mockTelegramAPI) Self() tgbotapi.User   { return m.self }
 
@@ -79,10 +105,19 @@ func newTestBot() (*ChatBotImpl, *mockTelegramAPI) {
 	mock := newMockTelegramAPI()
 	bot := &ChatBotImpl{
 		tgbot:               mock,
-		chatHandlerLayers:   make(map[int64]*HandlerLayer),
+		chatHandlerLayers:   make(map[scopeKey]*HandlerLayer),
 		defaultHandlerLayer: nil,
+		messageLayers:       make(map[messageKey]messageLayer),
+		messageOrder:        make(map[int64][]int),
+		messageLayerTTL:     defaultMessageLayerTTL,
+		navStacks:           make(map[scopeKey]navStack),
+		backButtonText:      defaultBackButtonText,
+		staleButtonText:     defaultStaleButtonText,
+		replyKeyboards:      make(map[scopeKey]shownReplyKeyboard),
+		topicLayers:         make(map[scopeKey]*HandlerLayer),
 		middlewares:         make([]MiddlewareFunc, 0),
 		logger:              noopLogger{},
+		sessionStore:        NewMemorySessionStore(),
 		parseMode:           tgbotapi.ModeHTML,
 		defaultTTL:          24 * time.Hour,
 		updateConcurrency:   defaultUpdateConcurrency,
@@ -94,3 +129,43 @@ func newTestBot() (*ChatBotImpl, *mockTelegramAPI) {
 	bot.RegisterDefaultHandler(bot.defaultEventHandler)
 	return bot, mock
 }
+
+// lastMarkup returns the inline keyboard of the most recent sent message.
+func (m *mockTelegramAPI) lastMarkup(t interface {
+	Helper()
+	Fatalf(string, ...any)
+}) tgbotapi.InlineKeyboardMarkup {
+	t.Helper()
+	msg, ok := m.lastSent().(tgbotapi.MessageConfig)
+	if !ok {
+		t.Fatalf("last sent is %T, not a message", m.lastSent())
+	}
+	markup, ok := msg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	if !ok {
+		t.Fatalf("last message has %T, not an inline keyboard", msg.ReplyMarkup)
+	}
+	return markup
+}
+
+// tapUpdate builds the callback update Telegram sends when the button with
+// the given label is tapped on a message carrying markup.
+func tapUpdate(chatID int64, markup tgbotapi.InlineKeyboardMarkup, label string) tgbotapi.Update {
+	var data string
+	for _, row := range markup.InlineKeyboard {
+		for _, button := range row {
+			if button.Text == label && button.CallbackData != nil {
+				data = *button.CallbackData
+			}
+		}
+	}
+	return tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			Data: data,
+			From: &tgbotapi.User{ID: 1},
+			Message: &tgbotapi.Message{
+				Chat:        &tgbotapi.Chat{ID: chatID},
+				ReplyMarkup: &markup,
+			},
+		},
+	}
+}
@@ -0,0 +1,185 @@
+package bf
+
+import (
+	"slices"
+	"time"
+)
+
+// defaultMessageLayerTTL is how long the inline buttons of a sent message
+// keep their handlers; override with WithMessageLayerTTL.
+const defaultMessageLayerTTL = 7 * 24 * time.Hour
+
+// maxChatMessageLayers is how many messages per chat keep their layer; the
+// buttons of older ones go stale.
+const maxChatMessageLayers = 100
+
+// messageKey identifies one bot message.
+type messageKey struct {
+	chatID    int64
+	messageID int
+}
+
+// messageLayer is the layer whose inline keyboard a message carries.
+type messageLayer struct {
+	layer   *HandlerLayer
+	expires time.Time
+}
+
+// rememberMessageLayer records layer as the one rendered on the message, so
+// its inline buttons keep working after the chat layer has moved on, and
+// replies to a ForceReply prompt find their layer. Other layers and unknown
+// message ids are not recorded. ForceReply layers keep their own TTL. Past
+// maxChatMessageLayers the chat's oldest message is forgotten.
+func (b *ChatBotImpl) rememberMessageLayer(chatID int64, messageID int, layer *HandlerLayer) {
+	if messageID == 0 || (layer.forceReply == nil && !layer.hasCallbackButtons()) {
+		return
+	}
+
+	expires := time.Now().Add(b.messageLayerTTL)
+	if layer.forceReply != nil {
+		expires = layer.ttl
+	}
+
+	b.messageLayersMutex.Lock()
+	defer b.messageLayersMutex.Unlock()
+
+	key := messageKey{chatID: chatID, messageID: messageID}
+	if _, ok := b.messageLayers[key]; !ok {
+		b.messageOrder[chatID] = append(b.messageOrder[chatID], messageID)
+	}
+	b.messageLayers[key] = messageLayer{layer: layer, expires: expires}
+
+	if order := b.messageOrder[chatID]; len(order) > maxChatMessageLayers {
+		b.deleteMessageLayer(messageKey{chatID: chatID, messageID: order[0]})
+	}
+}
+
+// forgetMessageLayer makes the buttons of a message stale, e.g. once the
+// widget it shows has finished.
+func (b *ChatBotImpl) forgetMessageLayer(chatID int64, messageID int) {
+	b.messageLayersMutex.Lock()
+	defer b.messageLayersMutex.Unlock()
+
+	b.deleteMessageLayer(messageKey{chatID: chatID, messageID: messageID})
+}
+
+// deleteMessageLayer removes the message's entry; the caller holds
+// messageLayersMutex.
+func (b *ChatBotImpl) deleteMessageLayer(key messageKey) {
+	if _, ok := b.messageLayers[key]; !ok {
+		return
+	}
+	delete(b.messageLayers, key)
+
+	order := slices.DeleteFunc(b.messageOrder[key.chatID], func(id int) bool { return id == key.messageID })
+	if len(order) == 0 {
+		delete(b.messageOrder, key.chatID)
+		return
+	}
+	b.messageOrder[key.chatID] = order
+}
+
+// replyLayer returns the ForceReply layer of the message event replies to,
+// or nil, also when the layer's scope does not admit the event; take removes
+// it unless it is sticky.
+func (b *ChatBotImpl) replyLayer(event Event, take bool) *HandlerLayer {
+	if event.ReplyToMessageID == 0 || event.Kind == EventKindInlineButton {
+		return nil
+	}
+
+	key := messageKey{chatID: event.ChatID, messageID: event.ReplyToMessageID}
+
+	b.messageLayersMutex.Lock()
+	defer b.messageLayersMutex.Unlock()
+
+	entry, ok := b.messageLayers[key]
+	if !ok || entry.layer.forceReply == nil || time.Now().After(entry.expires) || !entry.layer.scope.admits(event) {
+		return nil
+	}
+	if take && !entry.layer.sticky {
+		b.deleteMessageLayer(key)
+	}
+
+	return entry.layer
+}
+
+// messageLayerFor returns the live layer of the message an inline-button
+// event was tapped on, or nil, also when the layer's scope does not admit
+// the event.
+func (b *ChatBotImpl) messageLayerFor(event Event) *HandlerLayer {
+	if event.Kind != EventKindInlineButton || event.MessageID == 0 {
+		return nil
+	}
+
+	b.messageLayersMutex.Lock()
+	defer b.messageLayersMutex.Unlock()
+
+	entry, ok := b.messageLayers[messageKey{chatID: event.ChatID, messageID: event.MessageID}]
+	if !ok || time.Now().After(entry.expires) || !entry.layer.scope.admits(event) {
+		return nil
+	}
+
+	return entry.layer
+}
+
+// layerForEvent picks the layer that serves event. A reply to a ForceReply
+// prompt is served by that prompt's layer, and a tap on an older message by
+// that message's layer; both leave the chat layer installed. Everything else
+// consumes the chat layer as usual.
+func (b *ChatBotImpl) layerForEvent(event Event) *HandlerLayer {
+	if layer := b.replyLayer(event, true); layer != nil {
+		return layer
+	}
+	if layer := b.messageLayerFor(event); layer != nil && layer != b.peekEventLayer(event) {
+		return layer
+	}
+
+	return b.findAndWipeLayer(event)
+}
+
+// peekLayerForEvent returns the layer layerForEvent would pick for event,
+// without consuming anything; nil when only the default layer applies.
+func (b *ChatBotImpl) peekLayerForEvent(event Event) *HandlerLayer {
+	if layer := b.replyLayer(event, false); layer != nil {
+		return layer
+	}
+	chat := b.peekEventLayer(event)
+	if layer := b.messageLayerFor(event); layer != nil && layer != chat {
+		return layer
+	}
+
+	return chat
+}
+
+// peekLayer returns the layer in slot key without consuming it.
+func (b *ChatBotImpl) peekLayer(key scopeKey) *HandlerLayer {
+	b.layersMutex.RLock()
+	defer b.layersMutex.RUnlock()
+
+	return b.chatHandlerLayers[key]
+}
+
+// peekEventLayer returns the layer findAndWipeLayer would pick for event,
+// without consuming it; nil when only the default layer applies.
+func (b *ChatBotImpl) peekEventLayer(event Event) *HandlerLayer {
+	for _, key := range eventKeys(event) {
+		if layer := b.peekLayer(key); layer != nil {
+			return layer
+		}
+	}
+
+	return nil
+}
+
+// sweepExpiredMessageLayers forgets message layers past their TTL.
+func (b *ChatBotImpl) sweepExpiredMessageLayers() {
+	b.messageLayersMutex.Lock()
+	defer b.messageLayersMutex.Unlock()
+
+	now := time.Now()
+	for key, entry := range b.messageLayers {
+		if now.After(entry.expires) {
+			b.deleteMessageLayer(key)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package bf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// sendAs sends layer while the mock reports messageID for the sent message,
+// and returns the rendered inline keyboard.
+func sendAs(
+	t *testing.T,
+	bot *ChatBotImpl,
+	mock *mockTelegramAPI,
+	messageID int,
+	layer *HandlerLayer,
+) tgbotapi.InlineKeyboardMarkup {
+	t.Helper()
+	mock.sendResp = tgbotapi.Message{MessageID: messageID}
+	if err := bot.SendMsg(42, layer); err != nil {
+		t.Fatal(err)
+	}
+	return mock.lastMarkup(t)
+}
+
+func TestMessageLayers_OldButtonsKeepWorking(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	var got []string
+	record := func(name string) HandlerFunc {
+		return func(context.Context, Event) error { got = append(got, name); return nil }
+	}
+
+	first := bot.NewLayer("Order #1")
+	first.RegisterIButton("Details", record("details-1"))
+	firstMarkup := sendAs(t, bot, mock, 10, first)
+
+	second := bot.NewLayer("Order #2")
+	second.RegisterIButton("Details", record("details-2"))
+	second.RegisterText(AnyText, record("text"))
+	sendAs(t, bot, mock, 11, second)
+
+	// Tapping the older message reaches its own handler, twice, and leaves
+	// the chat layer of the newer message installed.
+	old := withMessageID(tapUpdate(42, firstMarkup, "Details"), 10)
+	bot.handleUpdate(context.Background(), c, old)
+	bot.handleUpdate(context.Background(), c, old)
+	bot.handleUpdate(context.Background(), c, msgUpdate("hello"))
+
+	want := []string{"details-1", "details-1", "text"}
+	if len(got) != len(want) {
+		t.Fatalf("handlers: %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("handlers: %v, want %v", got, want)
+		}
+	}
+}
+
+func TestMessageLayers_CurrentMessageConsumesChatLayer(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	layer := bot.NewLayer("Pick")
+	layer.RegisterIButton("Go", func(context.Context, Event) error { return nil })
+	markup := sendAs(t, bot, mock, 10, layer)
+
+	bot.handleUpdate(context.Background(), c, withMessageID(tapUpdate(42, markup, "Go"), 10))
+
+	if bot.peekLayer(chatKey(42)) != nil {
+		t.Fatal("tapping the current message must consume the chat layer")
+	}
+}
+
+func TestMessageLayers_ExpireAndSkipPlainMessages(t *testing.T) {
+	bot, mock := newTestBot()
+	WithMessageLayerTTL(time.Millisecond)(bot)
+
+	mock.sendResp = tgbotapi.Message{MessageID: 10}
+	if err := bot.SendMsg(42, bot.NewLayer("No buttons")); err != nil {
+		t.Fatal(err)
+	}
+	layer := bot.NewLayer("Pick")
+	layer.RegisterIButton("Go", func(context.Context, Event) error { return nil })
+	sendAs(t, bot, mock, 11, layer)
+
+	if len(bot.messageLayers) != 1 {
+		t.Fatalf("want only the keyboard message remembered, got %d", len(bot.messageLayers))
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if bot.messageLayerFor(Event{Kind: EventKindInlineButton, ChatID: 42, MessageID: 11}) != nil {
+		t.Fatal("expired message layer still served")
+	}
+	bot.sweepExpiredMessageLayers()
+	if len(bot.messageLayers) != 0 {
+		t.Fatal("sweep left an expired message layer")
+	}
+}
+
+func TestMessageLayers_BoundedPerChat(t *testing.T) {
+	bot, mock := newTestBot()
+	noop := func(context.Context, Event) error { return nil }
+
+	for id := 1; id <= maxChatMessageLayers+5; id++ {
+		layer := bot.NewLayer("Pick")
+		layer.RegisterIButton("Go", noop)
+		sendAs(t, bot, mock, id, layer)
+	}
+
+	if len(bot.messageLayers) != maxChatMessageLayers || len(bot.messageOrder[42]) != maxChatMessageLayers {
+		t.Fatalf("remembered %d messages", len(bot.messageLayers))
+	}
+	if bot.messageLayerFor(Event{Kind: EventKindInlineButton, ChatID: 42, MessageID: 5}) != nil {
+		t.Fatal("oldest messages must be forgotten")
+	}
+	if bot.messageLayerFor(Event{Kind: EventKindInlineButton, ChatID: 42, MessageID: 6}) == nil {
+		t.Fatal("recent messages must be kept")
+	}
+
+	bot.forgetMessageLayer(42, 6)
+	if bot.messageLayerFor(Event{Kind: EventKindInlineButton, ChatID: 42, MessageID: 6}) != nil {
+		t.Fatal("forgotten message still served")
+	}
+}
+
+func replyUpdate(userID int64, text string, replyTo int) tgbotapi.Update {
+	u := msgUpdate(text)
+	u.Message.From = &tgbotapi.User{ID: userID}
+	u.Message.ReplyToMessage = &tgbotapi.Message{MessageID: replyTo}
+	return u
+}
+
+func TestForceReply_RoutedByReplyNotByChat(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	answers := map[string]string{}
+	prompt := func(name string, messageID int) {
+		t.Helper()
+		layer := bot.NewLayer(name + ", your name?")
+		layer.SetForceReply("Name")
+		layer.RegisterText(AnyText, func(_ context.Context, event Event) error {
+			answers[name] = event.Text
+			return nil
+		})
+		mock.sendResp = tgbotapi.Message{MessageID: messageID}
+		if err := bot.SendMsg(42, layer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	prompt("alice", 10)
+	fr, ok := lastReplyMarkup(t, mock).(tgbotapi.ForceReply)
+	if !ok || !fr.ForceReply || fr.InputFieldPlaceholder != "Name" {
+		t.Fatalf("want ForceReply markup, got %#v", lastReplyMarkup(t, mock))
+	}
+	prompt("bob", 11)
+
+	if bot.peekLayer(chatKey(42)) != nil {
+		t.Fatal("ForceReply layers must not occupy the chat layer")
+	}
+
+	// Replies arrive in reverse order and each reaches its own prompt.
+	bot.handleUpdate(context.Background(), c, replyUpdate(2, "Bob", 11))
+	bot.handleUpdate(context.Background(), c, replyUpdate(1, "Alice", 10))
+	// A second reply to the same prompt is not matched again.
+	bot.handleUpdate(context.Background(), c, replyUpdate(1, "Again", 10))
+
+	if answers["alice"] != "Alice" || answers["bob"] != "Bob" {
+		t.Fatalf("answers: %v", answers)
+	}
+}
+
+func TestForceReply_RejectsButtons(t *testing.T) {
+	bot, _ := newTestBot()
+
+	layer := bot.NewLayer("Name?")
+	layer.SetForceReply("")
+	layer.RegisterButton("Skip", func(context.Context, Event) error { return nil })
+	if err := bot.SendMsg(42, layer); err == nil {
+		t.Fatal("expected error for ForceReply with buttons")
+	}
+}
@@ -0,0 +1,182 @@
+package bf
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// MultiSelectDoneFunc receives the options that were checked when the user
+// tapped Done, in the order they were given to NewMultiSelect.
+type MultiSelectDoneFunc func(ctx context.Context, event Event, selected []string) error
+
+// MultiSelect is a checkbox list: tapping an option toggles a ✅ mark on its
+// button by re-rendering the keyboard in place (editMessageReplyMarkup), and
+// a final Done button delivers the checked set.
+//
+// The widget's layer is sticky, so it keeps receiving taps on the same
+// message until Done; the button callback ids stay stable between edits.
+type MultiSelect struct {
+	bot       *ChatBotImpl
+	prompt    string
+	options   []string
+	initial   map[string]bool
+	onDone    MultiSelectDoneFunc
+	doneText  string
+	checkMark string
+	minCount  int
+	maxCount  int
+}
+
+// NewMultiSelect creates a checkbox list over options.
+func (b *ChatBotImpl) NewMultiSelect(prompt string, options []string, onDone MultiSelectDoneFunc) *MultiSelect {
+	return &MultiSelect{
+		bot:       b,
+		prompt:    prompt,
+		options:   options,
+		initial:   make(map[string]bool),
+		onDone:    onDone,
+		doneText:  "Done",
+		checkMark: "✅ ",
+	}
+}
+
+// SetSelected pre-checks the given options.
+func (m *MultiSelect) SetSelected(options ...string) {
+	for _, option := range options {
+		m.initial[option] = true
+	}
+}
+
+// SetDoneText overrides the Done button label.
+func (m *MultiSelect) SetDoneText(text string) {
+	m.doneText = text
+}
+
+// SetCheckMark overrides the prefix of checked options ("✅ " by default).
+func (m *MultiSelect) SetCheckMark(mark string) {
+	m.checkMark = mark
+}
+
+// SetLimits bounds how many options may be checked. Taps that would exceed
+// maxCount are ignored, and Done does nothing below minCount. Zero means
+// no bound.
+func (m *MultiSelect) SetLimits(minCount, maxCount int) {
+	m.minCount = minCount
+	m.maxCount = maxCount
+}
+
+// Show renders the list, editing the tapped message when event is an
+// inline-button tap. Every Show starts an independent selection.
+func (m *MultiSelect) Show(_ context.Context, event Event) error {
+	if len(m.options) == 0 {
+		return errors.New("multi-select has no options")
+	}
+	if m.onDone == nil {
+		return errors.New("multi-select has no done handler")
+	}
+
+	sel := &multiSelection{
+		selected: make([]bool, len(m.options)),
+		ids:      make([]string, len(m.options)+1),
+	}
+	for i, option := range m.options {
+		sel.selected[i] = m.initial[option]
+	}
+	for i := range sel.ids {
+		sel.ids[i] = uuid.NewString()
+	}
+	sel.layer = m.render(sel)
+
+	return m.bot.presentLayer(event, sel.layer)
+}
+
+// multiSelection is the state of one Show. Members of a group may tap the
+// list at the same time, so it is guarded by mu, and the installed layer
+// is never changed: toggles render a fresh layer with the same button ids.
+type multiSelection struct {
+	mu       sync.Mutex
+	selected []bool
+	done     bool
+	// ids are the callback ids of the options, then of Done.
+	ids   []string
+	layer *HandlerLayer
+}
+
+// render builds the sticky layer showing the current selection; the caller
+// holds sel.mu or has not shared sel yet.
+func (m *MultiSelect) render(sel *multiSelection) *HandlerLayer {
+	layer := m.bot.NewLayer()
+	layer.AddText(m.prompt)
+	layer.SetSticky()
+
+	for i, option := range m.options {
+		layer.registerIButtonID(sel.ids[i], m.label(option, sel.selected[i]), m.toggleHandler(sel, i))
+	}
+	layer.registerIButtonID(sel.ids[len(m.options)], m.doneText, m.doneHandler(sel))
+
+	return layer
+}
+
+func (m *MultiSelect) label(option string, checked bool) string {
+	if checked {
+		return m.checkMark + option
+	}
+
+	return option
+}
+
+func (m *MultiSelect) toggleHandler(sel *multiSelection, i int) HandlerFunc {
+	return func(_ context.Context, event Event) error {
+		sel.mu.Lock()
+		if sel.done || !sel.selected[i] && m.maxCount > 0 && countTrue(sel.selected) >= m.maxCount {
+			sel.mu.Unlock()
+			return nil
+		}
+		sel.selected[i] = !sel.selected[i]
+		layer := m.render(sel)
+		sel.mu.Unlock()
+
+		if event.MessageID == 0 {
+			return nil
+		}
+
+		return m.bot.editMarkup(eventRecipient(event), event.MessageID, layer)
+	}
+}
+
+func (m *MultiSelect) doneHandler(sel *multiSelection) HandlerFunc {
+	return func(ctx context.Context, event Event) error {
+		sel.mu.Lock()
+		if sel.done || countTrue(sel.selected) < m.minCount {
+			sel.mu.Unlock()
+			return nil
+		}
+		sel.done = true
+		result := make([]string, 0, len(m.options))
+		for i, option := range m.options {
+			if sel.selected[i] {
+				result = append(result, option)
+			}
+		}
+		sel.mu.Unlock()
+
+		m.bot.dropLayer(event.ChatID, event.ThreadID, sel.layer)
+		m.bot.forgetMessageLayer(event.ChatID, event.MessageID)
+
+		return m.onDone(ctx, event, result)
+	}
+}
+
+func countTrue(values []bool) int {
+	n := 0
+	for _, v := range values {
+		if v {
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,185 @@
+package bf
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestMultiSelect_TogglesInPlaceAndDeliversSelection(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+	ctx := context.Background()
+
+	var got []string
+	ms := bot.NewMultiSelect("Toppings?", []string{"Cheese", "Ham", "Olives"},
+		func(_ context.Context, _ Event, selected []string) error {
+			got = selected
+			return nil
+		})
+	ms.SetSelected("Olives")
+
+	if err := ms.Show(ctx, Event{ChatID: 42}); err != nil {
+		t.Fatal(err)
+	}
+	markup := mock.lastMarkup(t)
+	if markup.InlineKeyboard[2][0].Text != "✅ Olives" {
+		t.Fatalf("pre-selection not rendered: %+v", markup.InlineKeyboard)
+	}
+
+	bot.handleUpdate(ctx, c, withMessageID(tapUpdate(42, markup, "Cheese"), 10))
+	edit, ok := mock.lastSent().(tgbotapi.EditMessageReplyMarkupConfig)
+	if !ok || edit.MessageID != 10 {
+		t.Fatalf("want markup edit of message 10, got %T", mock.lastSent())
+	}
+	if edit.ReplyMarkup.InlineKeyboard[0][0].Text != "✅ Cheese" {
+		t.Fatalf("toggle not rendered: %+v", edit.ReplyMarkup.InlineKeyboard)
+	}
+	if *edit.ReplyMarkup.InlineKeyboard[0][0].CallbackData != *markup.InlineKeyboard[0][0].CallbackData {
+		t.Fatal("callback id changed between renders")
+	}
+
+	// Untoggle Olives on the edited keyboard; the layer is still installed.
+	bot.handleUpdate(ctx, c, withMessageID(tapUpdate(42, *edit.ReplyMarkup, "✅ Olives"), 10))
+	edit = mock.lastSent().(tgbotapi.EditMessageReplyMarkupConfig)
+	bot.handleUpdate(ctx, c, withMessageID(tapUpdate(42, *edit.ReplyMarkup, "Done"), 10))
+
+	if strings.Join(got, ",") != "Cheese" {
+		t.Fatalf("want [Cheese], got %v", got)
+	}
+	bot.layersMutex.RLock()
+	_, present := bot.chatHandlerLayers[chatKey(42)]
+	bot.layersMutex.RUnlock()
+	if present {
+		t.Fatal("layer left installed after Done")
+	}
+}
+
+func TestMultiSelect_DoneOnlyOnce(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+	ctx := context.Background()
+
+	calls := 0
+	ms := bot.NewMultiSelect("Toppings?", []string{"Cheese"}, func(context.Context, Event, []string) error {
+		calls++
+		return nil
+	})
+	mock.sendResp = tgbotapi.Message{MessageID: 10}
+	if err := ms.Show(ctx, Event{ChatID: 42}); err != nil {
+		t.Fatal(err)
+	}
+	done := withMessageID(tapUpdate(42, mock.lastMarkup(t), "Done"), 10)
+
+	bot.handleUpdate(ctx, c, done)
+	bot.handleUpdate(ctx, c, done)
+	if calls != 1 {
+		t.Fatalf("onDone called %d times", calls)
+	}
+}
+
+func TestMultiSelect_Limits(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+	ctx := context.Background()
+
+	done := false
+	ms := bot.NewMultiSelect("Pick", []string{"A", "B"}, func(context.Context, Event, []string) error {
+		done = true
+		return nil
+	})
+	ms.SetLimits(1, 1)
+
+	if err := ms.Show(ctx, Event{ChatID: 42}); err != nil {
+		t.Fatal(err)
+	}
+	markup := mock.lastMarkup(t)
+
+	bot.handleUpdate(ctx, c, tapUpdate(42, markup, "Done"))
+	if done {
+		t.Fatal("Done accepted below the minimum")
+	}
+
+	bot.handleUpdate(ctx, c, withMessageID(tapUpdate(42, markup, "A"), 10))
+	bot.handleUpdate(ctx, c, withMessageID(tapUpdate(42, markup, "B"), 10))
+
+	edit := mock.lastSent().(tgbotapi.EditMessageReplyMarkupConfig)
+	for _, row := range edit.ReplyMarkup.InlineKeyboard {
+		if row[0].Text == "✅ B" {
+			t.Fatal("toggle beyond the maximum accepted")
+		}
+	}
+}
+
+func TestMultiSelect_ConcurrentTapsInGroup(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+	ctx := context.Background()
+
+	options := []string{"A", "B", "C", "D", "E", "F", "G", "H"}
+	var got []string
+	ms := bot.NewMultiSelect("Pick", options, func(_ context.Context, _ Event, selected []string) error {
+		got = selected
+		return nil
+	})
+	mock.sendResp = tgbotapi.Message{MessageID: 10}
+	if err := ms.Show(ctx, Event{ChatID: -100}); err != nil {
+		t.Fatal(err)
+	}
+	markup := mock.lastMarkup(t)
+	tap := func(userID int64, label string) tgbotapi.Update {
+		u := withMessageID(tapUpdate(-100, markup, label), 10)
+		u.CallbackQuery.From = &tgbotapi.User{ID: userID}
+		u.CallbackQuery.Message.Chat.Type = "group"
+		return u
+	}
+
+	// Each member holds their own lock, so the taps run concurrently; an odd
+	// number of taps leaves every option checked.
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, option := range options {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for range 201 {
+				bot.handleUpdate(ctx, c, tap(int64(i+1), option))
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	bot.handleUpdate(ctx, c, tap(1, "Done"))
+	if strings.Join(got, ",") != strings.Join(options, ",") {
+		t.Fatalf("want every tap counted, got %v", got)
+	}
+}
+
+func TestMultiSelect_Validation(t *testing.T) {
+	bot, _ := newTestBot()
+	if err := bot.NewMultiSelect("x", nil, nil).Show(context.Background(), Event{}); err == nil {
+		t.Fatal("expected error without options")
+	}
+	if err := bot.NewMultiSelect("x", []string{"a"}, nil).Show(context.Background(), Event{}); err == nil {
+		t.Fatal("expected error without done handler")
+	}
+}
+
+func TestLayer_StickySurvivesDispatch(t *testing.T) {
+	bot, _ := newTestBot()
+	l := bot.NewLayer()
+	l.SetSticky()
+	bot.setLayer(l, 5, 0)
+
+	if got := bot.findAndWipeLayer(Event{ChatID: 5}); got != l {
+		t.Fatal("sticky layer not returned")
+	}
+	if got := bot.findAndWipeLayer(Event{ChatID: 5}); got != l {
+		t.Fatal("sticky layer was wiped")
+	}
+}
@@ -0,0 +1,160 @@
+package bf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxNavDepth caps a navigation history; the oldest screens are
+// forgotten first.
+const maxNavDepth = 32
+
+// defaultBackButtonText labels the button PushLayer adds to nested screens.
+const defaultBackButtonText = "« Back"
+
+// navStack is the screen history of one chat, or of one group member (keyed
+// like their chatController lock). touched drives expiry in the cleaner.
+type navStack struct {
+	layers  []*HandlerLayer
+	touched time.Time
+}
+
+// PushLayer shows layer as a new screen on top of the chat's navigation
+// stack; in groups every member navigates a stack of their own. Every screen
+// above the root gets an automatic Back button (WithBackButtonText) that
+// returns to the previous screen via PopLayer.
+//
+// When event is an inline-button tap, the message carrying the tapped
+// keyboard is edited in place, so a multi-level menu stays one message.
+// Otherwise a new message is sent. Either way the screen is installed as
+// the chat's layer, exactly like SendMsg.
+//
+// The stack only grows through PushLayer, and only by screens that were
+// delivered; call ResetNavigation when entering the menu from its root
+// (e.g. in the /settings command handler).
+//
+// The pushed layer is kept and re-shown as-is on Back, so build it once per
+// screen and do not mutate it afterwards.
+func (b *ChatBotImpl) PushLayer(event Event, layer *HandlerLayer) error {
+	if layer == nil {
+		return errors.New("PushLayer: layer is nil")
+	}
+
+	key := lockKey(event)
+	b.navMutex.Lock()
+	depth := len(b.navStacks[key].layers) + 1
+	b.navMutex.Unlock()
+
+	// The screen joins the history only once it is shown, so a failed send
+	// leaves no phantom screen for Back to return to.
+	if err := b.showScreen(event, layer, depth > 1); err != nil {
+		return err
+	}
+
+	b.navMutex.Lock()
+	stack := b.navStacks[key]
+	stack.layers = append(stack.layers, layer)
+	if len(stack.layers) > maxNavDepth {
+		stack.layers = stack.layers[len(stack.layers)-maxNavDepth:]
+	}
+	stack.touched = time.Now()
+	b.navStacks[key] = stack
+	b.navMutex.Unlock()
+
+	return nil
+}
+
+// PopLayer drops the current screen and shows the previous one, editing in
+// place when event is an inline-button tap. It is what the automatic Back
+// button calls; invoke it directly for a custom "Done" or "Cancel" button.
+func (b *ChatBotImpl) PopLayer(event Event) error {
+	key := lockKey(event)
+	b.navMutex.Lock()
+	stack := b.navStacks[key]
+	if len(stack.layers) < 2 {
+		b.navMutex.Unlock()
+		return fmt.Errorf("PopLayer: no previous screen for chat %d", event.ChatID)
+	}
+	depth := len(stack.layers) - 1
+	top := stack.layers[depth-1]
+	b.navMutex.Unlock()
+
+	// Like PushLayer, the history changes only once the screen is shown.
+	if err := b.showScreen(event, top, depth > 1); err != nil {
+		return err
+	}
+
+	b.navMutex.Lock()
+	stack = b.navStacks[key]
+	if len(stack.layers) > depth {
+		stack.layers = stack.layers[:depth]
+	}
+	stack.touched = time.Now()
+	b.navStacks[key] = stack
+	b.navMutex.Unlock()
+
+	return nil
+}
+
+// ResetNavigation forgets the chat's screen history; in a group, every
+// member's. The WithMenus commands reset only the sender's history.
+func (b *ChatBotImpl) ResetNavigation(chatID int64) {
+	b.navMutex.Lock()
+	for key := range b.navStacks {
+		if key.chatID == chatID {
+			delete(b.navStacks, key)
+		}
+	}
+	b.navMutex.Unlock()
+}
+
+// resetNavigation forgets the screen history of the event's sender only.
+func (b *ChatBotImpl) resetNavigation(event Event) {
+	b.navMutex.Lock()
+	delete(b.navStacks, lockKey(event))
+	b.navMutex.Unlock()
+}
+
+// showScreen renders a copy of layer (with a Back button when withBack) and
+// installs it, editing the tapped message when possible.
+func (b *ChatBotImpl) showScreen(event Event, layer *HandlerLayer, withBack bool) error {
+	screen := layer.clone()
+	screen.ttl = time.Now().Add(b.defaultTTL)
+
+	if withBack {
+		back := func(_ context.Context, ev Event) error { return b.PopLayer(ev) }
+		if len(screen.buttonTextHandler) > 0 {
+			screen.RegisterButton(b.backButtonText, back)
+		} else {
+			screen.RegisterIButton(b.backButtonText, back)
+		}
+	}
+
+	return b.presentLayer(event, screen)
+}
+
+// presentLayer edits the message carrying the tapped keyboard when event is
+// an inline-button tap and the layer has no reply keyboard or ForceReply;
+// otherwise it sends a new message. Either way the layer is installed.
+func (b *ChatBotImpl) presentLayer(event Event, layer *HandlerLayer) error {
+	if event.Kind == EventKindInlineButton && event.MessageID != 0 &&
+		len(layer.buttonTextHandler) == 0 && layer.forceReply == nil {
+		return b.editMsg(eventRecipient(event), event.MessageID, layer)
+	}
+
+	return b.Reply(event, layer)
+}
+
+// sweepExpiredNavigation forgets stacks untouched for longer than the layer TTL.
+func (b *ChatBotImpl) sweepExpiredNavigation() {
+	b.navMutex.Lock()
+	defer b.navMutex.Unlock()
+
+	for key, stack := range b.navStacks {
+		if time.Since(stack.touched) > b.defaultTTL {
+			delete(b.navStacks, key)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package bf
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// withMessageID gives the callback's carrier message an id so screens edit in place.
+func withMessageID(u tgbotapi.Update, id int) tgbotapi.Update {
+	u.CallbackQuery.Message.MessageID = id
+	return u
+}
+
+func TestNavigation_PushEditsInPlaceAndBackPops(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+	ctx := context.Background()
+
+	root := bot.NewLayer("Settings")
+	privacy := bot.NewLayer("Privacy")
+	root.RegisterIButton("Privacy", func(_ context.Context, ev Event) error {
+		return bot.PushLayer(ev, privacy)
+	})
+
+	bot.RegisterCommand("/settings", func(_ context.Context, ev Event) error {
+		bot.ResetNavigation(ev.ChatID)
+		return bot.PushLayer(ev, root)
+	})
+
+	bot.handleUpdate(ctx, c, cmdUpdate(42, "/settings"))
+	rootMsg := mock.lastSent().(tgbotapi.MessageConfig)
+	for _, row := range rootMsg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup).InlineKeyboard {
+		if row[0].Text == defaultBackButtonText {
+			t.Fatal("root screen must not have a Back button")
+		}
+	}
+
+	bot.handleUpdate(ctx, c, withMessageID(tapUpdate(42, mock.lastMarkup(t), "Privacy"), 500))
+	edit, ok := mock.lastSent().(tgbotapi.EditMessageTextConfig)
+	if !ok || edit.MessageID != 500 || edit.Text != "Privacy" {
+		t.Fatalf("want in-place edit of message 500, got %+v", mock.lastSent())
+	}
+	backRow := edit.ReplyMarkup.InlineKeyboard[len(edit.ReplyMarkup.InlineKeyboard)-1]
+	if backRow[0].Text != defaultBackButtonText {
+		t.Fatalf("nested screen lacks Back button: %+v", edit.ReplyMarkup)
+	}
+	if len(privacy.buttonHandler) != 0 {
+		t.Fatal("Back button leaked into the pushed layer")
+	}
+
+	bot.handleUpdate(ctx, c, withMessageID(tapUpdate(42, *edit.ReplyMarkup, defaultBackButtonText), 500))
+	edit, ok = mock.lastSent().(tgbotapi.EditMessageTextConfig)
+	if !ok || edit.Text != "Settings" {
+		t.Fatalf("Back did not restore root: %+v", mock.lastSent())
+	}
+}
+
+func TestNavigation_PopWithoutHistory(t *testing.T) {
+	bot, _ := newTestBot()
+	if err := bot.PopLayer(Event{ChatID: 1}); err == nil {
+		t.Fatal("expected error when nothing to pop")
+	}
+	if err := bot.PushLayer(Event{ChatID: 1}, nil); err == nil {
+		t.Fatal("expected error for nil layer")
+	}
+}
+
+func TestNavigation_ReplyKeyboardScreenGetsReplyBack(t *testing.T) {
+	bot, mock := newTestBot()
+	ev := Event{ChatID: 3}
+
+	_ = bot.PushLayer(ev, bot.NewLayer("root"))
+	child := bot.NewLayer("child")
+	child.RegisterButton("A", func(context.Context, Event) error { return nil })
+	if err := bot.PushLayer(ev, child); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := mock.lastSent().(tgbotapi.MessageConfig)
+	kb, ok := msg.ReplyMarkup.(tgbotapi.ReplyKeyboardMarkup)
+	if !ok || kb.Keyboard[0][len(kb.Keyboard[0])-1].Text != defaultBackButtonText {
+		t.Fatalf("want reply-keyboard Back, got %+v", msg.ReplyMarkup)
+	}
+}
+
+func TestNavigation_FailedSendLeavesHistoryAlone(t *testing.T) {
+	bot, mock := newTestBot()
+	ev := Event{ChatID: 5}
+
+	if err := bot.PushLayer(ev, bot.NewLayer("root")); err != nil {
+		t.Fatal(err)
+	}
+	mock.sendErr = errors.New("network down")
+	if err := bot.PushLayer(ev, bot.NewLayer("child")); err == nil {
+		t.Fatal("want the send error")
+	}
+	mock.sendErr = nil
+	if err := bot.PopLayer(ev); err == nil {
+		t.Fatal("the unsent screen was kept as history")
+	}
+
+	if err := bot.PushLayer(ev, bot.NewLayer("child")); err != nil {
+		t.Fatal(err)
+	}
+	mock.sendErr = errors.New("network down")
+	if err := bot.PopLayer(ev); err == nil {
+		t.Fatal("want the send error")
+	}
+	mock.sendErr = nil
+	if got := len(bot.navStacks[chatKey(5)].layers); got != 2 {
+		t.Fatalf("failed Back changed the history to %d screens", got)
+	}
+}
+
+func TestNavigation_GroupMembersHaveOwnStacks(t *testing.T) {
+	bot, mock := newTestBot()
+	alice := Event{ChatID: -100, UserTGID: 1}
+	bob := Event{ChatID: -100, UserTGID: 2}
+
+	_ = bot.PushLayer(alice, bot.NewLayer("Alice root"))
+	_ = bot.PushLayer(alice, bot.NewLayer("Alice child"))
+	if err := bot.PushLayer(bob, bot.NewLayer("Bob root")); err != nil {
+		t.Fatal(err)
+	}
+	if msg := mock.lastSent().(tgbotapi.MessageConfig); msg.ReplyMarkup != nil {
+		t.Fatalf("Bob's first screen got Alice's history: %+v", msg.ReplyMarkup)
+	}
+
+	if err := bot.PopLayer(alice); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastText(t, mock); got != "Alice root" {
+		t.Fatalf("Back for Alice showed %q", got)
+	}
+	if err := bot.PopLayer(bob); err == nil {
+		t.Fatal("Bob has no previous screen")
+	}
+
+	bot.ResetNavigation(-100)
+	if len(bot.navStacks) != 0 {
+		t.Fatalf("ResetNavigation kept members' stacks: %v", bot.navStacks)
+	}
+}
+
+func TestNavigation_DepthCappedAndSwept(t *testing.T) {
+	bot, _ := newTestBot()
+	ev := Event{ChatID: 9}
+	for range maxNavDepth + 5 {
+		_ = bot.PushLayer(ev, bot.NewLayer("x"))
+	}
+	if got := len(bot.navStacks[chatKey(9)].layers); got != maxNavDepth {
+		t.Fatalf("want depth %d, got %d", maxNavDepth, got)
+	}
+
+	bot.navMutex.Lock()
+	stack := bot.navStacks[chatKey(9)]
+	stack.touched = time.Now().Add(-2 * bot.defaultTTL)
+	bot.navStacks[chatKey(9)] = stack
+	bot.navMutex.Unlock()
+
+	bot.sweepExpiredNavigation()
+	if _, ok := bot.navStacks[chatKey(9)]; ok {
+		t.Fatal("stale stack not swept")
+	}
+}
+
+func TestWithBackButtonText(t *testing.T) {
+	bot := newSkeleton([]BotOption{WithBackButtonText("Up")})
+	if bot.backButtonText != "Up" {
+		t.Fatalf("want Up, got %q", bot.backButtonText)
+	}
+	bot = newSkeleton([]BotOption{WithBackButtonText("")})
+	if bot.backButtonText != defaultBackButtonText {
+		t.Fatal("empty text must be ignored")
+	}
+}
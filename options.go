@@ -24,13 +24,24 @@ func WithLogger(logger Logger) BotOption {
 }
 
 // WithParseMode sets the parse mode applied to messages sent via SendMsg.
-// Accepts tgbotapi.ModeMarkdown, ModeMarkdownV2 or ModeHTML (default).
+// Accepts tgbotapi.ModeMarkdown, ModeMarkdownV2, ModeHTML (default) or
+// ModeEntities, which sends no parse mode and formats RichText with entities.
 func WithParseMode(parseMode string) BotOption {
 	return func(bot *ChatBotImpl) {
 		bot.parseMode = parseMode
 	}
 }
 
+// WithPlainTextFallback makes a text whose markup is invalid go out without
+// formatting instead of failing the send: when ValidateText refuses it, or
+// when Telegram cannot parse its entities. The offending text is logged at
+// error level, so a typo in a template does not break a flow.
+func WithPlainTextFallback() BotOption {
+	return func(bot *ChatBotImpl) {
+		bot.plainTextFallback = true
+	}
+}
+
 // WithLayerTTL overrides how long a chat-specific layer stays active before
 // being garbage-collected by the cleaner. Default is 24 hours.
 //
@@ -56,3 +67,86 @@ func WithUpdateConcurrency(n int) BotOption {
 		}
 	}
 }
+
+// WithSessionStore replaces the in-memory SessionStore used to keep partial
+// form answers and other per-chat state. A nil store is ignored.
+func WithSessionStore(store SessionStore) BotOption {
+	return func(bot *ChatBotImpl) {
+		if store != nil {
+			bot.sessionStore = store
+		}
+	}
+}
+
+// WithBackButtonText sets the label of the Back button PushLayer adds to
+// nested screens. Default is "« Back". An empty text is ignored.
+func WithBackButtonText(text string) BotOption {
+	return func(bot *ChatBotImpl) {
+		if text != "" {
+			bot.backButtonText = text
+		}
+	}
+}
+
+// WithCallbackSecret signs the callback data of every inline button with a
+// truncated HMAC-SHA256 of secret (8 extra bytes), and verifies it on every
+// tap before any handler runs. The signature covers the chat, and the user
+// of a ScopeUser layer, so buttons cannot be replayed elsewhere. Forged,
+// altered or foreign callbacks are reported to the error handler as
+// ErrForgedCallback.
+//
+// Signing leaves 56 of Telegram's 64 callback bytes for payloads. Buttons on
+// messages sent before the secret was set or changed stop working.
+func WithCallbackSecret(secret []byte) BotOption {
+	return func(bot *ChatBotImpl) {
+		bot.callbackSecret = secret
+	}
+}
+
+// WithMessageLayerTTL sets how long the inline buttons of a sent or edited
+// message keep their handlers, independently of the chat's next-message
+// layer. Default is 7 days; a chat's 100 latest keyboard messages are kept
+// at most. A non-positive d is logged and ignored.
+func WithMessageLayerTTL(d time.Duration) BotOption {
+	return func(bot *ChatBotImpl) {
+		if d <= 0 {
+			bot.logger.Errorf("WithMessageLayerTTL: non-positive duration %v ignored", d)
+			return
+		}
+		bot.messageLayerTTL = d
+	}
+}
+
+// WithStaleButtonText sets the notice shown when the user taps an inline
+// button nothing handles any more (consumed, expired or from before a
+// restart). Default is "This button has expired."; an empty text only stops
+// the button's loading spinner.
+func WithStaleButtonText(text string) BotOption {
+	return func(bot *ChatBotImpl) {
+		bot.staleButtonText = text
+	}
+}
+
+// WithStaleKeyboardRemoval additionally strips the dead inline keyboard from
+// the message when a stale button is tapped.
+func WithStaleKeyboardRemoval() BotOption {
+	return func(bot *ChatBotImpl) {
+		bot.removeStaleKeyboard = true
+	}
+}
+
+// WithStaleButtonHook registers a function called for every stale button
+// tap, e.g. to feed metrics. Stale taps never reach the default handler.
+func WithStaleButtonHook(hook StaleButtonHookFunc) BotOption {
+	return func(bot *ChatBotImpl) {
+		bot.staleButtonHook = hook
+	}
+}
+
+// WithReplyKeyboardOptions sets the ReplyKeyboardOptions of every reply
+// keyboard; HandlerLayer.SetKeyboardOptions overrides them per layer.
+func WithReplyKeyboardOptions(opts ReplyKeyboardOptions) BotOption {
+	return func(bot *ChatBotImpl) {
+		bot.keyboardOptions = opts
+	}
+}
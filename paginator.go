@@ -0,0 +1,184 @@
+package bf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Telegram inline-keyboard limits the paginator stays within.
+const (
+	// maxInlineKeyboardButtons is the most buttons Telegram accepts in one
+	// inline keyboard.
+	maxInlineKeyboardButtons = 100
+	// pageNumberWindow is how many page-number buttons surround the current page.
+	pageNumberWindow = 5
+	// maxPageControls is Prev + the page-number window + Next.
+	maxPageControls = pageNumberWindow + 2
+	// maxPageSize leaves room for the control row under the item buttons.
+	maxPageSize = maxInlineKeyboardButtons - maxPageControls
+)
+
+// PageItem is one entry of a paginated list. An item with a Handler renders
+// as an inline button (one per row); an item without one renders as a line
+// of the message text.
+type PageItem struct {
+	Text    string
+	Handler HandlerFunc
+}
+
+// PageSource feeds a Paginator. Count is called on every render so the list
+// may change between pages; Page returns at most limit items from offset.
+type PageSource interface {
+	Count(ctx context.Context) (int, error)
+	Page(ctx context.Context, offset, limit int) ([]PageItem, error)
+}
+
+// SlicePageSource serves a fixed, in-memory list.
+type SlicePageSource []PageItem
+
+// Count implements PageSource.
+func (s SlicePageSource) Count(context.Context) (int, error) { return len(s), nil }
+
+// Page implements PageSource.
+func (s SlicePageSource) Page(_ context.Context, offset, limit int) ([]PageItem, error) {
+	if offset >= len(s) {
+		return nil, nil
+	}
+
+	return s[offset:min(offset+limit, len(s))], nil
+}
+
+// Paginator renders a long list a page at a time with Prev (‹), page-number
+// and Next (›) controls. Navigating edits the message in place.
+//
+// Each page is an ordinary HandlerLayer; item buttons are consumed with it,
+// so an item handler typically shows details via PushLayer or SendMsg.
+// Pages never exceed Telegram's inline-keyboard limits: the page size is
+// clamped so items plus controls fit in one keyboard.
+type Paginator struct {
+	bot       *ChatBotImpl
+	source    PageSource
+	pageSize  int
+	header    string
+	emptyText string
+	prevText  string
+	nextText  string
+}
+
+// NewPaginator creates a paginator over source showing pageSize items per
+// page. pageSize is clamped to [1, 93].
+func (b *ChatBotImpl) NewPaginator(source PageSource, pageSize int) *Paginator {
+	return &Paginator{
+		bot:       b,
+		source:    source,
+		pageSize:  max(1, min(pageSize, maxPageSize)),
+		emptyText: "Nothing here yet.",
+		prevText:  "‹",
+		nextText:  "›",
+	}
+}
+
+// SetHeader sets the text shown above the items on every page.
+func (p *Paginator) SetHeader(text string) {
+	p.header = text
+}
+
+// SetEmptyText sets the text shown when the source has no items.
+func (p *Paginator) SetEmptyText(text string) {
+	p.emptyText = text
+}
+
+// SetNavTexts overrides the Prev / Next button labels.
+func (p *Paginator) SetNavTexts(prev, next string) {
+	p.prevText = prev
+	p.nextText = next
+}
+
+// Show renders the first page. When event is an inline-button tap the tapped
+// message is edited, otherwise a new message is sent.
+func (p *Paginator) Show(ctx context.Context, event Event) error {
+	return p.ShowPage(ctx, event, 0)
+}
+
+// ShowPage renders the zero-based page, clamped to the available range.
+func (p *Paginator) ShowPage(ctx context.Context, event Event, page int) error {
+	if p.source == nil {
+		return errors.New("paginator has no source")
+	}
+
+	total, err := p.source.Count(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count paginator items: %w", err)
+	}
+
+	pages := max(1, (total+p.pageSize-1)/p.pageSize)
+	page = max(0, min(page, pages-1))
+
+	items, err := p.source.Page(ctx, page*p.pageSize, p.pageSize)
+	if err != nil {
+		return fmt.Errorf("failed to fetch paginator page %d: %w", page, err)
+	}
+
+	layer := p.bot.NewLayer()
+	if p.header != "" {
+		layer.AddText(p.header)
+	}
+	if total == 0 {
+		layer.AddText(p.emptyText)
+	}
+
+	rows := make([]int, 0, len(items)+1)
+	for _, item := range items {
+		if item.Handler == nil {
+			layer.AddText(item.Text)
+			continue
+		}
+		layer.RegisterIButton(item.Text, item.Handler)
+		rows = append(rows, 1)
+	}
+
+	if pages > 1 {
+		rows = append(rows, p.addControls(layer, page, pages))
+	}
+	layer.SetIButtonRows(rows...)
+
+	return p.bot.presentLayer(event, layer)
+}
+
+// addControls registers the navigation row and returns its button count.
+func (p *Paginator) addControls(layer *HandlerLayer, page, pages int) int {
+	goTo := func(target int) HandlerFunc {
+		return func(ctx context.Context, event Event) error {
+			return p.ShowPage(ctx, event, target)
+		}
+	}
+
+	count := 0
+	if page > 0 {
+		layer.RegisterIButton(p.prevText, goTo(page-1))
+		count++
+	}
+
+	end := min(pages, max(0, page-pageNumberWindow/2)+pageNumberWindow)
+	start := max(0, end-pageNumberWindow)
+	for i := start; i < end; i++ {
+		label := strconv.Itoa(i + 1)
+		if i == page {
+			// Re-rendering the same page would fail with "message is not
+			// modified"; keep the page alive instead.
+			layer.RegisterIButton("· "+label+" ·", p.bot.keepLayerHandler(layer))
+		} else {
+			layer.RegisterIButton(label, goTo(i))
+		}
+		count++
+	}
+
+	if page < pages-1 {
+		layer.RegisterIButton(p.nextText, goTo(page+1))
+		count++
+	}
+
+	return count
+}
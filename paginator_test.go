@@ -0,0 +1,125 @@
+package bf
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func numberedItems(n int, handler HandlerFunc) SlicePageSource {
+	items := make(SlicePageSource, 0, n)
+	for i := range n {
+		items = append(items, PageItem{Text: "item " + strconv.Itoa(i+1), Handler: handler})
+	}
+	return items
+}
+
+func rowTexts(row []tgbotapi.InlineKeyboardButton) []string {
+	texts := make([]string, 0, len(row))
+	for _, b := range row {
+		texts = append(texts, b.Text)
+	}
+	return texts
+}
+
+func TestPaginator_FirstPageLayout(t *testing.T) {
+	bot, mock := newTestBot()
+	noop := func(context.Context, Event) error { return nil }
+	p := bot.NewPaginator(numberedItems(23, noop), 5)
+	p.SetHeader("Orders")
+
+	if err := p.Show(context.Background(), Event{ChatID: 42}); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := mock.lastSent().(tgbotapi.MessageConfig)
+	if msg.Text != "Orders" {
+		t.Fatalf("header: %q", msg.Text)
+	}
+	kb := mock.lastMarkup(t).InlineKeyboard
+	if len(kb) != 6 {
+		t.Fatalf("want 5 item rows + controls, got %d rows", len(kb))
+	}
+	if got := strings.Join(rowTexts(kb[5]), " "); got != "· 1 · 2 3 4 5 ›" {
+		t.Fatalf("controls: %q", got)
+	}
+}
+
+func TestPaginator_NextEditsInPlace(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+	p := bot.NewPaginator(numberedItems(23, nil), 10)
+
+	if err := p.Show(context.Background(), Event{ChatID: 42}); err != nil {
+		t.Fatal(err)
+	}
+	bot.handleUpdate(context.Background(), c, withMessageID(tapUpdate(42, mock.lastMarkup(t), "›"), 77))
+
+	edit, ok := mock.lastSent().(tgbotapi.EditMessageTextConfig)
+	if !ok || edit.MessageID != 77 {
+		t.Fatalf("want edit of message 77, got %T", mock.lastSent())
+	}
+	if !strings.HasPrefix(edit.Text, "item 11\n") {
+		t.Fatalf("page 2 text: %q", edit.Text)
+	}
+	controls := rowTexts(edit.ReplyMarkup.InlineKeyboard[0])
+	if strings.Join(controls, " ") != "‹ 1 · 2 · 3 ›" {
+		t.Fatalf("controls: %v", controls)
+	}
+}
+
+func TestPaginator_WindowAndClamp(t *testing.T) {
+	bot, mock := newTestBot()
+	p := bot.NewPaginator(numberedItems(100, nil), 1)
+
+	if err := p.ShowPage(context.Background(), Event{ChatID: 1}, 500); err != nil {
+		t.Fatal(err)
+	}
+	kb := mock.lastMarkup(t).InlineKeyboard
+	if got := strings.Join(rowTexts(kb[0]), " "); got != "‹ 96 97 98 99 · 100 ·" {
+		t.Fatalf("last-page controls: %q", got)
+	}
+
+	if got := bot.NewPaginator(nil, 1000).pageSize; got != maxPageSize {
+		t.Fatalf("page size not clamped: %d", got)
+	}
+	if got := bot.NewPaginator(nil, 0).pageSize; got != 1 {
+		t.Fatalf("page size lower bound: %d", got)
+	}
+}
+
+func TestPaginator_EmptyAndErrors(t *testing.T) {
+	bot, mock := newTestBot()
+
+	if err := bot.NewPaginator(SlicePageSource{}, 5).Show(context.Background(), Event{ChatID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := mock.lastSent().(tgbotapi.MessageConfig).Text; got != "Nothing here yet." {
+		t.Fatalf("empty text: %q", got)
+	}
+
+	if err := bot.NewPaginator(nil, 5).Show(context.Background(), Event{}); err == nil {
+		t.Fatal("expected error for nil source")
+	}
+	if err := bot.NewPaginator(failingSource{}, 5).Show(context.Background(), Event{}); err == nil {
+		t.Fatal("expected count error")
+	}
+}
+
+type failingSource struct{}
+
+func (failingSource) Count(context.Context) (int, error) { return 0, errors.New("db down") }
+func (failingSource) Page(context.Context, int, int) ([]PageItem, error) {
+	return nil, nil
+}
+
+func TestSplitRows(t *testing.T) {
+	got := splitRows([]int{1, 2, 3, 4, 5}, []int{2, 0, 1})
+	if len(got) != 4 || len(got[0]) != 2 || len(got[1]) != 1 || len(got[3]) != 1 {
+		t.Fatalf("unexpected rows: %v", got)
+	}
+}
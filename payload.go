@@ -0,0 +1,152 @@
+package bf
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// maxCallbackDataLen is Telegram's limit on an inline button's callback_data.
+const maxCallbackDataLen = 64
+
+const (
+	payloadSeparator = ':'
+	payloadEscape    = '\\'
+)
+
+// CallbackPayload is structured data carried by an inline button instead of
+// an opaque id: a route naming the action and a list of values, encoded
+// compactly as "route:v1:v2" (e.g. "item:42"). Values keep their text form;
+// the typed accessors parse them back.
+//
+// Buttons registered with RegisterIButtonData deliver their decoded payload
+// as Event.Payload, and a route handler (RegisterIButtonRoute) can serve
+// every button of a route, including buttons on messages sent long ago.
+type CallbackPayload struct {
+	Route  string   `json:"route"`
+	Values []string `json:"values,omitempty"`
+}
+
+// Payload builds a CallbackPayload. Values are formatted with fmt.Sprint,
+// so strings, integers, floats and booleans round-trip through the typed
+// accessors.
+func Payload(route string, values ...any) CallbackPayload {
+	p := CallbackPayload{Route: route, Values: make([]string, 0, len(values))}
+	for _, v := range values {
+		p.Values = append(p.Values, fmt.Sprint(v))
+	}
+
+	return p
+}
+
+// Len returns the number of values.
+func (p CallbackPayload) Len() int {
+	return len(p.Values)
+}
+
+// String returns value i, or "" when there is no such value.
+func (p CallbackPayload) String(i int) string {
+	if i < 0 || i >= len(p.Values) {
+		return ""
+	}
+
+	return p.Values[i]
+}
+
+// Int64 parses value i as a base-10 integer.
+func (p CallbackPayload) Int64(i int) (int64, error) {
+	n, err := strconv.ParseInt(p.String(i), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse payload value %d as int: %w", i, err)
+	}
+
+	return n, nil
+}
+
+// Bool parses value i as a boolean.
+func (p CallbackPayload) Bool(i int) (bool, error) {
+	b, err := strconv.ParseBool(p.String(i))
+	if err != nil {
+		return false, fmt.Errorf("failed to parse payload value %d as bool: %w", i, err)
+	}
+
+	return b, nil
+}
+
+// Encode renders the payload as callback data. The route must be non-empty
+// and free of ':' and '\'; values may contain anything (separators are
+// escaped). The result must fit Telegram's 64-byte callback_data limit.
+func (p CallbackPayload) Encode() (string, error) {
+	if p.Route == "" {
+		return "", errors.New("callback payload route is empty")
+	}
+	if strings.ContainsAny(p.Route, string([]rune{payloadSeparator, payloadEscape})) {
+		return "", fmt.Errorf("callback payload route %q contains ':' or '\\'", p.Route)
+	}
+	if isButtonID(p.Route) {
+		return "", fmt.Errorf("callback payload route %q looks like a button id", p.Route)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(p.Route)
+	for _, v := range p.Values {
+		sb.WriteByte(payloadSeparator)
+		for _, r := range v {
+			if r == payloadSeparator || r == payloadEscape {
+				sb.WriteByte(payloadEscape)
+			}
+			sb.WriteRune(r)
+		}
+	}
+
+	data := sb.String()
+	if len(data) > maxCallbackDataLen {
+		return "", fmt.Errorf("callback payload %q is %d bytes, limit is %d", data, len(data), maxCallbackDataLen)
+	}
+
+	return data, nil
+}
+
+// decodePayload parses callback data produced by Encode. Opaque button ids
+// (RegisterIButton's UUIDs) and empty data are not payloads.
+func decodePayload(data string) (CallbackPayload, bool) {
+	if data == "" || isButtonID(data) {
+		return CallbackPayload{}, false
+	}
+
+	var (
+		parts   []string
+		current strings.Builder
+		escaped bool
+	)
+	for _, r := range data {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == payloadEscape:
+			escaped = true
+		case r == payloadSeparator:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	parts = append(parts, current.String())
+
+	if parts[0] == "" {
+		return CallbackPayload{}, false
+	}
+
+	return CallbackPayload{Route: parts[0], Values: parts[1:]}, true
+}
+
+// isButtonID reports whether data is an opaque id minted by RegisterIButton.
+func isButtonID(data string) bool {
+	_, err := uuid.Parse(data)
+	return err == nil
+}
@@ -0,0 +1,139 @@
+package bf
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/google/uuid"
+)
+
+func TestPayload_EncodeDecodeRoundTrip(t *testing.T) {
+	data, err := Payload("item", 42, true, "a:b\\c").Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != `item:42:true:a\:b\\c` {
+		t.Fatalf("encoded: %q", data)
+	}
+
+	p, ok := decodePayload(data)
+	if !ok || p.Route != "item" || p.Len() != 3 {
+		t.Fatalf("decoded: ok=%v %+v", ok, p)
+	}
+	if n, err := p.Int64(0); err != nil || n != 42 {
+		t.Fatalf("Int64: %d %v", n, err)
+	}
+	if b, err := p.Bool(1); err != nil || !b {
+		t.Fatalf("Bool: %v %v", b, err)
+	}
+	if p.String(2) != "a:b\\c" || p.String(9) != "" {
+		t.Fatalf("String: %q / %q", p.String(2), p.String(9))
+	}
+	if _, err := p.Int64(2); err == nil {
+		t.Fatal("expected parse error for non-numeric value")
+	}
+}
+
+func TestPayload_EncodeRejects(t *testing.T) {
+	cases := map[string]CallbackPayload{
+		"empty route":   Payload(""),
+		"separator":     Payload("a:b"),
+		"uuid route":    Payload(uuid.NewString()),
+		"over 64 bytes": Payload("item", strings.Repeat("x", 60)),
+	}
+	for name, p := range cases {
+		if _, err := p.Encode(); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestPayload_OpaqueIDsAreNotPayloads(t *testing.T) {
+	if _, ok := decodePayload(uuid.NewString()); ok {
+		t.Fatal("RegisterIButton ids must not decode as payloads")
+	}
+	if p, ok := decodePayload("refresh"); !ok || p.Route != "refresh" || p.Len() != 0 {
+		t.Fatalf("route-only payload: ok=%v %+v", ok, p)
+	}
+}
+
+func TestRegisterIButtonData_DeliversPayload(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	var got *CallbackPayload
+	layer := bot.NewLayer("Products")
+	for _, id := range []int{7, 8} {
+		show := func(_ context.Context, event Event) error {
+			got = event.Payload
+			return nil
+		}
+		if err := layer.RegisterIButtonData("#"+strconv.Itoa(id), Payload("item", id), show); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := layer.RegisterIButtonData("dup", Payload("item", 7), nil); err == nil {
+		t.Fatal("expected duplicate payload error")
+	}
+	if err := bot.SendMsg(42, layer); err != nil {
+		t.Fatal(err)
+	}
+
+	markup := mock.lastMarkup(t)
+	if data := *markup.InlineKeyboard[1][0].CallbackData; data != "item:8" {
+		t.Fatalf("callback data: %q", data)
+	}
+
+	bot.handleUpdate(context.Background(), c, tapUpdate(42, markup, "#8"))
+	if got == nil || got.Route != "item" || got.String(0) != "8" {
+		t.Fatalf("payload: %+v", got)
+	}
+}
+
+func TestRegisterIButtonRoute_ServesOldMessages(t *testing.T) {
+	bot, _ := newTestBot()
+	c := newChatController(context.Background())
+
+	var id int64
+	bot.RegisterIButtonRoute("item", func(_ context.Context, event Event) error {
+		var err error
+		id, err = event.Payload.Int64(0)
+		return err
+	})
+
+	data := "item:99"
+	markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.InlineKeyboardButton{Text: "Old", CallbackData: &data},
+	))
+	bot.handleUpdate(context.Background(), c, tapUpdate(42, markup, "Old"))
+
+	if id != 99 {
+		t.Fatalf("route handler got id %d", id)
+	}
+}
+
+func TestRegisterIButtonData_NilHandlerFallsBackToRoute(t *testing.T) {
+	l := newEmptyLayer()
+	called := false
+	l.RegisterIButtonRoute("page", func(context.Context, Event) error { called = true; return nil })
+	if err := l.RegisterIButtonData("2", Payload("page", 2), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ev := Event{
+		Kind:    EventKindInlineButton,
+		Button:  "page:2",
+		Payload: &CallbackPayload{Route: "page", Values: []string{"2"}},
+	}
+	h := l.Handler(ev)
+	if h == nil {
+		t.Fatal("expected route handler")
+	}
+	_ = h(context.Background(), ev)
+	if !called {
+		t.Fatal("route handler not called")
+	}
+}
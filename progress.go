@@ -0,0 +1,241 @@
+package bf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// progressBarWidth is the number of cells in a rendered progress bar.
+const progressBarWidth = 10
+
+// Progress reports the state of a long-running operation to a chat, either
+// as a placeholder message with a progress bar or as a repeated chat action
+// ("typing…"):
+//
+//	progress, err := bot.Progress(ctx, event.ChatID, "Building report")
+//	if err != nil {
+//	    return err
+//	}
+//	defer progress.Cancel()
+//	for i, part := range parts {
+//	    build(part)
+//	    progress.Set(100*(i+1)/len(parts), part.Name)
+//	}
+//	return progress.Done(bot.NewLayer("Report ready"))
+//
+// Called from a handler, the progress and its result go to the forum topic,
+// and use the locale, of the event being handled.
+//
+// Set may be called as often as convenient; edits are throttled. Progress is
+// safe for concurrent use. Stopping the bot stops every Progress.
+type Progress struct {
+	bot    *ChatBotImpl
+	to     recipient
+	title  string
+	action string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	messageID int
+	percent   int
+	status    string
+	shown     string
+	lastEdit  time.Time
+	flush     *time.Timer
+	finished  bool
+}
+
+// Progress sends a placeholder message with an empty progress bar under
+// title. Update it with Set and replace it with the result using Done.
+func (b *ChatBotImpl) Progress(ctx context.Context, chatID int64, title string) (*Progress, error) {
+	p := b.newProgress(contextRecipient(ctx, chatID), title, "")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	text := p.render()
+	sent, err := b.tgbot.Send(inThread(tgbotapi.NewMessage(chatID, text), p.to.thread))
+	if err != nil {
+		p.cancel()
+		return nil, fmt.Errorf("failed to send progress message: %w", err)
+	}
+	p.messageID = sent.MessageID
+	p.shown = text
+	p.lastEdit = time.Now()
+
+	return p, nil
+}
+
+// ProgressAction shows a chat action such as tgbotapi.ChatTyping or
+// tgbotapi.ChatUploadPhoto, repeated until Done or Cancel, instead of a
+// placeholder message. Set is a no-op in this mode.
+func (b *ChatBotImpl) ProgressAction(ctx context.Context, chatID int64, action string) *Progress {
+	p := b.newProgress(contextRecipient(ctx, chatID), "", action)
+	go p.repeatAction()
+
+	return p
+}
+
+func (b *ChatBotImpl) newProgress(to recipient, title, action string) *Progress {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Progress{bot: b, to: to, title: title, action: action, ctx: ctx, cancel: cancel}
+
+	// Like LoaderButton, tie the progress to the bot's shutdown signal.
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-b.shutdown:
+			p.Cancel()
+		}
+	}()
+
+	return p
+}
+
+func (p *Progress) repeatAction() {
+	ticker := time.NewTicker(progressActionTick())
+	defer ticker.Stop()
+
+	for {
+		p.sendAction()
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Progress) sendAction() {
+	action := actionInThread(tgbotapi.NewChatAction(p.to.chatID, p.action), p.to.thread)
+	if _, err := p.bot.tgbot.Request(action); err != nil {
+		p.bot.logger.Errorf("failed to send chat action: %s", err)
+	}
+}
+
+// Set updates the progress bar to percent (clamped to 0-100) with an
+// optional status line. The message is edited at most once per second; the
+// latest state always makes it out.
+func (p *Progress) Set(percent int, status string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.finished || p.action != "" {
+		return
+	}
+	p.percent = min(max(percent, 0), 100)
+	p.status = status
+
+	if p.flush != nil {
+		return
+	}
+	if wait := progressEditGap() - time.Since(p.lastEdit); wait > 0 {
+		p.flush = time.AfterFunc(wait, p.flushPending)
+		return
+	}
+	p.edit()
+}
+
+func (p *Progress) flushPending() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.flush = nil
+	if !p.finished {
+		p.edit()
+	}
+}
+
+// edit shows the current state; the caller holds p.mu. Unchanged text is
+// skipped, since Telegram rejects edits that change nothing.
+func (p *Progress) edit() {
+	text := p.render()
+	if text == p.shown {
+		return
+	}
+
+	p.lastEdit = time.Now()
+	if _, err := p.bot.tgbot.Send(tgbotapi.NewEditMessageText(p.to.chatID, p.messageID, text)); err != nil {
+		p.bot.logger.Errorf("failed to edit progress message: %s", err)
+		return
+	}
+	p.shown = text
+}
+
+// render draws the title, the bar with its percentage, and the status.
+func (p *Progress) render() string {
+	filled := p.percent * progressBarWidth / 100
+	lines := []string{
+		fmt.Sprintf("%s%s %d%%",
+			strings.Repeat("▓", filled), strings.Repeat("░", progressBarWidth-filled), p.percent),
+	}
+	if p.title != "" {
+		lines = append([]string{p.title}, lines...)
+	}
+	if p.status != "" {
+		lines = append(lines, p.status)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// stop ends the progress; it reports false if it had already ended.
+func (p *Progress) stop() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.finished {
+		return false
+	}
+	p.finished = true
+	if p.flush != nil {
+		p.flush.Stop()
+		p.flush = nil
+	}
+	p.cancel()
+
+	return true
+}
+
+// Cancel stops the progress and leaves the placeholder as it is. It is safe
+// to call after Done, so it can be deferred.
+func (p *Progress) Cancel() {
+	p.stop()
+}
+
+// Done stops the progress and shows layer as its result, installing it like
+// SendMsg. The placeholder is edited into the layer in place; a layer that
+// cannot be edited in (reply keyboard, ForceReply) is sent as a new message
+// and the placeholder deleted. In chat-action mode the layer is sent.
+func (p *Progress) Done(layer *HandlerLayer) error {
+	if layer == nil {
+		return errors.New("Done: layer is nil")
+	}
+	if !p.stop() {
+		return errors.New("progress already finished")
+	}
+
+	if p.action != "" {
+		return p.bot.sendMsg(p.to, layer)
+	}
+	if len(layer.buttonTextHandler) == 0 && layer.forceReply == nil {
+		return p.bot.editMsg(p.to, p.messageID, layer)
+	}
+
+	if err := p.bot.sendMsg(p.to, layer); err != nil {
+		return err
+	}
+	if _, err := p.bot.tgbot.Request(tgbotapi.NewDeleteMessage(p.to.chatID, p.messageID)); err != nil {
+		p.bot.logger.Errorf("failed to delete progress message: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package bf
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// withProgressDelays sets the progress edit gap and action interval for one test.
+func withProgressDelays(t *testing.T, edit, action time.Duration) {
+	t.Helper()
+	origEdit, origAction := progressEditDelay.Load(), progressActionDelay.Load()
+	progressEditDelay.Store(int64(edit))
+	progressActionDelay.Store(int64(action))
+	t.Cleanup(func() {
+		progressEditDelay.Store(origEdit)
+		progressActionDelay.Store(origAction)
+	})
+}
+
+func (m *mockTelegramAPI) edits() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var texts []string
+	for _, c := range m.sent {
+		if edit, ok := c.(tgbotapi.EditMessageTextConfig); ok {
+			texts = append(texts, edit.Text)
+		}
+	}
+	return texts
+}
+
+func (m *mockTelegramAPI) requestsOf(method func(tgbotapi.Chattable) bool) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, c := range m.requests {
+		if method(c) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestProgress_RendersBarAndThrottlesEdits(t *testing.T) {
+	withProgressDelays(t, 50*time.Millisecond, time.Hour)
+	bot, mock := newTestBot()
+	mock.sendResp = tgbotapi.Message{MessageID: 10}
+
+	p, err := bot.Progress(context.Background(), 42, "Report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Cancel()
+	if got := lastText(t, mock); got != "Report\n░░░░░░░░░░ 0%" {
+		t.Fatalf("placeholder: %q", got)
+	}
+
+	// Inside the first gap: coalesced into one edit showing the latest state.
+	p.Set(10, "a")
+	p.Set(20, "b")
+	p.Set(30, "c")
+	if n := len(mock.edits()); n != 0 {
+		t.Fatalf("edited %d times inside the throttle gap", n)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(mock.edits()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("pending progress never flushed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if edits := mock.edits(); len(edits) != 1 || edits[0] != "Report\n▓▓▓░░░░░░░ 30%\nc" {
+		t.Fatalf("edits: %q", edits)
+	}
+
+	// Past the gap, Set edits at once; unchanged text is not re-sent.
+	time.Sleep(60 * time.Millisecond)
+	p.Set(150, "done")
+	p.Set(100, "done")
+	time.Sleep(60 * time.Millisecond)
+	if edits := mock.edits(); len(edits) != 2 || !strings.HasPrefix(edits[1], "Report\n▓▓▓▓▓▓▓▓▓▓ 100%") {
+		t.Fatalf("edits: %q", edits)
+	}
+}
+
+func TestProgress_DoneReplacesPlaceholder(t *testing.T) {
+	withProgressDelays(t, time.Hour, time.Hour)
+	bot, mock := newTestBot()
+	mock.sendResp = tgbotapi.Message{MessageID: 10}
+
+	p, err := bot.Progress(context.Background(), 42, "Working")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Set(50, "half")
+
+	result := bot.NewLayer("Finished")
+	result.RegisterIButton("Open", func(context.Context, Event) error { return nil })
+	if err := p.Done(result); err != nil {
+		t.Fatal(err)
+	}
+
+	edit, ok := mock.lastSent().(tgbotapi.EditMessageTextConfig)
+	if !ok || edit.MessageID != 10 || edit.Text != "Finished" || edit.ReplyMarkup == nil {
+		t.Fatalf("placeholder not replaced: %#v", mock.lastSent())
+	}
+	if bot.peekLayer(chatKey(42)) != result {
+		t.Fatal("result layer not installed")
+	}
+
+	// The throttled "half" edit must not fire after Done.
+	if err := p.Done(result); err == nil {
+		t.Fatal("second Done must fail")
+	}
+	p.Cancel()
+	for _, text := range mock.edits() {
+		if strings.Contains(text, "half") {
+			t.Fatalf("stale progress edit after Done: %q", text)
+		}
+	}
+}
+
+func TestProgress_DoneWithReplyKeyboardSendsAndDeletes(t *testing.T) {
+	bot, mock := newTestBot()
+	mock.sendResp = tgbotapi.Message{MessageID: 10}
+
+	p, err := bot.Progress(context.Background(), 42, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := bot.NewLayer("Pick one")
+	result.RegisterButton("Yes", func(context.Context, Event) error { return nil })
+	if err := p.Done(result); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := lastText(t, mock); got != "Pick one" {
+		t.Fatalf("result not sent: %q", got)
+	}
+	deleted := mock.requestsOf(func(c tgbotapi.Chattable) bool {
+		d, ok := c.(tgbotapi.DeleteMessageConfig)
+		return ok && d.MessageID == 10
+	})
+	if deleted != 1 {
+		t.Fatal("placeholder not deleted")
+	}
+}
+
+func TestProgress_ChatActionMode(t *testing.T) {
+	withProgressDelays(t, time.Hour, 10*time.Millisecond)
+	bot, mock := newTestBot()
+
+	p := bot.ProgressAction(context.Background(), 42, tgbotapi.ChatTyping)
+	p.Set(50, "ignored")
+
+	isTyping := func(c tgbotapi.Chattable) bool {
+		a, ok := c.(tgbotapi.ChatActionConfig)
+		return ok && a.Action == tgbotapi.ChatTyping
+	}
+	deadline := time.Now().Add(time.Second)
+	for mock.requestsOf(isTyping) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("chat action not repeated")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := p.Done(bot.NewLayer("Here you go")); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastText(t, mock); got != "Here you go" {
+		t.Fatalf("result: %q", got)
+	}
+	if n := len(mock.edits()); n != 0 {
+		t.Fatalf("action mode must not edit messages, got %d edits", n)
+	}
+
+	after := mock.requestsOf(isTyping)
+	time.Sleep(40 * time.Millisecond)
+	if mock.requestsOf(isTyping) > after+1 {
+		t.Fatal("chat action kept repeating after Done")
+	}
+}
+
+func TestProgress_StopsWithBot(t *testing.T) {
+	withProgressDelays(t, time.Hour, 5*time.Millisecond)
+	bot, _ := newTestBot()
+
+	p := bot.ProgressAction(context.Background(), 42, tgbotapi.ChatTyping)
+	bot.Stop()
+
+	select {
+	case <-p.ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("progress not stopped by bot shutdown")
+	}
+}
+
+func TestProgress_FollowsHandledTopicAndLocale(t *testing.T) {
+	withProgressDelays(t, time.Hour, 10*time.Millisecond)
+	bot, mock := newTestBot()
+	WithCatalog(testCatalog(t))(bot)
+	mock.sendResp = tgbotapi.Message{MessageID: 10}
+	ctx := context.WithValue(withThread(context.Background(), 5), localeKey{},
+		localizer{catalog: bot.catalog, locale: "ru"})
+
+	p, err := bot.Progress(ctx, -200, "Report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lastThread(t, mock); got != 5 {
+		t.Fatalf("placeholder went to topic %d", got)
+	}
+	menu := bot.NewLayer("Ready")
+	menu.RegisterButton("menu.settings", func(context.Context, Event) error { return nil })
+	if err := p.Done(menu); err != nil {
+		t.Fatal(err)
+	}
+	msg, ok := mock.lastSent().(threadMessage)
+	if !ok || msg.threadID != 5 {
+		t.Fatalf("result not sent to the topic: %#v", mock.lastSent())
+	}
+	if got := msg.ReplyMarkup.(tgbotapi.ReplyKeyboardMarkup).Keyboard[0][0].Text; got != "Настройки" {
+		t.Fatalf("result label %q", got)
+	}
+
+	action := bot.ProgressAction(ctx, -200, tgbotapi.ChatTyping)
+	defer action.Cancel()
+	inTopic := func(c tgbotapi.Chattable) bool {
+		a, ok := c.(threadChatAction)
+		return ok && a.threadID == 5
+	}
+	deadline := time.Now().Add(time.Second)
+	for mock.requestsOf(inTopic) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("chat action not shown in the topic")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
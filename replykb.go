@@ -0,0 +1,132 @@
+package bf
+
+import (
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ReplyKeyboardOptions tune how clients show a layer's reply keyboard.
+// The zero value is the framework default: a keyboard of the standard
+// keyboard height that stays until it is replaced or removed.
+type ReplyKeyboardOptions struct {
+	// OneTime hides the keyboard once a button is used; the user can bring
+	// it back from the input field.
+	OneTime bool
+	// Persistent keeps the keyboard shown even when the user hides it, and
+	// exempts it from the automatic removal described on SetRemoveKeyboard.
+	Persistent bool
+	// Selective shows the keyboard only to users @mentioned in the message
+	// text, or to the sender of the message being replied to.
+	Selective bool
+	// Resize shrinks the keyboard to fit its rows instead of the standard
+	// keyboard height.
+	Resize bool
+	// Placeholder is shown in the input field while the keyboard is active
+	// (1-64 characters).
+	Placeholder string
+}
+
+// shownReplyKeyboard is the reply keyboard last sent to a chat, or by a
+// member's user-scoped layer.
+type shownReplyKeyboard struct {
+	persistent bool
+	sent       time.Time
+}
+
+// persistentReplyKeyboard adds is_persistent, which the vendored
+// tgbotapi.ReplyKeyboardMarkup predates.
+type persistentReplyKeyboard struct {
+	tgbotapi.ReplyKeyboardMarkup
+	IsPersistent bool `json:"is_persistent,omitempty"`
+}
+
+// SetKeyboardOptions applies opts to the layer's reply keyboard, overriding
+// the bot-wide WithReplyKeyboardOptions.
+func (hl *HandlerLayer) SetKeyboardOptions(opts ReplyKeyboardOptions) {
+	hl.keyboardOptions = &opts
+}
+
+// SetRemoveKeyboard makes the layer's message remove the reply keyboard
+// currently shown in the chat. The layer must not have buttons of its own.
+//
+// Removal also happens automatically: a layer without buttons sent after one
+// with a (non-persistent) reply keyboard strips that keyboard, so finished
+// flows do not leave stale buttons behind. Layers with inline buttons leave
+// the keyboard alone. Keyboards older than the layer TTL are forgotten and
+// left alone too. A user-scoped layer (ScopeUser) only removes a keyboard
+// sent by that member's layers, so one member's flow does not strip the
+// keyboard of the whole group.
+func (hl *HandlerLayer) SetRemoveKeyboard() {
+	hl.removeKeyboard = true
+}
+
+// replyKeyboardMarkup renders reply-keyboard rows with the layer's options,
+// falling back to the bot's defaults.
+func (b *ChatBotImpl) replyKeyboardMarkup(layer *HandlerLayer, rows [][]tgbotapi.KeyboardButton) any {
+	opts := b.keyboardOptions
+	if layer.keyboardOptions != nil {
+		opts = *layer.keyboardOptions
+	}
+
+	markup := tgbotapi.NewReplyKeyboard(rows...)
+	markup.ResizeKeyboard = opts.Resize
+	markup.OneTimeKeyboard = opts.OneTime
+	markup.Selective = opts.Selective
+	markup.InputFieldPlaceholder = opts.Placeholder
+
+	if opts.Persistent {
+		return persistentReplyKeyboard{ReplyKeyboardMarkup: markup, IsPersistent: true}
+	}
+
+	return markup
+}
+
+// replyKeyboardRemoval decides whether a message without buttons should
+// carry ReplyKeyboardRemove: when the layer asks for it, or when key still
+// shows a non-persistent reply keyboard from an earlier layer.
+func (b *ChatBotImpl) replyKeyboardRemoval(key scopeKey, layer *HandlerLayer) (any, bool) {
+	b.replyKeyboardsMutex.Lock()
+	keyboard, shown := b.replyKeyboards[key]
+	b.replyKeyboardsMutex.Unlock()
+
+	if !layer.removeKeyboard && (!shown || keyboard.persistent) {
+		return nil, false
+	}
+
+	selective := b.keyboardOptions.Selective
+	if layer.keyboardOptions != nil {
+		selective = layer.keyboardOptions.Selective
+	}
+
+	return tgbotapi.NewRemoveKeyboard(selective), true
+}
+
+// trackReplyKeyboard records what key shows after a message with the given
+// markup was delivered.
+func (b *ChatBotImpl) trackReplyKeyboard(key scopeKey, markup any) {
+	b.replyKeyboardsMutex.Lock()
+	defer b.replyKeyboardsMutex.Unlock()
+
+	switch m := markup.(type) {
+	case tgbotapi.ReplyKeyboardMarkup:
+		b.replyKeyboards[key] = shownReplyKeyboard{sent: time.Now()}
+	case persistentReplyKeyboard:
+		b.replyKeyboards[key] = shownReplyKeyboard{persistent: m.IsPersistent, sent: time.Now()}
+	case tgbotapi.ReplyKeyboardRemove:
+		delete(b.replyKeyboards, key)
+	}
+}
+
+// sweepExpiredReplyKeyboards forgets the reply keyboards sent longer than
+// the layer TTL ago.
+func (b *ChatBotImpl) sweepExpiredReplyKeyboards() {
+	b.replyKeyboardsMutex.Lock()
+	defer b.replyKeyboardsMutex.Unlock()
+
+	for key, keyboard := range b.replyKeyboards {
+		if time.Since(keyboard.sent) > b.defaultTTL {
+			delete(b.replyKeyboards, key)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package bf
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func replyLayer(bot *ChatBotImpl, text string, labels ...string) *HandlerLayer {
+	layer := bot.NewLayer(text)
+	for _, label := range labels {
+		layer.RegisterButton(label, func(context.Context, Event) error { return nil })
+	}
+	return layer
+}
+
+func lastReplyMarkup(t *testing.T, mock *mockTelegramAPI) any {
+	t.Helper()
+	msg, ok := mock.lastSent().(tgbotapi.MessageConfig)
+	if !ok {
+		t.Fatalf("last sent is %T, not a message", mock.lastSent())
+	}
+	return msg.ReplyMarkup
+}
+
+func TestReplyKeyboard_OptionsApplied(t *testing.T) {
+	bot, mock := newTestBot()
+	WithReplyKeyboardOptions(ReplyKeyboardOptions{OneTime: true})(bot)
+
+	if err := bot.SendMsg(42, replyLayer(bot, "Pick", "A", "B")); err != nil {
+		t.Fatal(err)
+	}
+	kb, ok := lastReplyMarkup(t, mock).(tgbotapi.ReplyKeyboardMarkup)
+	if !ok || !kb.OneTimeKeyboard || kb.ResizeKeyboard {
+		t.Fatalf("bot defaults not applied: %#v", lastReplyMarkup(t, mock))
+	}
+
+	layer := replyLayer(bot, "Pick", "A")
+	layer.SetKeyboardOptions(ReplyKeyboardOptions{Resize: true, Selective: true, Placeholder: "Choose…"})
+	if err := bot.SendMsg(42, layer); err != nil {
+		t.Fatal(err)
+	}
+	kb = lastReplyMarkup(t, mock).(tgbotapi.ReplyKeyboardMarkup)
+	if !kb.ResizeKeyboard || kb.OneTimeKeyboard || !kb.Selective || kb.InputFieldPlaceholder != "Choose…" {
+		t.Fatalf("layer options not applied: %#v", kb)
+	}
+}
+
+func TestReplyKeyboard_PersistentSerialises(t *testing.T) {
+	bot, mock := newTestBot()
+
+	layer := replyLayer(bot, "Menu", "Home")
+	layer.SetKeyboardOptions(ReplyKeyboardOptions{Persistent: true})
+	if err := bot.SendMsg(42, layer); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := json.Marshal(lastReplyMarkup(t, mock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), `"is_persistent":true`) || !strings.Contains(string(raw), `"keyboard":[[`) {
+		t.Fatalf("markup json: %s", raw)
+	}
+
+	// A persistent keyboard survives plain follow-up layers.
+	if err := bot.SendMsg(42, bot.NewLayer("Info")); err != nil {
+		t.Fatal(err)
+	}
+	if m := lastReplyMarkup(t, mock); m != nil {
+		t.Fatalf("persistent keyboard removed: %#v", m)
+	}
+}
+
+func TestReplyKeyboard_AutoRemovedAfterFlow(t *testing.T) {
+	bot, mock := newTestBot()
+
+	if err := bot.SendMsg(42, replyLayer(bot, "Yes or no?", "Yes", "No")); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.SendMsg(42, bot.NewLayer("Thanks!")); err != nil {
+		t.Fatal(err)
+	}
+	if rm, ok := lastReplyMarkup(t, mock).(tgbotapi.ReplyKeyboardRemove); !ok || !rm.RemoveKeyboard {
+		t.Fatalf("want keyboard removal, got %#v", lastReplyMarkup(t, mock))
+	}
+
+	// Once removed, later plain layers carry no markup at all; other chats
+	// were never affected.
+	if err := bot.SendMsg(42, bot.NewLayer("Bye")); err != nil {
+		t.Fatal(err)
+	}
+	if m := lastReplyMarkup(t, mock); m != nil {
+		t.Fatalf("unexpected markup: %#v", m)
+	}
+	if err := bot.SendMsg(7, bot.NewLayer("Hi")); err != nil {
+		t.Fatal(err)
+	}
+	if m := lastReplyMarkup(t, mock); m != nil {
+		t.Fatalf("unexpected markup in other chat: %#v", m)
+	}
+}
+
+func TestReplyKeyboard_InlineLayerKeepsIt(t *testing.T) {
+	bot, mock := newTestBot()
+
+	if err := bot.SendMsg(42, replyLayer(bot, "Main menu", "Help", "Settings")); err != nil {
+		t.Fatal(err)
+	}
+	inline := bot.NewLayer("Rate us")
+	inline.RegisterIButton("★", func(context.Context, Event) error { return nil })
+	n := mock.sentCount()
+	if err := bot.SendMsg(42, inline); err != nil {
+		t.Fatal(err)
+	}
+	if got := mock.sentCount() - n; got != 1 {
+		t.Fatalf("want one API call for an inline layer, got %d", got)
+	}
+	if _, ok := lastReplyMarkup(t, mock).(tgbotapi.InlineKeyboardMarkup); !ok {
+		t.Fatalf("want the inline keyboard, got %#v", lastReplyMarkup(t, mock))
+	}
+
+	// The main menu is still shown, so a layer without buttons removes it.
+	if err := bot.SendMsg(42, bot.NewLayer("Thanks!")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lastReplyMarkup(t, mock).(tgbotapi.ReplyKeyboardRemove); !ok {
+		t.Fatalf("want keyboard removal, got %#v", lastReplyMarkup(t, mock))
+	}
+}
+
+func TestReplyKeyboard_MemberLayersKeepGroupKeyboard(t *testing.T) {
+	bot, mock := newTestBot()
+
+	if err := bot.SendMsg(-100, replyLayer(bot, "Main menu", "Help", "Settings")); err != nil {
+		t.Fatal(err)
+	}
+	contact := replyLayer(bot, "Your phone?", "Share")
+	contact.SetScope(ScopeUser(1))
+	if err := bot.SendMsg(-100, contact); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another member's flow ends: neither keyboard is theirs to remove.
+	thanks := bot.NewLayer("Thanks, Bob")
+	thanks.SetScope(ScopeUser(2))
+	if err := bot.SendMsg(-100, thanks); err != nil {
+		t.Fatal(err)
+	}
+	if m := lastReplyMarkup(t, mock); m != nil {
+		t.Fatalf("member 2 removed a keyboard: %#v", m)
+	}
+
+	// Member 1's flow ends: their keyboard goes, the group's menu stays.
+	done := bot.NewLayer("Thanks, Alice")
+	done.SetScope(ScopeUser(1))
+	if err := bot.SendMsg(-100, done); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lastReplyMarkup(t, mock).(tgbotapi.ReplyKeyboardRemove); !ok {
+		t.Fatalf("want member 1's keyboard removed, got %#v", lastReplyMarkup(t, mock))
+	}
+	if _, ok := bot.replyKeyboards[chatKey(-100)]; !ok {
+		t.Fatal("the group's menu keyboard was forgotten")
+	}
+}
+
+func TestReplyKeyboard_ForgottenAfterTTL(t *testing.T) {
+	bot, mock := newTestBot()
+
+	if err := bot.SendMsg(42, replyLayer(bot, "Yes or no?", "Yes", "No")); err != nil {
+		t.Fatal(err)
+	}
+	bot.replyKeyboardsMutex.Lock()
+	bot.replyKeyboards[chatKey(42)] = shownReplyKeyboard{sent: time.Now().Add(-bot.defaultTTL - time.Minute)}
+	bot.replyKeyboardsMutex.Unlock()
+
+	bot.sweepExpiredReplyKeyboards()
+	if len(bot.replyKeyboards) != 0 {
+		t.Fatalf("keyboard not expired: %v", bot.replyKeyboards)
+	}
+	if err := bot.SendMsg(42, bot.NewLayer("Later")); err != nil {
+		t.Fatal(err)
+	}
+	if m := lastReplyMarkup(t, mock); m != nil {
+		t.Fatalf("expired keyboard removed: %#v", m)
+	}
+}
+
+func TestReplyKeyboard_ExplicitRemove(t *testing.T) {
+	bot, mock := newTestBot()
+
+	layer := bot.NewLayer("Done")
+	layer.SetRemoveKeyboard()
+	if err := bot.SendMsg(42, layer); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lastReplyMarkup(t, mock).(tgbotapi.ReplyKeyboardRemove); !ok {
+		t.Fatalf("want removal, got %#v", lastReplyMarkup(t, mock))
+	}
+
+	withButtons := replyLayer(bot, "Oops", "A")
+	withButtons.SetRemoveKeyboard()
+	if err := bot.SendMsg(42, withButtons); err == nil {
+		t.Fatal("expected error removing the keyboard in a message with buttons")
+	}
+}
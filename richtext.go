@@ -0,0 +1,355 @@
+package bf
+
+import (
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ModeEntities is a parse mode for WithParseMode that sends texts with no
+// parse mode at all: RichText formatting travels as message entities and
+// any other text is shown exactly as written, markup characters included.
+const ModeEntities = "entities"
+
+// richSpan is a run of RichText with one formatting; kind is the Telegram
+// entity type, empty for plain text.
+type richSpan struct {
+	kind   string
+	text   string
+	url    string
+	userID int64
+	lang   string
+}
+
+// RichText builds formatted message text that renders correctly in any parse
+// mode. Every piece is escaped for the mode it is rendered in, so user input
+// can go straight into it:
+//
+//	text := bf.NewRichText().
+//	    Text("Hello, ").Bold(event.FirstName).Text("!").Newline().
+//	    Link("Open the docs", "https://example.com/docs")
+//	layer := bot.NewLayer()
+//	layer.AddRichText(text)
+//
+// Formatting does not nest. Legacy ModeMarkdown has no spoilers or quotes;
+// they render as plain text there.
+type RichText struct {
+	spans []richSpan
+	// afterBlock is set by Quote: text that follows starts on a new line,
+	// as it does in HTML.
+	afterBlock bool
+}
+
+// NewRichText returns an empty RichText.
+func NewRichText() *RichText {
+	return &RichText{}
+}
+
+func (t *RichText) add(span richSpan) *RichText {
+	if span.text == "" {
+		return t
+	}
+	if t.afterBlock {
+		t.afterBlock = false
+		if !strings.HasPrefix(span.text, "\n") {
+			t.spans = append(t.spans, richSpan{text: "\n"})
+		}
+	}
+	// Adjacent runs of the same formatting merge, so they cannot render
+	// as ambiguous delimiters such as "_a__b_".
+	if n := len(t.spans); n > 0 && span.kind != "pre" && span.kind != "blockquote" {
+		last := &t.spans[n-1]
+		if last.kind == span.kind && last.url == span.url && last.userID == span.userID {
+			last.text += span.text
+			return t
+		}
+	}
+	t.spans = append(t.spans, span)
+
+	return t
+}
+
+// Text appends s as is, without formatting.
+func (t *RichText) Text(s string) *RichText {
+	return t.add(richSpan{text: s})
+}
+
+// Textf appends fmt.Sprintf(format, args...) without formatting.
+func (t *RichText) Textf(format string, args ...any) *RichText {
+	return t.Text(fmt.Sprintf(format, args...))
+}
+
+// Newline starts a new line.
+func (t *RichText) Newline() *RichText {
+	return t.Text("\n")
+}
+
+// Bold appends s in bold.
+func (t *RichText) Bold(s string) *RichText {
+	return t.add(richSpan{kind: "bold", text: s})
+}
+
+// Italic appends s in italics.
+func (t *RichText) Italic(s string) *RichText {
+	return t.add(richSpan{kind: "italic", text: s})
+}
+
+// Spoiler appends s hidden until tapped.
+func (t *RichText) Spoiler(s string) *RichText {
+	return t.add(richSpan{kind: "spoiler", text: s})
+}
+
+// Code appends s in a monospace font inline.
+func (t *RichText) Code(s string) *RichText {
+	return t.add(richSpan{kind: "code", text: s})
+}
+
+// Pre appends a monospace code block; language, if set, enables syntax
+// highlighting.
+func (t *RichText) Pre(code, language string) *RichText {
+	return t.add(richSpan{kind: "pre", text: code, lang: language})
+}
+
+// Link appends text linking to url.
+func (t *RichText) Link(text, url string) *RichText {
+	return t.add(richSpan{kind: "text_link", text: text, url: url})
+}
+
+// Mention appends text linking to the user's profile; unlike @username it
+// works for users without a username.
+func (t *RichText) Mention(text string, userID int64) *RichText {
+	return t.add(richSpan{kind: "text_mention", text: text, userID: userID})
+}
+
+// Quote appends s as a block quote. The quote starts on a new line, and so
+// does whatever follows it.
+func (t *RichText) Quote(s string) *RichText {
+	if len(t.spans) > 0 && !strings.HasSuffix(t.spans[len(t.spans)-1].text, "\n") {
+		t.add(richSpan{text: "\n"})
+	}
+	t.add(richSpan{kind: "blockquote", text: s})
+	t.afterBlock = true
+
+	return t
+}
+
+// String returns the text without formatting.
+func (t *RichText) String() string {
+	var b strings.Builder
+	for _, span := range t.spans {
+		b.WriteString(span.text)
+	}
+
+	return b.String()
+}
+
+// Render returns the text marked up for parseMode: tgbotapi.ModeHTML,
+// ModeMarkdownV2 or ModeMarkdown. Any other mode, ModeEntities included,
+// yields the plain text; use Entities for its formatting.
+func (t *RichText) Render(parseMode string) string {
+	var b strings.Builder
+	for _, span := range t.spans {
+		switch parseMode {
+		case tgbotapi.ModeHTML:
+			b.WriteString(span.html())
+		case tgbotapi.ModeMarkdownV2:
+			b.WriteString(span.markdownV2())
+		case tgbotapi.ModeMarkdown:
+			b.WriteString(span.markdown())
+		default:
+			b.WriteString(span.text)
+		}
+	}
+
+	return b.String()
+}
+
+// Entities returns the plain text and its formatting as Telegram message
+// entities, to be sent with no parse mode.
+func (t *RichText) Entities() (string, []tgbotapi.MessageEntity) {
+	var (
+		b        strings.Builder
+		entities []tgbotapi.MessageEntity
+		offset   int
+	)
+	for _, span := range t.spans {
+		b.WriteString(span.text)
+		length := utf16Len(span.text)
+		if span.kind != "" {
+			entity := tgbotapi.MessageEntity{
+				Type:     span.kind,
+				Offset:   offset,
+				Length:   length,
+				URL:      span.url,
+				Language: span.lang,
+			}
+			if span.kind == "text_mention" {
+				entity.User = &tgbotapi.User{ID: span.userID}
+			}
+			entities = append(entities, entity)
+		}
+		offset += length
+	}
+
+	return b.String(), entities
+}
+
+func (s richSpan) html() string {
+	text := escapeHTML(s.text)
+	switch s.kind {
+	case "bold":
+		return "<b>" + text + "</b>"
+	case "italic":
+		return "<i>" + text + "</i>"
+	case "spoiler":
+		return "<tg-spoiler>" + text + "</tg-spoiler>"
+	case "code":
+		return "<code>" + text + "</code>"
+	case "pre":
+		if s.lang != "" {
+			return `<pre><code class="language-` + escapeHTML(s.lang) + `">` + text + "</code></pre>"
+		}
+		return "<pre>" + text + "</pre>"
+	case "text_link":
+		return `<a href="` + escapeHTML(s.url) + `">` + text + "</a>"
+	case "text_mention":
+		return fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>`, s.userID, text)
+	case "blockquote":
+		return "<blockquote>" + text + "</blockquote>"
+	}
+
+	return text
+}
+
+func (s richSpan) markdownV2() string {
+	text := escapeMarkdownV2(s.text)
+	switch s.kind {
+	case "bold":
+		return "*" + text + "*"
+	case "italic":
+		return "_" + text + "_"
+	case "spoiler":
+		return "||" + text + "||"
+	case "code":
+		return "`" + markdownV2Code.Replace(s.text) + "`"
+	case "pre":
+		return "```" + s.lang + "\n" + markdownV2Code.Replace(s.text) + "\n```"
+	case "text_link":
+		return "[" + text + "](" + markdownV2URL.Replace(s.url) + ")"
+	case "text_mention":
+		return fmt.Sprintf("[%s](tg://user?id=%d)", text, s.userID)
+	case "blockquote":
+		lines := strings.Split(s.text, "\n")
+		for i, line := range lines {
+			lines[i] = ">" + escapeMarkdownV2(line)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	return text
+}
+
+// markdown renders for legacy Markdown. It cannot escape within entities:
+// a delimiter inside one closes the entity, is escaped, and reopens it.
+func (s richSpan) markdown() string {
+	switch s.kind {
+	case "bold":
+		return legacyEntity("*", s.text)
+	case "italic":
+		return legacyEntity("_", s.text)
+	case "code":
+		return legacyEntity("`", s.text)
+	case "pre":
+		return "```" + s.lang + "\n" + strings.ReplaceAll(s.text, "```", "'''") + "\n```"
+	case "text_link":
+		return "[" + legacyLinkText(s.text) + "](" + s.url + ")"
+	case "text_mention":
+		return fmt.Sprintf("[%s](tg://user?id=%d)", legacyLinkText(s.text), s.userID)
+	}
+
+	return escapeMarkdown(s.text)
+}
+
+func legacyEntity(delim, text string) string {
+	parts := strings.Split(text, delim)
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = delim + part + delim
+		}
+	}
+
+	return strings.Join(parts, `\`+delim)
+}
+
+// legacyLinkText drops "]", which legacy Markdown link texts cannot hold.
+func legacyLinkText(text string) string {
+	return strings.ReplaceAll(text, "]", "")
+}
+
+var (
+	htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+	markdownV2Escaper = strings.NewReplacer(
+		`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+		"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+		"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+	)
+	markdownV2Code = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+	markdownV2URL  = strings.NewReplacer(`\`, `\\`, ")", `\)`)
+
+	markdownEscaper = strings.NewReplacer("_", `\_`, "*", `\*`, "`", "\\`", "[", `\[`)
+)
+
+func escapeHTML(s string) string       { return htmlEscaper.Replace(s) }
+func escapeMarkdownV2(s string) string { return markdownV2Escaper.Replace(s) }
+func escapeMarkdown(s string) string   { return markdownEscaper.Replace(s) }
+
+// Escape makes text safe to embed in a message sent with parseMode, e.g. user
+// input passed to AddText. Texts for ModeEntities and plain texts need no
+// escaping and are returned as is.
+func Escape(text, parseMode string) string {
+	switch parseMode {
+	case tgbotapi.ModeHTML:
+		return escapeHTML(text)
+	case tgbotapi.ModeMarkdownV2:
+		return escapeMarkdownV2(text)
+	case tgbotapi.ModeMarkdown:
+		return escapeMarkdown(text)
+	}
+
+	return text
+}
+
+// apiParseMode is the parse_mode sent to Telegram: none in ModeEntities.
+func (b *ChatBotImpl) apiParseMode() string {
+	if b.parseMode == ModeEntities {
+		return ""
+	}
+
+	return b.parseMode
+}
+
+// chunkEntities distributes entities over chunks, which are consecutive
+// substrings of text as SplitText returns them for plain text. An entity
+// crossing a cut is continued in the next chunk.
+func chunkEntities(text string, chunks []string, entities []tgbotapi.MessageEntity) [][]tgbotapi.MessageEntity {
+	parts := make([][]tgbotapi.MessageEntity, len(chunks))
+	pos := 0
+	for i, chunk := range chunks {
+		at := pos + strings.Index(text[pos:], chunk)
+		start := utf16Len(text[:at])
+		end := start + utf16Len(chunk)
+		for _, e := range entities {
+			from, to := max(e.Offset, start), min(e.Offset+e.Length, end)
+			if from >= to {
+				continue
+			}
+			e.Offset, e.Length = from-start, to-from
+			parts[i] = append(parts[i], e)
+		}
+		pos = at + len(chunk)
+	}
+
+	return parts
+}
@@ -0,0 +1,161 @@
+package bf
+
+import (
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestRichText_RendersPerParseMode(t *testing.T) {
+	text := NewRichText().
+		Text("Hi <b> & _x_! ").Bold("a*b").Text(" ").Italic("c_d").Newline().
+		Code("x`y").Text(" ").Link("docs (v2)", "https://x.io/a)b").Text(" ").
+		Mention("Ann", 7).Text(" ").Spoiler("boo")
+
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{
+			tgbotapi.ModeHTML,
+			"Hi &lt;b&gt; &amp; _x_! <b>a*b</b> <i>c_d</i>\n<code>x`y</code> " +
+				`<a href="https://x.io/a)b">docs (v2)</a> <a href="tg://user?id=7">Ann</a> <tg-spoiler>boo</tg-spoiler>`,
+		},
+		{
+			tgbotapi.ModeMarkdownV2,
+			`Hi <b\> & \_x\_\! *a\*b* _c\_d_` + "\n`x\\`y` " +
+				`[docs \(v2\)](https://x.io/a\)b) [Ann](tg://user?id=7) ||boo||`,
+		},
+		{
+			tgbotapi.ModeMarkdown,
+			`Hi <b> & \_x\_! *a*\**b* _c_\__d_` + "\n`x`\\``y` " +
+				`[docs (v2)](https://x.io/a)b) [Ann](tg://user?id=7) boo`,
+		},
+		{
+			"",
+			"Hi <b> & _x_! a*b c_d\nx`y docs (v2) Ann boo",
+		},
+	}
+	for _, tt := range tests {
+		if got := text.Render(tt.mode); got != tt.want {
+			t.Errorf("%q:\n got %q\nwant %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestRichText_QuoteAndPreAreBlocks(t *testing.T) {
+	text := NewRichText().Text("He said:").Quote("a.\nb").Text("end").Pre("x := 1", "go")
+
+	if got := text.String(); got != "He said:\na.\nb\nendx := 1" {
+		t.Fatalf("plain: %q", got)
+	}
+	if got := text.Render(tgbotapi.ModeMarkdownV2); got != "He said:\n>a\\.\n>b\nend```go\nx := 1\n```" {
+		t.Fatalf("markdownV2: %q", got)
+	}
+	want := "He said:\n<blockquote>a.\nb</blockquote>\nend" +
+		`<pre><code class="language-go">x := 1</code></pre>`
+	if got := text.Render(tgbotapi.ModeHTML); got != want {
+		t.Fatalf("html: %q", got)
+	}
+}
+
+func TestRichText_EntitiesCountUTF16(t *testing.T) {
+	plain, entities := NewRichText().Text("😀 ").Bold("hi").Italic("").Mention("Ann", 7).Entities()
+
+	if plain != "😀 hiAnn" {
+		t.Fatalf("plain: %q", plain)
+	}
+	if len(entities) != 2 {
+		t.Fatalf("entities: %+v", entities)
+	}
+	if e := entities[0]; e.Type != "bold" || e.Offset != 3 || e.Length != 2 {
+		t.Fatalf("bold: %+v", e)
+	}
+	if e := entities[1]; e.Type != "text_mention" || e.Offset != 5 || e.User == nil || e.User.ID != 7 {
+		t.Fatalf("mention: %+v", e)
+	}
+}
+
+func TestRichText_AdjacentRunsMerge(t *testing.T) {
+	got := NewRichText().Italic("a").Italic("b").Render(tgbotapi.ModeMarkdownV2)
+	if got != "_ab_" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	if got := Escape("<a&b>", tgbotapi.ModeHTML); got != "&lt;a&amp;b&gt;" {
+		t.Fatalf("html: %q", got)
+	}
+	if got := Escape("1.5-2", tgbotapi.ModeMarkdownV2); got != `1\.5\-2` {
+		t.Fatalf("markdownV2: %q", got)
+	}
+	if got := Escape("<b>", ModeEntities); got != "<b>" {
+		t.Fatalf("entities: %q", got)
+	}
+}
+
+func TestAddRichText_UsesBotParseMode(t *testing.T) {
+	bot, mock := newTestBot()
+
+	layer := bot.NewLayer("Profile")
+	layer.AddRichText(NewRichText().Text("Name: ").Bold("<script>"))
+	if err := bot.SendMsg(42, layer); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastText(t, mock); got != "Profile\nName: <b>&lt;script&gt;</b>" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestModeEntities_SendsEntitiesWithoutParseMode(t *testing.T) {
+	bot, mock := newTestBot()
+	bot.parseMode = ModeEntities
+
+	layer := bot.NewLayer("<b>raw</b>")
+	layer.AddRichText(NewRichText().Text("Hi ").Bold("Ann"))
+	if err := bot.SendMsg(42, layer); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := mock.lastSent().(tgbotapi.MessageConfig)
+	if msg.ParseMode != "" || msg.Text != "<b>raw</b>\nHi Ann" {
+		t.Fatalf("sent %q with mode %q", msg.Text, msg.ParseMode)
+	}
+	if len(msg.Entities) != 1 || msg.Entities[0].Offset != 14 || msg.Entities[0].Length != 3 {
+		t.Fatalf("entities: %+v", msg.Entities)
+	}
+
+	if err := bot.EditMsg(42, 9, layer); err != nil {
+		t.Fatal(err)
+	}
+	edit := mock.lastSent().(tgbotapi.EditMessageTextConfig)
+	if edit.ParseMode != "" || len(edit.Entities) != 1 {
+		t.Fatalf("edit: mode %q, entities %+v", edit.ParseMode, edit.Entities)
+	}
+}
+
+func TestModeEntities_EntitiesFollowSplitText(t *testing.T) {
+	bot, mock := newTestBot()
+	bot.parseMode = ModeEntities
+
+	head := strings.Repeat("a", MaxTextLength-2)
+	layer := bot.NewLayer()
+	layer.AddRichText(NewRichText().Text(head).Bold("bbbb").Text(" tail"))
+	if err := bot.SendMsg(42, layer); err != nil {
+		t.Fatal(err)
+	}
+
+	if mock.sentCount() != 2 {
+		t.Fatalf("want 2 messages, got %d", mock.sentCount())
+	}
+	first := mock.sent[0].(tgbotapi.MessageConfig)
+	second := mock.sent[1].(tgbotapi.MessageConfig)
+	if len(first.Entities) != 1 || first.Entities[0].Offset != MaxTextLength-2 || first.Entities[0].Length != 2 {
+		t.Fatalf("first: %+v", first.Entities)
+	}
+	if len(second.Entities) != 1 || second.Entities[0].Offset != 0 || second.Entities[0].Length != 2 {
+		t.Fatalf("second %q: %+v", second.Text, second.Entities)
+	}
+}
@@ -0,0 +1,202 @@
+package bf
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// RouteKind tells how the user triggers a Route.
+type RouteKind string
+
+// Kinds of Route.
+const (
+	RouteCommand      RouteKind = "command"
+	RouteButton       RouteKind = "button"
+	RouteInlineButton RouteKind = "inline_button"
+)
+
+// Route is a command or button of the default layer, as listed by Routes.
+// Trigger is the command with its slash or the button label.
+type Route struct {
+	Kind        RouteKind
+	Trigger     string
+	Description string
+	Group       string
+	Hidden      bool
+}
+
+// routeMeta describes a default-layer handler for Routes and the help.
+type routeMeta struct {
+	description string
+	group       string
+	hidden      bool
+}
+
+// RouteOption describes a command or button at registration, e.g.
+// RegisterCommand("/start", start, Description("Start over")).
+type RouteOption func(meta *routeMeta)
+
+// Description explains what a command or button does; the help shows it
+// next to the trigger. It may be a WithCatalog key.
+func Description(text string) RouteOption {
+	return func(meta *routeMeta) {
+		meta.description = text
+	}
+}
+
+// Group files a command or button under a heading of the help. It may be a
+// WithCatalog key.
+func Group(name string) RouteOption {
+	return func(meta *routeMeta) {
+		meta.group = name
+	}
+}
+
+// Hidden leaves a command or button out of the help; Routes still lists it.
+func Hidden() RouteOption {
+	return func(meta *routeMeta) {
+		meta.hidden = true
+	}
+}
+
+func newRouteMeta(opts []RouteOption) routeMeta {
+	var meta routeMeta
+	for _, opt := range opts {
+		opt(&meta)
+	}
+
+	return meta
+}
+
+// helpTexts are the help's own texts, used unless the catalog has the key.
+var helpTexts = map[string]string{
+	"bf.help.title":       "Commands",
+	"bf.help.description": "Show this help",
+}
+
+// Routes lists the commands of the default layer, sorted, then its
+// reply-keyboard and inline buttons in keyboard order, with what their
+// RouteOptions say about them.
+func (b *ChatBotImpl) Routes() []Route {
+	b.defaultLayerMutex.RLock()
+	defer b.defaultLayerMutex.RUnlock()
+
+	layer := b.defaultHandlerLayer
+	if layer == nil {
+		return nil
+	}
+
+	route := func(kind RouteKind, trigger string, meta routeMeta) Route {
+		return Route{
+			Kind:        kind,
+			Trigger:     trigger,
+			Description: meta.description,
+			Group:       meta.group,
+			Hidden:      meta.hidden,
+		}
+	}
+
+	var routes []Route
+	for _, command := range slices.Sorted(maps.Keys(layer.commandHandler)) {
+		routes = append(routes, route(RouteCommand, command, layer.commandHandler[command].meta))
+	}
+	for _, h := range layer.sortedButtonsSlice() {
+		routes = append(routes, route(RouteButton, h.text, h.meta))
+	}
+	for _, h := range layer.sortedIButtonsSlice() {
+		routes = append(routes, route(RouteInlineButton, h.button.Text, h.meta))
+	}
+
+	return routes
+}
+
+// WithHelpCommand registers HelpHandler as /help when Start begins, unless
+// /help is already registered.
+func WithHelpCommand() BotOption {
+	return func(bot *ChatBotImpl) {
+		bot.helpCommand = true
+	}
+}
+
+// registerHelp installs the WithHelpCommand /help.
+func (b *ChatBotImpl) registerHelp() {
+	if !b.helpCommand {
+		return
+	}
+
+	b.defaultLayerMutex.RLock()
+	_, taken := b.defaultHandlerLayer.commandHandler["/help"]
+	b.defaultLayerMutex.RUnlock()
+	if !taken {
+		b.RegisterCommand("/help", b.HelpHandler, Description("bf.help.description"))
+	}
+}
+
+// HelpHandler replies with the commands and reply-keyboard buttons of
+// Routes that are not Hidden: the ungrouped ones first, then each Group
+// under its heading, in the order they are first listed. Descriptions,
+// groups and labels that are WithCatalog keys are translated, as is the
+// title, "bf.help.title".
+func (b *ChatBotImpl) HelpHandler(ctx context.Context, event Event) error {
+	var (
+		groups []string
+		lines  = map[string][]Route{}
+	)
+	for _, route := range b.Routes() {
+		if route.Hidden || route.Kind == RouteInlineButton {
+			continue
+		}
+		if _, ok := lines[route.Group]; !ok && route.Group != "" {
+			groups = append(groups, route.Group)
+		}
+		lines[route.Group] = append(lines[route.Group], route)
+	}
+
+	help := NewRichText().Bold(localText(ctx, "bf.help.title"))
+	writeRoutes := func(routes []Route) {
+		for _, route := range routes {
+			help.Newline()
+			// Commands stay plain text, which Telegram makes tappable.
+			if route.Kind == RouteCommand {
+				help.Text(route.Trigger)
+			} else {
+				help.Text(localText(ctx, route.Trigger))
+			}
+			if route.Description != "" {
+				help.Text(" — " + localText(ctx, route.Description))
+			}
+		}
+	}
+	writeRoutes(lines[""])
+	for _, group := range groups {
+		help.Newline().Newline().Bold(localText(ctx, group))
+		writeRoutes(lines[group])
+	}
+
+	text, entities := help.Render(b.parseMode), []tgbotapi.MessageEntity(nil)
+	if b.parseMode == ModeEntities {
+		text, entities = help.Entities()
+	}
+	if _, err := b.sendChunks(event.ChatID, event.ThreadID, text, entities, nil); err != nil {
+		return fmt.Errorf("failed to send help: %w", err)
+	}
+
+	return nil
+}
+
+// localText translates text into the event's locale when it is a catalog
+// key, else returns the help's own text for it, or text as is.
+func localText(ctx context.Context, text string) string {
+	if l, ok := ctx.Value(localeKey{}).(localizer); ok && l.catalog.Has(text) {
+		return l.catalog.Translate(l.locale, text)
+	}
+	if fallback, ok := helpTexts[text]; ok {
+		return fallback
+	}
+
+	return text
+}
@@ -0,0 +1,95 @@
+package bf
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func noop(context.Context, Event) error { return nil }
+
+func routesBot() (*ChatBotImpl, *mockTelegramAPI) {
+	bot, mock := newTestBot()
+	bot.RegisterCommand("/start", noop, Description("Start over"))
+	bot.RegisterCommand("/admin", noop, Hidden())
+	bot.RegisterCommand("/login", noop, Description("account.login"), Group("account.group"))
+	bot.RegisterButton("Profile", noop, Description("Your <profile>"), Group("account.group"))
+	bot.RegisterIButton("Refresh", noop, Description("Reload"))
+	WithHelpCommand()(bot)
+	bot.registerHelp()
+
+	return bot, mock
+}
+
+func TestRoutes_ListsDefaultLayerWithOptions(t *testing.T) {
+	bot, _ := routesBot()
+
+	want := []Route{
+		{Kind: RouteCommand, Trigger: "/admin", Hidden: true},
+		{Kind: RouteCommand, Trigger: "/help", Description: "bf.help.description"},
+		{Kind: RouteCommand, Trigger: "/login", Description: "account.login", Group: "account.group"},
+		{Kind: RouteCommand, Trigger: "/start", Description: "Start over"},
+		{Kind: RouteButton, Trigger: "Profile", Description: "Your <profile>", Group: "account.group"},
+		{Kind: RouteInlineButton, Trigger: "Refresh", Description: "Reload"},
+	}
+	got := bot.Routes()
+	if len(got) != len(want) {
+		t.Fatalf("got %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("route %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestHelpHandler_GroupsAndEscapes(t *testing.T) {
+	bot, mock := routesBot()
+
+	bot.handleUpdate(context.Background(), newChatController(context.Background()), cmdUpdate(42, "/help"))
+	want := "<b>Commands</b>\n/help — Show this help\n/start — Start over\n\n" +
+		"<b>account.group</b>\n/login — account.login\nProfile — Your &lt;profile&gt;"
+	if got := lastText(t, mock); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestHelpHandler_Localised(t *testing.T) {
+	bot, mock := routesBot()
+	catalog, err := LoadCatalog(fstest.MapFS{
+		"en.yaml": {Data: []byte("bf:\n  help:\n    title: Commands\n    description: Show this help\n" +
+			"account:\n  group: Account\n  login: Sign in\n")},
+		"ru.yaml": {Data: []byte("bf:\n  help:\n    title: Команды\n    description: Эта справка\n" +
+			"account:\n  group: Аккаунт\n  login: Войти\n")},
+	}, "en", "*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	WithCatalog(catalog)(bot)
+	bot.parseMode = ModeEntities
+
+	update := cmdUpdate(42, "/help")
+	update.Message.From = &tgbotapi.User{ID: 7, LanguageCode: "ru"}
+	bot.handleUpdate(context.Background(), newChatController(context.Background()), update)
+	msg := mock.lastSent().(tgbotapi.MessageConfig)
+	want := "Команды\n/help — Эта справка\n/start — Start over\n\nАккаунт\n/login — Войти\nProfile — Your <profile>"
+	if msg.Text != want {
+		t.Fatalf("got %q, want %q", msg.Text, want)
+	}
+	if len(msg.Entities) != 2 || msg.Entities[0].Type != "bold" {
+		t.Fatalf("entities %+v", msg.Entities)
+	}
+}
+
+func TestWithHelpCommand_KeepsRegisteredHelp(t *testing.T) {
+	bot, _ := newTestBot()
+	bot.RegisterCommand("/help", noop, Description("custom"))
+	WithHelpCommand()(bot)
+	bot.registerHelp()
+
+	if got := bot.Routes()[0].Description; got != "custom" {
+		t.Fatalf("/help replaced: %q", got)
+	}
+}
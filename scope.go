@@ -0,0 +1,126 @@
+package bf
+
+import "context"
+
+// LayerScope selects whose messages a layer listens to within its chat.
+// The default, ScopeChat, matches the next event from anyone in the chat;
+// ScopeUser matches only one member, so in a group the layer is neither
+// answered nor consumed by somebody else:
+//
+//	layer := bot.NewLayer("Your answer?")
+//	layer.SetScope(bf.ScopeUser(event.UserTGID))
+//	bot.Reply(event, layer)
+//
+// A chat can hold one chat-scoped layer and one user-scoped layer per member
+// at the same time; an event is served by its sender's layer first. In forum
+// supergroups every topic has its own slots: a layer belongs to the topic it
+// is sent to (see ScopeThread).
+type LayerScope struct {
+	userID   int64
+	threadID int
+}
+
+// ScopeChat is the default scope: the layer belongs to the whole chat.
+func ScopeChat() LayerScope {
+	return LayerScope{}
+}
+
+// ScopeUser binds the layer to one user within the chat.
+func ScopeUser(userID int64) LayerScope {
+	return LayerScope{userID: userID}
+}
+
+// ScopeThread binds the layer to a forum topic: it is sent to that topic and
+// matches only events from it. Without it a layer goes to the topic of the
+// event passed to Reply, or the general topic for SendMsg.
+func ScopeThread(threadID int) LayerScope {
+	return LayerScope{threadID: threadID}
+}
+
+// InThread returns the scope restricted to a forum topic, e.g.
+// ScopeUser(id).InThread(event.ThreadID).
+func (s LayerScope) InThread(threadID int) LayerScope {
+	s.threadID = threadID
+	return s
+}
+
+// SetScope sets whose messages the layer listens to; see LayerScope.
+func (hl *HandlerLayer) SetScope(scope LayerScope) {
+	hl.scope = scope
+}
+
+// admits reports whether event may reach a layer of this scope found by its
+// message rather than by its slot: the event must come from the scope's user
+// and topic, when the scope names them.
+func (s LayerScope) admits(event Event) bool {
+	return (s.userID == 0 || s.userID == event.UserTGID) && (s.threadID == 0 || s.threadID == event.ThreadID)
+}
+
+// scopeKey addresses a layer slot in chatHandlerLayers and a lock in the
+// chatController: a whole chat (userID 0) or one user in it, within a forum
+// topic (threadID 0 is the general topic and ordinary chats).
+type scopeKey struct {
+	chatID   int64
+	userID   int64
+	threadID int
+}
+
+func chatKey(chatID int64) scopeKey {
+	return scopeKey{chatID: chatID}
+}
+
+// key returns the layer slot of the scope in chatID; thread is used when the
+// scope names no topic of its own.
+func (s LayerScope) key(chatID int64, thread int) scopeKey {
+	if s.threadID != 0 {
+		thread = s.threadID
+	}
+
+	return scopeKey{chatID: chatID, userID: s.userID, threadID: thread}
+}
+
+// eventKeys lists the layer slots that may serve event, most specific first.
+func eventKeys(event Event) []scopeKey {
+	chat := scopeKey{chatID: event.ChatID, threadID: event.ThreadID}
+	if event.UserTGID == 0 {
+		return []scopeKey{chat}
+	}
+
+	user := chat
+	user.userID = event.UserTGID
+
+	return []scopeKey{user, chat}
+}
+
+// lockKey is the chatController lock an event takes. Private chats and
+// events without a sender serialise per chat; in groups every member has a
+// lock of their own, so one member's slow handler does not drop the others'
+// messages.
+func lockKey(event Event) scopeKey {
+	if event.UserTGID == 0 || event.UserTGID == event.ChatID {
+		return chatKey(event.ChatID)
+	}
+
+	return scopeKey{chatID: event.ChatID, userID: event.UserTGID}
+}
+
+// lockCtxKey is the context key under which handleUpdate passes the
+// chatController lock of the event being handled.
+type lockCtxKey struct{}
+
+func withLock(ctx context.Context, lock scopeKey) context.Context {
+	return context.WithValue(ctx, lockCtxKey{}, lock)
+}
+
+// contextMember is the group member whose lock ctx's handler holds in
+// chatID, or 0: in private chats, outside handlers and for other chats.
+// Per-conversation state (forms, navigation) is kept per member with it, the
+// same way the dispatcher serialises their events.
+func contextMember(ctx context.Context, chatID int64) int64 {
+	lock, ok := ctx.Value(lockCtxKey{}).(scopeKey)
+	if !ok || lock.chatID != chatID {
+		return 0
+	}
+
+	return lock.userID
+}
@@ -0,0 +1,204 @@
+package bf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// groupUpdate is a text message from userID in group chat -100.
+func groupUpdate(userID int64, text string) tgbotapi.Update {
+	return tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			Text: text,
+			Chat: &tgbotapi.Chat{ID: -100, Type: "group"},
+			From: &tgbotapi.User{ID: userID},
+		},
+	}
+}
+
+func TestScope_UserLayerIgnoresOtherMembers(t *testing.T) {
+	bot, _ := newTestBot()
+	c := newChatController(context.Background())
+
+	var answeredBy []int64
+	quiz := bot.NewLayer("2+2?")
+	quiz.SetScope(ScopeUser(1))
+	quiz.layerDefaultHandler = func(_ context.Context, ev Event) error {
+		answeredBy = append(answeredBy, ev.UserTGID)
+		return nil
+	}
+	if err := bot.SendMsg(-100, quiz); err != nil {
+		t.Fatal(err)
+	}
+
+	bot.handleUpdate(context.Background(), c, groupUpdate(2, "4"))
+	if len(answeredBy) != 0 {
+		t.Fatalf("another member answered the quiz: %v", answeredBy)
+	}
+	if bot.peekLayer(ScopeUser(1).key(-100, 0)) != quiz {
+		t.Fatal("another member consumed the quiz layer")
+	}
+
+	bot.handleUpdate(context.Background(), c, groupUpdate(1, "4"))
+	if len(answeredBy) != 1 || answeredBy[0] != 1 {
+		t.Fatalf("answers: %v", answeredBy)
+	}
+	if bot.peekLayer(ScopeUser(1).key(-100, 0)) != nil {
+		t.Fatal("answered layer must be consumed")
+	}
+}
+
+func TestScope_SenderLayerWinsOverChatLayer(t *testing.T) {
+	bot, _ := newTestBot()
+	c := newChatController(context.Background())
+
+	var got []string
+	record := func(tag string) HandlerFunc {
+		return func(context.Context, Event) error {
+			got = append(got, tag)
+			return nil
+		}
+	}
+
+	chat := bot.NewLayer("Anyone?")
+	chat.layerDefaultHandler = record("chat")
+	personal := bot.NewLayer("You?")
+	personal.SetScope(ScopeUser(1))
+	personal.layerDefaultHandler = record("user")
+	if err := bot.SendMsg(-100, chat); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.SendMsg(-100, personal); err != nil {
+		t.Fatal(err)
+	}
+
+	bot.handleUpdate(context.Background(), c, groupUpdate(1, "me"))
+	bot.handleUpdate(context.Background(), c, groupUpdate(1, "me again"))
+
+	if len(got) != 2 || got[0] != "user" || got[1] != "chat" {
+		t.Fatalf("dispatch order: %v", got)
+	}
+}
+
+func TestScope_LockKeys(t *testing.T) {
+	if lockKey(Event{ChatID: 7, UserTGID: 7}) != chatKey(7) {
+		t.Fatal("private chats must lock per chat")
+	}
+	if lockKey(Event{ChatID: -100}) != chatKey(-100) {
+		t.Fatal("events without a sender must lock per chat")
+	}
+
+	c := newChatController(context.Background())
+	if !c.tryAcquire(lockKey(Event{ChatID: -100, UserTGID: 1})) {
+		t.Fatal("first member must acquire")
+	}
+	if !c.tryAcquire(lockKey(Event{ChatID: -100, UserTGID: 2})) {
+		t.Fatal("a busy member must not block another member of the group")
+	}
+	if c.tryAcquire(lockKey(Event{ChatID: -100, UserTGID: 1})) {
+		t.Fatal("same member must stay serialised")
+	}
+}
+
+func TestScope_ConversationAskScopeWaitsForOneMember(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	var answer string
+	bot.RegisterCommand("/quiz", func(ctx context.Context, ev Event) error {
+		var err error
+		answer, err = bot.Conversation(ev).Ask(ctx, "2+2?", AskScope(ScopeUser(ev.UserTGID)))
+		return err
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bot.handleUpdate(context.Background(), c, cmdUpdate(-100, "/quiz"))
+	}()
+	waitSent(t, mock, 1)
+
+	// A different member answers first; cmdUpdate sends as user 1.
+	bot.handleUpdate(context.Background(), c, groupUpdate(2, "5"))
+	select {
+	case <-done:
+		t.Fatal("conversation accepted another member's answer")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	bot.handleUpdate(context.Background(), c, groupUpdate(1, "4"))
+	waitDone(t, done)
+	if answer != "4" {
+		t.Fatalf("answer: %q", answer)
+	}
+}
+
+func TestScope_ReplyToUserPromptFromOtherMember(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	var answeredBy []int64
+	prompt := bot.NewLayer("Your name?")
+	prompt.SetForceReply("")
+	prompt.SetScope(ScopeUser(1))
+	prompt.RegisterText(AnyText, func(_ context.Context, ev Event) error {
+		answeredBy = append(answeredBy, ev.UserTGID)
+		return nil
+	})
+	mock.sendResp = tgbotapi.Message{MessageID: 10}
+	if err := bot.SendMsg(-100, prompt); err != nil {
+		t.Fatal(err)
+	}
+
+	reply := func(userID int64) tgbotapi.Update {
+		u := groupUpdate(userID, "Eve")
+		u.Message.ReplyToMessage = &tgbotapi.Message{MessageID: 10}
+		return u
+	}
+	bot.handleUpdate(context.Background(), c, reply(2))
+	if len(answeredBy) != 0 {
+		t.Fatalf("another member answered the prompt: %v", answeredBy)
+	}
+
+	bot.handleUpdate(context.Background(), c, reply(1))
+	if len(answeredBy) != 1 || answeredBy[0] != 1 {
+		t.Fatalf("answers: %v", answeredBy)
+	}
+}
+
+func TestScope_OldMessageButtonFromOtherMember(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	var tappedBy []int64
+	menu := bot.NewLayer("Your menu")
+	menu.SetScope(ScopeUser(1))
+	menu.RegisterIButton("Go", func(_ context.Context, ev Event) error {
+		tappedBy = append(tappedBy, ev.UserTGID)
+		return nil
+	})
+	mock.sendResp = tgbotapi.Message{MessageID: 10}
+	if err := bot.SendMsg(-100, menu); err != nil {
+		t.Fatal(err)
+	}
+	markup := mock.lastMarkup(t)
+
+	tap := func(userID int64) tgbotapi.Update {
+		u := withMessageID(tapUpdate(-100, markup, "Go"), 10)
+		u.CallbackQuery.From = &tgbotapi.User{ID: userID}
+		u.CallbackQuery.Message.Chat.Type = "group"
+		return u
+	}
+	bot.handleUpdate(context.Background(), c, tap(2))
+	if len(tappedBy) != 0 {
+		t.Fatalf("another member ran the button: %v", tappedBy)
+	}
+
+	bot.handleUpdate(context.Background(), c, tap(1))
+	if len(tappedBy) != 1 || tappedBy[0] != 1 {
+		t.Fatalf("taps: %v", tappedBy)
+	}
+}
@@ -0,0 +1,83 @@
+package bf
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// callbackSigBytes is how much of the HMAC-SHA256 tag is kept: 48 bits,
+// which base64url-encodes to exactly callbackSigLen characters.
+const (
+	callbackSigBytes = 6
+	callbackSigLen   = 8
+)
+
+// callbackSignature returns the truncated, base64url-encoded HMAC of data
+// as sent to chatID, and to userID alone unless it is 0, so a button cannot
+// be replayed in another chat or by another member.
+func (b *ChatBotImpl) callbackSignature(chatID, userID int64, data string) string {
+	mac := hmac.New(sha256.New, b.callbackSecret)
+	mac.Write([]byte(strconv.FormatInt(chatID, 10) + ":" + strconv.FormatInt(userID, 10) + ":" + data))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:callbackSigBytes])
+}
+
+// signButton appends the signature to a callback button's data, bound to
+// the chat and, for ScopeUser layers, the user. Buttons without callback
+// data (URL, switch-inline) are returned unchanged. The layer's stored
+// button is never modified, so handler lookup keeps using the unsigned data.
+func (b *ChatBotImpl) signButton(
+	chatID int64,
+	scope LayerScope,
+	button tgbotapi.InlineKeyboardButton,
+) (tgbotapi.InlineKeyboardButton, error) {
+	if len(b.callbackSecret) == 0 || button.CallbackData == nil {
+		return button, nil
+	}
+
+	signed := *button.CallbackData + b.callbackSignature(chatID, scope.userID, *button.CallbackData)
+	if len(signed) > maxCallbackDataLen {
+		return button, fmt.Errorf("callback data of button %q is %d bytes once signed, limit is %d",
+			button.Text, len(signed), maxCallbackDataLen)
+	}
+	button.CallbackData = &signed
+
+	return button, nil
+}
+
+// authenticateCallback checks and strips the signature of an inline-button
+// event when WithCallbackSecret is set, then decodes its payload from the
+// verified data. Data that is unsigned, signed with another secret or for
+// another chat or user, or altered yields ErrForgedCallback.
+func (b *ChatBotImpl) authenticateCallback(event *Event) error {
+	if event.Kind != EventKindInlineButton || len(b.callbackSecret) == 0 {
+		return nil
+	}
+
+	event.Payload = nil
+	if len(event.Button) < callbackSigLen {
+		return ErrForgedCallback
+	}
+
+	data, sig := event.Button[:len(event.Button)-callbackSigLen], event.Button[len(event.Button)-callbackSigLen:]
+	// The layer is not known yet: the button was signed either for the
+	// whole chat or for the user tapping it.
+	forChat := hmac.Equal([]byte(sig), []byte(b.callbackSignature(event.ChatID, 0, data)))
+	forUser := event.UserTGID != 0 &&
+		hmac.Equal([]byte(sig), []byte(b.callbackSignature(event.ChatID, event.UserTGID, data)))
+	if !forChat && !forUser {
+		return ErrForgedCallback
+	}
+
+	event.Button = data
+	if payload, ok := decodePayload(data); ok {
+		event.Payload = &payload
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package bf
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func newSignedTestBot(secret string) (*ChatBotImpl, *mockTelegramAPI, *[]error) {
+	bot, mock := newTestBot()
+	WithCallbackSecret([]byte(secret))(bot)
+
+	errs := &[]error{}
+	bot.RegisterErrorHandler(func(_ context.Context, _ Event, err error) {
+		*errs = append(*errs, err)
+	})
+	return bot, mock, errs
+}
+
+func TestCallbackSecret_SignsAndVerifies(t *testing.T) {
+	bot, mock, errs := newSignedTestBot("s3cret")
+	c := newChatController(context.Background())
+
+	var got Event
+	layer := bot.NewLayer("Pick")
+	if err := layer.RegisterIButtonData("Item", Payload("item", 42), func(_ context.Context, event Event) error {
+		got = event
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.SendMsg(42, layer); err != nil {
+		t.Fatal(err)
+	}
+
+	markup := mock.lastMarkup(t)
+	data := *markup.InlineKeyboard[0][0].CallbackData
+	if !strings.HasPrefix(data, "item:42") || len(data) != len("item:42")+callbackSigLen {
+		t.Fatalf("signed data: %q", data)
+	}
+	if _, ok := layer.buttonHandler["item:42"]; !ok {
+		t.Fatal("layer must keep the unsigned key")
+	}
+
+	bot.handleUpdate(context.Background(), c, tapUpdate(42, markup, "Item"))
+	if len(*errs) != 0 {
+		t.Fatalf("unexpected errors: %v", *errs)
+	}
+	if got.Button != "item:42" || got.Payload == nil || got.Payload.String(0) != "42" {
+		t.Fatalf("verified event: %+v", got)
+	}
+}
+
+func TestCallbackSecret_RejectsForgedAndForeign(t *testing.T) {
+	bot, mock, errs := newSignedTestBot("s3cret")
+	c := newChatController(context.Background())
+
+	called := false
+	bot.RegisterIButtonRoute("item", func(context.Context, Event) error { called = true; return nil })
+
+	layer := bot.NewLayer("Pick")
+	if err := layer.RegisterIButtonData("Item", Payload("item", 42), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.SendMsg(42, layer); err != nil {
+		t.Fatal(err)
+	}
+	markup := mock.lastMarkup(t)
+	sig := (*markup.InlineKeyboard[0][0].CallbackData)[len("item:42"):]
+
+	other, _, _ := newSignedTestBot("other")
+	forgeries := []string{
+		"item:43" + sig,
+		"item:42",
+		"x",
+		"item:42" + other.callbackSignature(42, 0, "item:42"),
+	}
+	for _, data := range forgeries {
+		*markup.InlineKeyboard[0][0].CallbackData = data
+		bot.handleUpdate(context.Background(), c, tapUpdate(42, markup, "Item"))
+	}
+
+	if called {
+		t.Fatal("handler ran for a forged callback")
+	}
+	if len(*errs) != len(forgeries) {
+		t.Fatalf("want %d errors, got %v", len(forgeries), *errs)
+	}
+	for _, err := range *errs {
+		if !errors.Is(err, ErrForgedCallback) {
+			t.Fatalf("want ErrForgedCallback, got %v", err)
+		}
+	}
+}
+
+func TestCallbackSecret_BoundToChatAndUser(t *testing.T) {
+	bot, mock, errs := newSignedTestBot("s3cret")
+	c := newChatController(context.Background())
+
+	var tappedBy []int64
+	tap := func(_ context.Context, event Event) error {
+		tappedBy = append(tappedBy, event.UserTGID)
+		return nil
+	}
+	bot.RegisterIButtonRoute("item", tap)
+
+	layer := bot.NewLayer("Pick")
+	if err := layer.RegisterIButtonData("Item", Payload("item", 42), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.SendMsg(42, layer); err != nil {
+		t.Fatal(err)
+	}
+	// Valid in chat 42, replayed in chat 43.
+	bot.handleUpdate(context.Background(), c, tapUpdate(43, mock.lastMarkup(t), "Item"))
+
+	mine := bot.NewLayer("Yours")
+	mine.SetScope(ScopeUser(1))
+	if err := mine.RegisterIButtonData("Item", Payload("item", 42), tap); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.SendMsg(-100, mine); err != nil {
+		t.Fatal(err)
+	}
+	markup := mock.lastMarkup(t)
+	tapAs := func(userID int64) tgbotapi.Update {
+		u := tapUpdate(-100, markup, "Item")
+		u.CallbackQuery.From = &tgbotapi.User{ID: userID}
+		return u
+	}
+	bot.handleUpdate(context.Background(), c, tapAs(2))
+	if len(tappedBy) != 0 {
+		t.Fatalf("replayed buttons ran: %v", tappedBy)
+	}
+	if len(*errs) != 2 || !errors.Is((*errs)[0], ErrForgedCallback) || !errors.Is((*errs)[1], ErrForgedCallback) {
+		t.Fatalf("want two ErrForgedCallback, got %v", *errs)
+	}
+
+	bot.handleUpdate(context.Background(), c, tapAs(1))
+	if len(tappedBy) != 1 || tappedBy[0] != 1 {
+		t.Fatalf("the user's own tap: %v, errors %v", tappedBy, *errs)
+	}
+}
+
+func TestCallbackSecret_SignedDataOverLimit(t *testing.T) {
+	bot, _, _ := newSignedTestBot("s3cret")
+
+	layer := bot.NewLayer("Pick")
+	if err := layer.RegisterIButtonData("Long", Payload("item", strings.Repeat("x", 55)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.SendMsg(42, layer); err == nil {
+		t.Fatal("expected error for signed data over 64 bytes")
+	}
+}
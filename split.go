@@ -0,0 +1,336 @@
+package bf
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// splitToken is a piece of text that is never cut: a character, an HTML tag
+// or character reference, or a Markdown escape or delimiter. units is its
+// visible length (markup counts zero); open is the markup still open after
+// the token: HTML opening tags, or Markdown delimiters.
+type splitToken struct {
+	text  string
+	units int
+	open  []string
+}
+
+// Split preferences, best first.
+const (
+	cutAnywhere = iota
+	cutAtSpace
+	cutAtLine
+	cutAtParagraph
+)
+
+// SplitText cuts text into chunks Telegram accepts: at most limit characters
+// each (MaxTextLength for messages, MaxCaptionLength for captions), counted
+// the way Telegram counts them. Chunks end at a paragraph break if there is
+// one in the second half of the chunk, else at a line break, else at a
+// space.
+//
+// parseMode is the mode the chunks are sent with. HTML tags and character
+// references are never cut; tags open at a cut are closed at the end of the
+// chunk and reopened at the start of the next. Markdown entities are never
+// cut either, except when a single entity is longer than limit. Text within
+// limit is returned as is.
+func SplitText(text string, limit int, parseMode string) []string {
+	if limit <= 0 || utf16Len(text) <= limit {
+		return []string{text}
+	}
+
+	var tokens []splitToken
+	switch parseMode {
+	case tgbotapi.ModeHTML:
+		tokens = tokenizeHTML(text)
+	case tgbotapi.ModeMarkdown:
+		tokens = tokenizeMarkdown(text, false)
+	case tgbotapi.ModeMarkdownV2:
+		tokens = tokenizeMarkdown(text, true)
+	default:
+		tokens = tokenizePlain(text)
+	}
+	// Plain text has no markup and HTML reopens it, so both can be cut
+	// anywhere; Markdown only outside entities.
+	anywhere := parseMode != tgbotapi.ModeMarkdown && parseMode != tgbotapi.ModeMarkdownV2
+
+	var chunks []string
+	prefix := ""
+	start := 0
+	for {
+		for start < len(tokens) && isSplitSpace(tokens[start].text) {
+			start++
+		}
+		if start == len(tokens) {
+			break
+		}
+
+		end, used := start, 0
+		for end < len(tokens) && used+tokens[end].units <= limit {
+			used += tokens[end].units
+			end++
+		}
+		if end == len(tokens) {
+			chunks = append(chunks, prefix+joinTokens(tokens[start:end]))
+			break
+		}
+
+		cut := bestCut(tokens, start, end, limit, anywhere)
+		chunk := strings.TrimRight(prefix+joinTokens(tokens[start:cut]), " \n")
+		prefix = ""
+		if parseMode == tgbotapi.ModeHTML {
+			open := tokens[cut-1].open
+			chunk += closingTags(open)
+			prefix = strings.Join(open, "")
+		}
+		chunks = append(chunks, chunk)
+		start = cut
+	}
+
+	return chunks
+}
+
+// bestCut picks where the chunk tokens[start:end] ends. anywhere allows cuts
+// inside open markup (HTML reopens it; plain text has none).
+func bestCut(tokens []splitToken, start, end, budget int, anywhere bool) int {
+	best, bestRank := -1, -1
+	used := 0
+	for cut := start + 1; cut <= end; cut++ {
+		used += tokens[cut-1].units
+		prev := tokens[cut-1]
+		if !anywhere && len(prev.open) > 0 {
+			continue
+		}
+
+		next := ""
+		if cut < len(tokens) {
+			next = tokens[cut].text
+		}
+		rank := cutAnywhere
+		switch {
+		case prev.text == "\n" && (next == "\n" || cut-2 >= start && tokens[cut-2].text == "\n"):
+			rank = cutAtParagraph
+		case prev.text == "\n" || next == "\n":
+			rank = cutAtLine
+		case prev.text == " " || next == " ":
+			rank = cutAtSpace
+		}
+		// Cuts in the second half of the chunk win over better-ranked
+		// ones that would leave it mostly empty.
+		if used*2 >= budget {
+			rank += cutAtParagraph + 1
+		}
+		if rank >= bestRank {
+			best, bestRank = cut, rank
+		}
+	}
+
+	if best < 0 {
+		return max(end, start+1)
+	}
+
+	return best
+}
+
+func tokenizePlain(text string) []splitToken {
+	tokens := make([]splitToken, 0, len(text))
+	for _, r := range text {
+		s := string(r)
+		tokens = append(tokens, splitToken{text: s, units: utf16.RuneLen(r)})
+	}
+
+	return tokens
+}
+
+func tokenizeHTML(text string) []splitToken {
+	var (
+		tokens []splitToken
+		open   []string
+	)
+	for i := 0; i < len(text); {
+		tok := ""
+		switch text[i] {
+		case '<':
+			if end := strings.IndexByte(text[i:], '>'); end > 0 {
+				tok = text[i : i+end+1]
+			}
+		case '&':
+			if end := strings.IndexByte(text[i:], ';'); end > 0 && end <= 10 {
+				tok = text[i : i+end+1]
+			}
+		}
+
+		units := 0
+		switch {
+		case tok == "":
+			_, size := utf8.DecodeRuneInString(text[i:])
+			tok = text[i : i+size]
+			units = utf16Len(tok)
+		case tok[0] == '&':
+			units = 1
+		case strings.HasPrefix(tok, "</"):
+			open = closeTag(open, htmlTagName(tok))
+		case !strings.HasSuffix(tok, "/>"):
+			open = append(slices.Clip(open), tok)
+		}
+
+		tokens = append(tokens, splitToken{text: tok, units: units, open: open})
+		i += len(tok)
+	}
+
+	return tokens
+}
+
+// htmlTagName returns the lower-cased name of an opening or closing tag.
+func htmlTagName(tag string) string {
+	name := strings.TrimLeft(tag, "</")
+	if i := strings.IndexAny(name, " \t\n>/"); i >= 0 {
+		name = name[:i]
+	}
+
+	return strings.ToLower(name)
+}
+
+// closeTag returns open without the innermost tag named name.
+func closeTag(open []string, name string) []string {
+	for i := len(open) - 1; i >= 0; i-- {
+		if htmlTagName(open[i]) == name {
+			return slices.Delete(slices.Clone(open), i, i+1)
+		}
+	}
+
+	return open
+}
+
+// closingTags closes the open tags, innermost first.
+func closingTags(open []string) string {
+	var b strings.Builder
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + htmlTagName(open[i]) + ">")
+	}
+
+	return b.String()
+}
+
+// tokenizeMarkdown tokenizes Markdown (v2 selects MarkdownV2). Code keeps
+// everything up to its closing delimiter literal, as Telegram does.
+func tokenizeMarkdown(text string, v2 bool) []splitToken {
+	var (
+		tokens []splitToken
+		open   []string
+	)
+	inCode := func() bool {
+		return len(open) > 0 && (open[len(open)-1] == "```" || open[len(open)-1] == "`")
+	}
+	toggle := func(delim string) {
+		if i := slices.Index(open, delim); i >= 0 {
+			open = slices.Delete(slices.Clone(open), i, i+1)
+			return
+		}
+		open = append(slices.Clip(open), delim)
+	}
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		tok := ""
+		switch {
+		case rest[0] == '\\' && len(rest) > 1:
+			_, size := utf8.DecodeRuneInString(rest[1:])
+			tok = rest[:1+size]
+		case strings.HasPrefix(rest, "```"):
+			tok = "```"
+			if !inCode() || open[len(open)-1] == tok {
+				toggle(tok)
+			}
+		case rest[0] == '`':
+			tok = "`"
+			if !inCode() || open[len(open)-1] == tok {
+				toggle(tok)
+			}
+		case inCode():
+		case strings.HasPrefix(rest, "]("):
+			end := strings.IndexByte(rest, ')')
+			if v2 {
+				end = indexUnescaped(rest, ')')
+			}
+			if end > 0 {
+				tok = rest[:end+1]
+				open = closeDelim(open, "[")
+			}
+		case rest[0] == '[':
+			tok = "["
+			open = append(slices.Clip(open), tok)
+		case v2 && (strings.HasPrefix(rest, "||") || strings.HasPrefix(rest, "__")):
+			tok = rest[:2]
+			toggle(tok)
+		case rest[0] == '*' || rest[0] == '_' || v2 && rest[0] == '~':
+			tok = rest[:1]
+			toggle(tok)
+		}
+
+		units := 0
+		if tok == "" {
+			_, size := utf8.DecodeRuneInString(rest)
+			tok = rest[:size]
+			units = utf16Len(tok)
+		} else if tok[0] == '\\' {
+			// Counted with the backslash: legacy Markdown keeps it in code.
+			units = utf16Len(tok)
+		}
+
+		tokens = append(tokens, splitToken{text: tok, units: units, open: open})
+		i += len(tok)
+	}
+
+	return tokens
+}
+
+// closeDelim returns open without its innermost delim.
+func closeDelim(open []string, delim string) []string {
+	if i := slices.Index(open, delim); i >= 0 {
+		return slices.Delete(slices.Clone(open), i, i+1)
+	}
+
+	return open
+}
+
+// indexUnescaped is strings.IndexByte skipping MarkdownV2 escapes.
+func indexUnescaped(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case c:
+			return i
+		}
+	}
+
+	return -1
+}
+
+func joinTokens(tokens []splitToken) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteString(t.text)
+	}
+
+	return b.String()
+}
+
+func isSplitSpace(s string) bool {
+	return s == " " || s == "\n"
+}
+
+// utf16Len is the length of s as Telegram counts it, in UTF-16 code units.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+
+	return n
+}
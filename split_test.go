@@ -0,0 +1,137 @@
+package bf
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestSplitText_ShortTextUntouched(t *testing.T) {
+	got := SplitText("hello", 10, tgbotapi.ModeHTML)
+	if len(got) != 1 || got[0] != "hello" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestSplitText_PrefersParagraphThenLine(t *testing.T) {
+	got := SplitText("aaaa bbbb\ncccc dddd eeee", 16, "")
+	if len(got) != 2 || got[0] != "aaaa bbbb" || got[1] != "cccc dddd eeee" {
+		t.Fatalf("line cut: %q", got)
+	}
+
+	text := "aaaa bbbb\n\ncccc dddd\neeee"
+	got = SplitText(text, 20, "")
+	if len(got) != 2 || got[0] != "aaaa bbbb" || got[1] != "cccc dddd\neeee" {
+		t.Fatalf("paragraph over line: %q", got)
+	}
+
+	got = SplitText(text, 12, "")
+	if len(got) != 3 || got[0] != "aaaa bbbb" || got[1] != "cccc dddd" || got[2] != "eeee" {
+		t.Fatalf("paragraph cut: %q", got)
+	}
+
+	// A paragraph break early in the chunk loses to a space late in it.
+	got = SplitText("ab\n\ncdefgh ijklmn", 12, "")
+	if got[0] != "ab\n\ncdefgh" {
+		t.Fatalf("early paragraph: %q", got)
+	}
+}
+
+func TestSplitText_HardCutAndUTF16(t *testing.T) {
+	got := SplitText(strings.Repeat("x", 25), 10, "")
+	if len(got) != 3 || got[0] != strings.Repeat("x", 10) || got[2] != "xxxxx" {
+		t.Fatalf("hard cut: %q", got)
+	}
+
+	// Emoji outside the BMP count twice, as Telegram counts them.
+	got = SplitText(strings.Repeat("😀", 6), 4, "")
+	if len(got) != 3 || got[0] != "😀😀" {
+		t.Fatalf("utf-16: %q", got)
+	}
+}
+
+func TestSplitText_HTMLClosesAndReopensTags(t *testing.T) {
+	text := `<b>bold <a href="https://x.io">link text here</a> more</b> &amp; tail`
+	got := SplitText(text, 12, tgbotapi.ModeHTML)
+
+	want := []string{
+		`<b>bold <a href="https://x.io">link</a></b>`,
+		`<b><a href="https://x.io">text here</a></b>`,
+		`<b>more</b> &amp; tail`,
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("got:\n%q\nwant:\n%q", got, want)
+	}
+
+	got = SplitText("x&amp;y&lt;z", 2, tgbotapi.ModeHTML)
+	if strings.Join(got, "|") != "x&amp;|y&lt;|z" {
+		t.Fatalf("character reference cut: %q", got)
+	}
+}
+
+func TestSplitText_MarkdownNeverCutsEntities(t *testing.T) {
+	text := "intro *bold words here* end"
+	got := SplitText(text, 20, tgbotapi.ModeMarkdown)
+	if len(got) != 2 || got[0] != "intro" || got[1] != "*bold words here* end" {
+		t.Fatalf("markdown: %q", got)
+	}
+
+	code := "text\n```\nline one\nline two\n```\nafter"
+	got = SplitText(code, 20, tgbotapi.ModeMarkdownV2)
+	if got[0] != "text" || !strings.HasPrefix(got[1], "```") || !strings.HasSuffix(got[1], "```") {
+		t.Fatalf("code block cut: %q", got)
+	}
+
+	escaped := `a\*b ` + strings.Repeat("c", 10)
+	got = SplitText(escaped, 8, tgbotapi.ModeMarkdownV2)
+	if got[0] != `a\*b` {
+		t.Fatalf("escape treated as entity: %q", got)
+	}
+}
+
+func TestSendMsg_SplitsLongTextKeyboardOnLast(t *testing.T) {
+	bot, mock := newTestBot()
+	mock.sendResp = tgbotapi.Message{MessageID: 3}
+
+	layer := bot.NewLayer(strings.Repeat("word ", 1000))
+	layer.RegisterIButton("OK", func(context.Context, Event) error { return nil })
+	if err := bot.SendMsg(42, layer); err != nil {
+		t.Fatal(err)
+	}
+
+	if mock.sentCount() != 2 {
+		t.Fatalf("want 2 messages, got %d", mock.sentCount())
+	}
+	first := mock.sent[0].(tgbotapi.MessageConfig)
+	last := mock.sent[1].(tgbotapi.MessageConfig)
+	if first.ReplyMarkup != nil || last.ReplyMarkup == nil {
+		t.Fatal("only the last chunk may carry the keyboard")
+	}
+	if len(first.Text) > MaxTextLength || first.ParseMode != bot.parseMode {
+		t.Fatalf("first chunk: %d bytes, mode %q", len(first.Text), first.ParseMode)
+	}
+	if bot.peekLayer(chatKey(42)) != layer {
+		t.Fatal("layer not installed")
+	}
+}
+
+func TestSendMsg_SplitFailureDoesNotInstallLayer(t *testing.T) {
+	bot, mock := newTestBot()
+	mock.sendErr = errors.New("flood")
+
+	layer := bot.NewLayer(strings.Repeat("word ", 1000))
+	err := bot.SendMsg(42, layer)
+	if err == nil || !strings.Contains(err.Error(), "part 1 of 2") {
+		t.Fatalf("err: %v", err)
+	}
+	if bot.peekLayer(chatKey(42)) != nil {
+		t.Fatal("layer installed although the text was not delivered")
+	}
+
+	if err := bot.SendText(42, strings.Repeat("x", MaxTextLength+1)); err == nil {
+		t.Fatal("SendText must report the failed part")
+	}
+}
@@ -0,0 +1,60 @@
+package bf
+
+import (
+	"context"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// defaultStaleButtonText is the callback answer shown for a dead button.
+const defaultStaleButtonText = "This button has expired."
+
+// StaleButtonHookFunc observes inline-button taps that no layer handles,
+// e.g. to count them. It runs after the stale answer has been sent.
+type StaleButtonHookFunc func(ctx context.Context, event Event)
+
+// isStaleCallback reports whether event is an inline-button tap that neither
+// the tapped message's layer, the chat layer, the RegisterTopic layer nor the
+// default layer has a button or route handler for: the layer was consumed,
+// expired or lost in a restart. Nothing is consumed by the check.
+func (b *ChatBotImpl) isStaleCallback(event Event) bool {
+	if event.Kind != EventKindInlineButton {
+		return false
+	}
+
+	for _, layer := range []*HandlerLayer{b.messageLayerFor(event), b.peekEventLayer(event), b.topicLayer(event)} {
+		if layer != nil && layer.inlineHandler(event) != nil {
+			return false
+		}
+	}
+
+	b.defaultLayerMutex.RLock()
+	defer b.defaultLayerMutex.RUnlock()
+
+	return b.defaultHandlerLayer == nil || b.defaultHandlerLayer.inlineHandler(event) == nil
+}
+
+// handleStaleCallback answers a dead button instead of routing it to the
+// default handler: a short notice (WithStaleButtonText), optionally removing
+// the dead keyboard (WithStaleKeyboardRemoval), then the stale hook.
+func (b *ChatBotImpl) handleStaleCallback(ctx context.Context, event Event) {
+	b.logger.Debugf("stale inline button in chat %d: %q", event.ChatID, event.Button)
+
+	if event.CallbackQueryID != "" {
+		answer := tgbotapi.NewCallback(event.CallbackQueryID, b.staleButtonText)
+		if _, err := b.tgbot.Request(answer); err != nil {
+			b.logger.Errorf("failed to answer stale callback: %s", err)
+		}
+	}
+
+	if b.removeStaleKeyboard && event.MessageID != 0 {
+		empty := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+		if _, err := b.tgbot.Send(tgbotapi.NewEditMessageReplyMarkup(event.ChatID, event.MessageID, empty)); err != nil {
+			b.logger.Errorf("failed to remove stale keyboard: %s", err)
+		}
+	}
+
+	if b.staleButtonHook != nil {
+		b.staleButtonHook(ctx, event)
+	}
+}
@@ -0,0 +1,88 @@
+package bf
+
+import (
+	"context"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func staleTap(data string, messageID int) tgbotapi.Update {
+	return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "cb-1",
+		Data:    data,
+		From:    &tgbotapi.User{ID: 1},
+		Message: &tgbotapi.Message{MessageID: messageID, Chat: &tgbotapi.Chat{ID: 42}},
+	}}
+}
+
+func TestStaleButton_AnsweredInsteadOfDefaultHandler(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	defaultCalled := false
+	bot.RegisterDefaultHandler(func(context.Context, Event) error { defaultCalled = true; return nil })
+
+	var hooked Event
+	WithStaleButtonHook(func(_ context.Context, event Event) { hooked = event })(bot)
+
+	// A live chat layer must survive the stale tap.
+	layer := bot.NewLayer("Next")
+	layer.RegisterText(AnyText, func(context.Context, Event) error { return nil })
+	if err := bot.SendMsg(42, layer); err != nil {
+		t.Fatal(err)
+	}
+
+	bot.handleUpdate(context.Background(), c, staleTap("3f0e8a34-0000-4000-8000-000000000000", 5))
+
+	if defaultCalled {
+		t.Fatal("stale tap reached the default handler")
+	}
+	if len(mock.requests) != 1 {
+		t.Fatalf("want one callback answer, got %d", len(mock.requests))
+	}
+	answer, ok := mock.requests[0].(tgbotapi.CallbackConfig)
+	if !ok || answer.CallbackQueryID != "cb-1" || answer.Text != defaultStaleButtonText {
+		t.Fatalf("bad answer: %#v", mock.requests[0])
+	}
+	if hooked.CallbackQueryID != "cb-1" {
+		t.Fatalf("hook not called: %+v", hooked)
+	}
+	if bot.peekLayer(chatKey(42)) != layer {
+		t.Fatal("stale tap consumed the chat layer")
+	}
+	if _, isEdit := mock.lastSent().(tgbotapi.EditMessageReplyMarkupConfig); isEdit {
+		t.Fatal("keyboard removed without WithStaleKeyboardRemoval")
+	}
+}
+
+func TestStaleButton_KeyboardRemovalAndCustomText(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+	WithStaleButtonText("Too late!")(bot)
+	WithStaleKeyboardRemoval()(bot)
+
+	bot.handleUpdate(context.Background(), c, staleTap("gone", 5))
+
+	if answer := mock.requests[0].(tgbotapi.CallbackConfig); answer.Text != "Too late!" {
+		t.Fatalf("answer text: %q", answer.Text)
+	}
+	edit, ok := mock.lastSent().(tgbotapi.EditMessageReplyMarkupConfig)
+	if !ok || edit.MessageID != 5 || len(edit.ReplyMarkup.InlineKeyboard) != 0 {
+		t.Fatalf("want keyboard removal on message 5, got %#v", mock.lastSent())
+	}
+}
+
+func TestStaleButton_RoutedButtonsAreLive(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	called := 0
+	bot.RegisterIButtonRoute("item", func(context.Context, Event) error { called++; return nil })
+
+	bot.handleUpdate(context.Background(), c, staleTap("item:1", 5))
+
+	if called != 1 || len(mock.requests) != 0 {
+		t.Fatalf("routed button treated as stale: called=%d answers=%d", called, len(mock.requests))
+	}
+}
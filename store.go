@@ -0,0 +1,63 @@
+package bf
+
+import (
+	"context"
+	"sync"
+)
+
+// SessionStore persists small pieces of per-chat state (partial form answers,
+// user preferences) between events. Values are opaque bytes; callers encode
+// them themselves, usually as JSON.
+//
+// The default store keeps everything in process memory. Plug a shared
+// implementation (Redis, SQL) via WithSessionStore when several bot instances
+// serve the same users or state must survive restarts.
+type SessionStore interface {
+	// Get returns the value under key; ok is false when nothing is stored.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set stores value under key, replacing any previous value.
+	Set(ctx context.Context, key string, value []byte) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+var _ SessionStore = &MemorySessionStore{}
+
+// MemorySessionStore is the in-process SessionStore used by default.
+// Safe for concurrent use.
+type MemorySessionStore struct {
+	mu     sync.RWMutex
+	values map[string][]byte
+}
+
+// NewMemorySessionStore returns an empty in-memory store.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{values: make(map[string][]byte)}
+}
+
+// Get implements SessionStore.
+func (s *MemorySessionStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.values[key]
+	return value, ok, nil
+}
+
+// Set implements SessionStore. The value is copied, so the caller may reuse it.
+func (s *MemorySessionStore) Set(_ context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key] = append([]byte(nil), value...)
+	return nil
+}
+
+// Delete implements SessionStore.
+func (s *MemorySessionStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.values, key)
+	return nil
+}
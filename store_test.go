@@ -0,0 +1,46 @@
+package bf
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemorySessionStore_RoundTrip(t *testing.T) {
+	s := NewMemorySessionStore()
+	ctx := context.Background()
+
+	if _, ok, err := s.Get(ctx, "k"); ok || err != nil {
+		t.Fatalf("empty store: ok=%v err=%v", ok, err)
+	}
+
+	value := []byte("v1")
+	if err := s.Set(ctx, "k", value); err != nil {
+		t.Fatal(err)
+	}
+	value[0] = 'x'
+
+	got, ok, err := s.Get(ctx, "k")
+	if err != nil || !ok || string(got) != "v1" {
+		t.Fatalf("want v1, got %q ok=%v err=%v", got, ok, err)
+	}
+
+	if err := s.Delete(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := s.Get(ctx, "k"); ok {
+		t.Fatal("key survived Delete")
+	}
+}
+
+func TestWithSessionStore(t *testing.T) {
+	custom := NewMemorySessionStore()
+	bot := newSkeleton([]BotOption{WithSessionStore(custom)})
+	if bot.sessionStore != custom {
+		t.Fatal("custom store not installed")
+	}
+
+	bot = newSkeleton([]BotOption{WithSessionStore(nil)})
+	if bot.sessionStore == nil {
+		t.Fatal("nil store must be ignored")
+	}
+}
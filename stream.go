@@ -0,0 +1,217 @@
+package bf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ErrStreamClosed is returned by TextStream.Write after Close.
+var ErrStreamClosed = errors.New("text stream closed")
+
+// TextStream is an io.Writer that shows text in a chat as it is written:
+//
+//	stream := bot.StreamText(event.ChatID)
+//	for chunk := range model.Generate(ctx, prompt) {
+//	    if _, err := io.WriteString(stream, chunk); err != nil {
+//	        return err
+//	    }
+//	}
+//	return stream.Close()
+//
+// The first non-blank write sends a message; later writes are coalesced into
+// edits at most once per second. Past 4096 characters the message is
+// finished and the text continues in a new one, preferably at a line break.
+// While streaming the text is sent plain, since half-written markup would
+// not parse; each message is finished with the bot's parse mode, so write
+// markup that is valid per message. Close must be called to finish the last
+// message.
+//
+// TextStream is safe for concurrent use, though concurrent writers interleave.
+type TextStream struct {
+	bot    *ChatBotImpl
+	chatID int64
+	thread int
+
+	mu        sync.Mutex
+	buf       []byte
+	messageID int
+	shown     string
+	lastEdit  time.Time
+	flush     *time.Timer
+	closed    bool
+	err       error
+}
+
+// StreamText starts a TextStream to chatID. Nothing is sent until the first
+// non-blank write.
+func (b *ChatBotImpl) StreamText(chatID int64) *TextStream {
+	return &TextStream{bot: b, chatID: chatID}
+}
+
+// InThread posts the stream to a forum topic, e.g.
+// bot.StreamText(ev.ChatID).InThread(ev.ThreadID). Call it before the first
+// write; without it the stream goes to the general topic.
+func (s *TextStream) InThread(threadID int) *TextStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.thread = threadID
+	return s
+}
+
+// Write appends p to the streamed text. It only fails when the stream is
+// closed or a new message cannot be sent; failed edits are logged and
+// reported by Close.
+func (s *TextStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, ErrStreamClosed
+	}
+	s.buf = append(s.buf, p...)
+
+	// Full messages are finished; the last chunk, which reopens the tags
+	// the cut closed, keeps streaming.
+	text := s.text()
+	chunks := SplitText(text, MaxTextLength, s.bot.apiParseMode())
+	for _, chunk := range chunks[:len(chunks)-1] {
+		if err := s.finish(chunk); err != nil {
+			return len(p), err
+		}
+		s.messageID, s.shown = 0, ""
+	}
+	if len(chunks) > 1 {
+		s.buf = append([]byte(chunks[len(chunks)-1]), s.buf[len(text):]...)
+	}
+
+	if s.flush != nil {
+		return len(p), nil
+	}
+	if wait := streamEditGap() - time.Since(s.lastEdit); wait > 0 {
+		s.flush = time.AfterFunc(wait, s.flushPending)
+		return len(p), nil
+	}
+
+	return len(p), s.show()
+}
+
+// Close finishes the current message with the bot's parse mode and returns
+// the first error met while streaming. Further writes fail.
+func (s *TextStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return s.err
+	}
+	s.closed = true
+	if s.flush != nil {
+		s.flush.Stop()
+		s.flush = nil
+	}
+	if err := s.finish(s.text()); err != nil {
+		s.fail(err)
+	}
+
+	return s.err
+}
+
+// text is the current message's text without a trailing partial rune.
+func (s *TextStream) text() string {
+	end := len(s.buf)
+	for i := end - 1; i >= 0 && i >= end-utf8.UTFMax; i-- {
+		if utf8.RuneStart(s.buf[i]) {
+			if !utf8.FullRune(s.buf[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+
+	return string(s.buf[:end])
+}
+
+func (s *TextStream) flushPending() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.flush = nil
+	if s.closed {
+		return
+	}
+	if err := s.show(); err != nil {
+		s.fail(err)
+	}
+}
+
+// show sends or edits the current message as plain text; the caller holds
+// s.mu.
+func (s *TextStream) show() error {
+	text := s.text()
+	if strings.TrimSpace(text) == "" || text == s.shown {
+		return nil
+	}
+
+	s.lastEdit = time.Now()
+	if s.messageID == 0 {
+		sent, err := s.bot.tgbot.Send(inThread(tgbotapi.NewMessage(s.chatID, text), s.thread))
+		if err != nil {
+			return fmt.Errorf("failed to send stream message: %w", err)
+		}
+		s.messageID = sent.MessageID
+		s.shown = text
+		return nil
+	}
+
+	if _, err := s.bot.tgbot.Send(tgbotapi.NewEditMessageText(s.chatID, s.messageID, text)); err != nil {
+		s.bot.logger.Errorf("failed to edit stream message: %s", err)
+		s.fail(fmt.Errorf("failed to edit stream message: %w", err))
+		return nil
+	}
+	s.shown = text
+
+	return nil
+}
+
+// finish shows text as the final content of the current message, formatted
+// with the bot's parse mode; the caller holds s.mu.
+func (s *TextStream) finish(text string) error {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+
+	if s.messageID == 0 {
+		msg := tgbotapi.NewMessage(s.chatID, text)
+		msg.ParseMode = s.bot.apiParseMode()
+		if _, err := s.bot.tgbot.Send(inThread(msg, s.thread)); err != nil {
+			return fmt.Errorf("failed to send stream message: %w", err)
+		}
+		return nil
+	}
+
+	edit := tgbotapi.NewEditMessageText(s.chatID, s.messageID, text)
+	edit.ParseMode = s.bot.apiParseMode()
+	if _, err := s.bot.tgbot.Send(edit); err != nil && !isNotModified(err) {
+		return fmt.Errorf("failed to finish stream message: %w", err)
+	}
+
+	return nil
+}
+
+func (s *TextStream) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// isNotModified reports Telegram's refusal of an edit that changes nothing.
+func isNotModified(err error) bool {
+	return strings.Contains(err.Error(), "message is not modified")
+}
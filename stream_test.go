@@ -0,0 +1,178 @@
+package bf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func withStreamDelay(t *testing.T, d time.Duration) {
+	t.Helper()
+	orig := streamEditDelay.Load()
+	streamEditDelay.Store(int64(d))
+	t.Cleanup(func() { streamEditDelay.Store(orig) })
+}
+
+func TestTextStream_CoalescesWritesIntoEdits(t *testing.T) {
+	withStreamDelay(t, 30*time.Millisecond)
+	bot, mock := newTestBot()
+	mock.sendResp = tgbotapi.Message{MessageID: 5}
+
+	stream := bot.StreamText(42)
+	if _, err := io.WriteString(stream, "  "); err != nil {
+		t.Fatal(err)
+	}
+	if mock.sentCount() != 0 {
+		t.Fatal("blank text must not be sent")
+	}
+
+	for _, chunk := range []string{"Hello", ", ", "<b>world</b>"} {
+		if _, err := io.WriteString(stream, chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := lastText(t, mock); got != "  Hello" {
+		t.Fatalf("first message: %q", got)
+	}
+	if mock.sentCount() != 1 {
+		t.Fatalf("writes inside the gap must be coalesced, got %d sends", mock.sentCount())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(mock.edits()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("pending text never flushed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if edits := mock.edits(); edits[0] != "  Hello, <b>world</b>" {
+		t.Fatalf("coalesced edit: %q", edits)
+	}
+
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	final, ok := mock.lastSent().(tgbotapi.EditMessageTextConfig)
+	if !ok || final.MessageID != 5 || final.ParseMode != bot.parseMode {
+		t.Fatalf("final edit: %#v", mock.lastSent())
+	}
+	if _, err := stream.Write([]byte("late")); !errors.Is(err, ErrStreamClosed) {
+		t.Fatalf("write after Close: %v", err)
+	}
+}
+
+func TestTextStream_RollsOverPastLimit(t *testing.T) {
+	withStreamDelay(t, time.Hour)
+	bot, mock := newTestBot()
+	mock.sendResp = tgbotapi.Message{MessageID: 5}
+
+	stream := bot.StreamText(42)
+	line := strings.Repeat("я", 99) + "\n"
+	for i := 0; i < 50; i++ {
+		if _, err := io.WriteString(stream, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var parts []string
+	for _, c := range mock.sent {
+		switch m := c.(type) {
+		case tgbotapi.MessageConfig:
+			parts = append(parts, m.Text)
+		case tgbotapi.EditMessageTextConfig:
+			parts[len(parts)-1] = m.Text
+		}
+	}
+	if len(parts) != 2 {
+		t.Fatalf("want 2 messages, got %d", len(parts))
+	}
+	for _, part := range parts {
+		if n := utf8.RuneCountInString(part); n > MaxTextLength {
+			t.Fatalf("message of %d characters", n)
+		}
+	}
+	if !strings.HasSuffix(parts[0], "я") || strings.HasPrefix(parts[1], "\n") {
+		t.Fatal("rollover must happen at a line break")
+	}
+	if got := strings.Count(parts[0]+"\n"+parts[1], "я"); got != 99*50 {
+		t.Fatalf("lost text: %d characters", got)
+	}
+}
+
+func TestTextStream_RolloverKeepsTagsAndCountsUTF16(t *testing.T) {
+	withStreamDelay(t, time.Hour)
+	bot, mock := newTestBot()
+	mock.sendResp = tgbotapi.Message{MessageID: 5}
+
+	// Each emoji is two UTF-16 units: 2100 of them overflow one message
+	// though they are only 2100 runes.
+	stream := bot.StreamText(42)
+	if _, err := io.WriteString(stream, "<b>"+strings.Repeat("😀", 2100)+"</b>"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var parts []string
+	for _, c := range mock.sent {
+		switch m := c.(type) {
+		case tgbotapi.MessageConfig:
+			parts = append(parts, m.Text)
+		case tgbotapi.EditMessageTextConfig:
+			parts[len(parts)-1] = m.Text
+		}
+	}
+	if len(parts) != 2 {
+		t.Fatalf("want 2 messages, got %d", len(parts))
+	}
+	for _, part := range parts {
+		if !strings.HasPrefix(part, "<b>") || !strings.HasSuffix(part, "</b>") {
+			t.Fatalf("tag not closed and reopened at the cut: %.20q…", part)
+		}
+		if n := utf16Len(strings.TrimSuffix(strings.TrimPrefix(part, "<b>"), "</b>")); n > MaxTextLength {
+			t.Fatalf("message of %d UTF-16 units", n)
+		}
+	}
+}
+
+func TestTextStream_SplitRuneAcrossWrites(t *testing.T) {
+	withStreamDelay(t, time.Hour)
+	bot, mock := newTestBot()
+	mock.sendResp = tgbotapi.Message{MessageID: 5}
+
+	stream := bot.StreamText(42)
+	euro := []byte("€")
+	_, _ = stream.Write(append([]byte("1 "), euro[:1]...))
+	_, _ = stream.Write(euro[1:])
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if edits := mock.edits(); len(edits) != 1 || edits[0] != "1 €" {
+		t.Fatalf("final text: %q", edits)
+	}
+}
+
+func TestTextStream_SendErrorAndNotModified(t *testing.T) {
+	withStreamDelay(t, 0)
+	bot, mock := newTestBot()
+	mock.sendErr = errors.New("boom")
+
+	if _, err := io.WriteString(bot.StreamText(42), "hi"); err == nil {
+		t.Fatal("failed first send must surface from Write")
+	}
+
+	if !isNotModified(fmt.Errorf("wrapped: %w",
+		errors.New("Bad Request: message is not modified: specified new message content"))) {
+		t.Fatal("not-modified error not recognised")
+	}
+}
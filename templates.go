@@ -0,0 +1,275 @@
+package bf
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// templateSet holds the message templates configured by WithTemplates.
+type templateSet struct {
+	fsys     fs.FS
+	patterns []string
+	// reload re-parses the files when they change; see WithTemplateReload.
+	reload bool
+
+	mu      sync.RWMutex
+	tmpl    *template.Template
+	version string
+}
+
+// WithTemplates loads message templates from the files of fsys matching the
+// patterns (fs.Glob syntax), e.g. WithTemplates(os.DirFS("texts"), "*.tmpl").
+// Templates use text/template; every file is a template named after its base
+// name, and templates defined in one file ({{define "footer"}}) can be used
+// as partials from any other. Besides the builtins they may call:
+//
+//	escape            escapes a value for the bot's parse mode: {{.Name | escape}}
+//	escapeHTML        escapes for tgbotapi.ModeHTML
+//	escapeMarkdown    escapes for tgbotapi.ModeMarkdown
+//	escapeMarkdownV2  escapes for tgbotapi.ModeMarkdownV2
+//	plural            picks a form by count: {{plural .N "%d file" "%d files"}}
+//
+// The files are parsed and checked when Start begins, before anything else:
+// syntax errors, unknown functions, patterns matching no file and partials
+// that are used but never defined make Start fail. Render them with
+// RenderTemplate or NewTemplateLayer.
+func WithTemplates(fsys fs.FS, patterns ...string) BotOption {
+	return func(bot *ChatBotImpl) {
+		reload := bot.templates != nil && bot.templates.reload
+		bot.templates = &templateSet{fsys: fsys, patterns: patterns, reload: reload}
+	}
+}
+
+// WithTemplateReload makes WithTemplates re-read its files whenever they
+// change, checked on every render; for development. A file that fails to
+// parse is logged and the previous templates stay in use.
+func WithTemplateReload() BotOption {
+	return func(bot *ChatBotImpl) {
+		if bot.templates == nil {
+			bot.templates = &templateSet{}
+		}
+		bot.templates.reload = true
+	}
+}
+
+// RenderTemplate executes the template name with data as its dot.
+func (b *ChatBotImpl) RenderTemplate(name string, data any) (string, error) {
+	tmpl, err := b.currentTemplates()
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	if err := tmpl.ExecuteTemplate(&out, name, data); err != nil {
+		return "", fmt.Errorf("failed to render template %q: %w", name, err)
+	}
+
+	return out.String(), nil
+}
+
+// NewTemplateLayer constructs a fresh layer whose text is the template name
+// rendered with data; register its handlers as with NewLayer.
+func (b *ChatBotImpl) NewTemplateLayer(name string, data any) (*HandlerLayer, error) {
+	text, err := b.RenderTemplate(name, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.NewLayer(text), nil
+}
+
+// loadTemplates parses the WithTemplates files, if any.
+func (b *ChatBotImpl) loadTemplates() error {
+	if b.templates == nil || b.templates.fsys == nil {
+		return nil
+	}
+
+	return b.templates.load(b.templateFuncs())
+}
+
+// currentTemplates returns the parsed templates, loading them on first use
+// and, with WithTemplateReload, again when the files change.
+func (b *ChatBotImpl) currentTemplates() (*template.Template, error) {
+	set := b.templates
+	if set == nil || set.fsys == nil {
+		return nil, errors.New("no templates configured; see WithTemplates")
+	}
+
+	set.mu.RLock()
+	tmpl, version := set.tmpl, set.version
+	set.mu.RUnlock()
+
+	if tmpl == nil {
+		if err := b.loadTemplates(); err != nil {
+			return nil, fmt.Errorf("failed to load templates: %w", err)
+		}
+	} else if !set.reload {
+		return tmpl, nil
+	} else if current, err := set.filesVersion(); err == nil && current != version {
+		if err := b.loadTemplates(); err != nil {
+			b.logger.Errorf("failed to reload templates, keeping the previous ones: %s", err)
+		}
+	}
+
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	return set.tmpl, nil
+}
+
+func (s *templateSet) load(funcs template.FuncMap) error {
+	version, err := s.filesVersion()
+	if err != nil {
+		return err
+	}
+
+	tmpl := template.New("").Funcs(funcs).Option("missingkey=error")
+	for _, pattern := range s.patterns {
+		if tmpl, err = tmpl.ParseFS(s.fsys, pattern); err != nil {
+			return fmt.Errorf("failed to parse templates: %w", err)
+		}
+	}
+	if err := checkPartials(tmpl); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.tmpl, s.version = tmpl, version
+	s.mu.Unlock()
+
+	return nil
+}
+
+// filesVersion identifies the current state of the template files by name,
+// size and modification time. Every pattern must match a file.
+func (s *templateSet) filesVersion() (string, error) {
+	var version strings.Builder
+	for _, pattern := range s.patterns {
+		names, err := fs.Glob(s.fsys, pattern)
+		if err != nil {
+			return "", fmt.Errorf("failed to match templates %q: %w", pattern, err)
+		}
+		if len(names) == 0 {
+			return "", fmt.Errorf("template pattern %q matches no files", pattern)
+		}
+		for _, name := range names {
+			info, err := fs.Stat(s.fsys, name)
+			if err != nil {
+				return "", fmt.Errorf("failed to stat template %q: %w", name, err)
+			}
+			fmt.Fprintf(&version, "%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+
+	return version.String(), nil
+}
+
+// checkPartials reports a {{template}} call to a template that is never
+// defined, which text/template would only find when executing it.
+func checkPartials(tmpl *template.Template) error {
+	defined := map[string]bool{}
+	for _, t := range tmpl.Templates() {
+		defined[t.Name()] = true
+	}
+
+	var missing []string
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		walkTemplateCalls(t.Tree.Root, func(name string) {
+			if !defined[name] {
+				missing = append(missing, fmt.Sprintf("%q uses undefined template %q", t.Name(), name))
+			}
+		})
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("failed to check templates: %s", strings.Join(slices.Compact(missing), "; "))
+	}
+
+	return nil
+}
+
+func walkTemplateCalls(node parse.Node, call func(name string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkTemplateCalls(child, call)
+		}
+	case *parse.TemplateNode:
+		call(n.Name)
+	case *parse.IfNode:
+		walkTemplateCalls(n.List, call)
+		walkTemplateCalls(n.ElseList, call)
+	case *parse.RangeNode:
+		walkTemplateCalls(n.List, call)
+		walkTemplateCalls(n.ElseList, call)
+	case *parse.WithNode:
+		walkTemplateCalls(n.List, call)
+		walkTemplateCalls(n.ElseList, call)
+	}
+}
+
+func (b *ChatBotImpl) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"escape":           func(v any) string { return Escape(fmt.Sprint(v), b.parseMode) },
+		"escapeHTML":       func(v any) string { return Escape(fmt.Sprint(v), tgbotapi.ModeHTML) },
+		"escapeMarkdown":   func(v any) string { return Escape(fmt.Sprint(v), tgbotapi.ModeMarkdown) },
+		"escapeMarkdownV2": func(v any) string { return Escape(fmt.Sprint(v), tgbotapi.ModeMarkdownV2) },
+		"plural":           plural,
+	}
+}
+
+// plural picks the form of forms for count n, replacing %d in it with n.
+// Two forms are singular and plural, as in English; three are the one, few
+// and many forms of Russian, Ukrainian and similar languages.
+func plural(n any, forms ...string) (string, error) {
+	count, err := strconv.ParseInt(fmt.Sprint(n), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("plural: count %v is not an integer", n)
+	}
+	if len(forms) == 0 {
+		return "", errors.New("plural: no forms given")
+	}
+
+	form := forms[0]
+	switch len(forms) {
+	case 1:
+	case 2:
+		if count != 1 && count != -1 {
+			form = forms[1]
+		}
+	default:
+		form = forms[slavicPluralForm(count)]
+	}
+
+	return strings.ReplaceAll(form, "%d", strconv.FormatInt(count, 10)), nil
+}
+
+// slavicPluralForm returns 0, 1 or 2 for the one, few and many forms.
+func slavicPluralForm(n int64) int {
+	if n < 0 {
+		n = -n
+	}
+	switch mod10, mod100 := n%10, n%100; {
+	case mod10 == 1 && mod100 != 11:
+		return 0
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return 1
+	}
+
+	return 2
+}
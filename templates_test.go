@@ -0,0 +1,152 @@
+package bf
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func templateFS() fstest.MapFS {
+	return fstest.MapFS{
+		"texts/welcome.tmpl": {Data: []byte(`<b>Hi, {{.Name | escape}}!</b>
+You have {{plural .Unread "%d new message" "%d new messages"}}.
+{{template "footer" .}}`)},
+		"texts/partials.tmpl": {Data: []byte(`{{define "footer"}}<i>{{.Bot}}</i>{{end}}`)},
+	}
+}
+
+type welcomeData struct {
+	Name   string
+	Unread int
+	Bot    string
+}
+
+func TestTemplates_RenderWithPartialsEscapingAndPlural(t *testing.T) {
+	bot, mock := newTestBot()
+	WithTemplates(templateFS(), "texts/*.tmpl")(bot)
+
+	layer, err := bot.NewTemplateLayer("welcome.tmpl", welcomeData{Name: "<Ann>", Unread: 1, Bot: "bf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.SendMsg(42, layer); err != nil {
+		t.Fatal(err)
+	}
+	want := "<b>Hi, &lt;Ann&gt;!</b>\nYou have 1 new message.\n<i>bf</i>"
+	if got := lastText(t, mock); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	got, err := bot.RenderTemplate("welcome.tmpl", welcomeData{Name: "Bob", Unread: 3})
+	if err != nil || !strings.Contains(got, "3 new messages") {
+		t.Fatalf("got %q, %v", got, err)
+	}
+}
+
+func TestTemplates_RenderErrors(t *testing.T) {
+	bot, _ := newTestBot()
+	if _, err := bot.RenderTemplate("welcome.tmpl", nil); err == nil {
+		t.Fatal("want an error without WithTemplates")
+	}
+
+	WithTemplates(templateFS(), "texts/*.tmpl")(bot)
+	if _, err := bot.RenderTemplate("missing.tmpl", nil); err == nil {
+		t.Fatal("want an error for an unknown template")
+	}
+	if _, err := bot.RenderTemplate("welcome.tmpl", map[string]any{"Name": "x"}); err == nil {
+		t.Fatal("want an error for a missing key")
+	}
+}
+
+func TestTemplates_StartFailsOnBrokenTemplates(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{"syntax", fstest.MapFS{"a.tmpl": {Data: []byte("{{if .X}}")}}, "failed to parse templates"},
+		{"unknown func", fstest.MapFS{"a.tmpl": {Data: []byte("{{shout .X}}")}}, "function \"shout\" not defined"},
+		{"undefined partial", fstest.MapFS{"a.tmpl": {Data: []byte(`{{if .X}}{{template "nope"}}{{end}}`)}},
+			`"a.tmpl" uses undefined template "nope"`},
+		{"no files", fstest.MapFS{"a.txt": {Data: []byte("x")}}, `pattern "*.tmpl" matches no files`},
+	}
+	for _, tt := range tests {
+		bot, mock := newTestBot()
+		WithTemplates(tt.files, "*.tmpl")(bot)
+
+		err := bot.Start(context.Background())
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: want %q, got %v", tt.name, tt.want, err)
+		}
+		if mock.stopped.Load() {
+			t.Errorf("%s: Start must fail before polling", tt.name)
+		}
+	}
+}
+
+func TestTemplates_ReloadOnChange(t *testing.T) {
+	files := fstest.MapFS{"a.tmpl": {Data: []byte("v1"), ModTime: time.Unix(1, 0)}}
+	logger := &capturingLogger{}
+	bot, _ := newTestBot()
+	bot.logger = logger
+	WithTemplateReload()(bot)
+	WithTemplates(files, "*.tmpl")(bot)
+
+	if got, _ := bot.RenderTemplate("a.tmpl", nil); got != "v1" {
+		t.Fatalf("got %q", got)
+	}
+
+	files["a.tmpl"] = &fstest.MapFile{Data: []byte("v2"), ModTime: time.Unix(2, 0)}
+	if got, _ := bot.RenderTemplate("a.tmpl", nil); got != "v2" {
+		t.Fatalf("not reloaded: %q", got)
+	}
+
+	files["a.tmpl"] = &fstest.MapFile{Data: []byte("{{end}}"), ModTime: time.Unix(3, 0)}
+	if got, err := bot.RenderTemplate("a.tmpl", nil); err != nil || got != "v2" {
+		t.Fatalf("broken reload must keep v2, got %q, %v", got, err)
+	}
+	if !logger.logged("failed to reload templates") {
+		t.Fatal("reload failure not logged")
+	}
+}
+
+func TestTemplates_EscapeFollowsParseMode(t *testing.T) {
+	bot, _ := newTestBot()
+	bot.parseMode = tgbotapi.ModeMarkdownV2
+	WithTemplates(fstest.MapFS{"a.tmpl": {Data: []byte("*{{escape .}}* {{escapeHTML .}}")}}, "*.tmpl")(bot)
+
+	got, err := bot.RenderTemplate("a.tmpl", "1.5<")
+	if err != nil || got != `*1\.5<* 1.5&lt;` {
+		t.Fatalf("got %q, %v", got, err)
+	}
+}
+
+func TestPlural(t *testing.T) {
+	ru := []string{"%d файл", "%d файла", "%d файлов"}
+	tests := []struct {
+		n     int
+		forms []string
+		want  string
+	}{
+		{1, []string{"%d file", "%d files"}, "1 file"},
+		{0, []string{"%d file", "%d files"}, "0 files"},
+		{1, ru, "1 файл"},
+		{3, ru, "3 файла"},
+		{11, ru, "11 файлов"},
+		{21, ru, "21 файл"},
+		{112, ru, "112 файлов"},
+		{5, []string{"items"}, "items"},
+	}
+	for _, tt := range tests {
+		if got, err := plural(tt.n, tt.forms...); err != nil || got != tt.want {
+			t.Errorf("plural(%d, %q) = %q, %v; want %q", tt.n, tt.forms, got, err, tt.want)
+		}
+	}
+	if _, err := plural("many", "a", "b"); err == nil {
+		t.Fatal("want an error for a non-integer count")
+	}
+}
@@ -0,0 +1,354 @@
+package bf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// pollRetryDelay is how long pollUpdates waits after a failed getUpdates.
+const pollRetryDelay = 3 * time.Second
+
+// ForumTopicAction names the change a forum topic service message reports.
+type ForumTopicAction string
+
+// Forum topic changes delivered as EventKindForumTopic.
+const (
+	ForumTopicCreated  ForumTopicAction = "created"
+	ForumTopicEdited   ForumTopicAction = "edited"
+	ForumTopicClosed   ForumTopicAction = "closed"
+	ForumTopicReopened ForumTopicAction = "reopened"
+)
+
+// ForumTopicEvent describes a forum topic being created, edited, closed or
+// reopened. The topic itself is Event.ThreadID. Name and the icon are set on
+// creation, and on edits when they changed.
+type ForumTopicEvent struct {
+	Action            ForumTopicAction `json:"action"`
+	Name              string           `json:"name,omitempty"`
+	IconColor         int              `json:"iconColor,omitempty"`
+	IconCustomEmojiID string           `json:"iconCustomEmojiID,omitempty"`
+}
+
+// updateTopic carries the topic fields of an update that the vendored
+// tgbotapi.Update cannot hold.
+type updateTopic struct {
+	threadID int
+	change   *ForumTopicEvent
+}
+
+// topicReader is implemented by telegramAPI adapters that decode topic
+// fields; handleUpdate takes each update's fields exactly once.
+type topicReader interface {
+	takeTopic(updateID int) (updateTopic, bool)
+}
+
+// rawTopicMessage is the part of a Bot API message about forum topics.
+type rawTopicMessage struct {
+	MessageThreadID   int  `json:"message_thread_id"`
+	IsTopicMessage    bool `json:"is_topic_message"`
+	ForumTopicCreated *struct {
+		Name              string `json:"name"`
+		IconColor         int    `json:"icon_color"`
+		IconCustomEmojiID string `json:"icon_custom_emoji_id"`
+	} `json:"forum_topic_created"`
+	ForumTopicEdited *struct {
+		Name              string `json:"name"`
+		IconCustomEmojiID string `json:"icon_custom_emoji_id"`
+	} `json:"forum_topic_edited"`
+	ForumTopicClosed   *struct{} `json:"forum_topic_closed"`
+	ForumTopicReopened *struct{} `json:"forum_topic_reopened"`
+}
+
+type rawTopicUpdate struct {
+	Message       *rawTopicMessage `json:"message"`
+	CallbackQuery *struct {
+		Message *rawTopicMessage `json:"message"`
+	} `json:"callback_query"`
+}
+
+// decodeTopic extracts the topic fields of a raw update. ok is false for
+// updates outside forum topics.
+func decodeTopic(raw json.RawMessage) (updateTopic, bool) {
+	var update rawTopicUpdate
+	if err := json.Unmarshal(raw, &update); err != nil {
+		return updateTopic{}, false
+	}
+
+	msg := update.Message
+	if msg == nil && update.CallbackQuery != nil {
+		msg = update.CallbackQuery.Message
+	}
+	if msg == nil {
+		return updateTopic{}, false
+	}
+
+	topic := updateTopic{threadID: msg.MessageThreadID}
+	switch {
+	case msg.ForumTopicCreated != nil:
+		topic.change = &ForumTopicEvent{
+			Action:            ForumTopicCreated,
+			Name:              msg.ForumTopicCreated.Name,
+			IconColor:         msg.ForumTopicCreated.IconColor,
+			IconCustomEmojiID: msg.ForumTopicCreated.IconCustomEmojiID,
+		}
+	case msg.ForumTopicEdited != nil:
+		topic.change = &ForumTopicEvent{
+			Action:            ForumTopicEdited,
+			Name:              msg.ForumTopicEdited.Name,
+			IconCustomEmojiID: msg.ForumTopicEdited.IconCustomEmojiID,
+		}
+	case msg.ForumTopicClosed != nil:
+		topic.change = &ForumTopicEvent{Action: ForumTopicClosed}
+	case msg.ForumTopicReopened != nil:
+		topic.change = &ForumTopicEvent{Action: ForumTopicReopened}
+	}
+
+	// Replies in ordinary groups carry a message_thread_id as well; only
+	// topic messages belong to a forum topic.
+	if !msg.IsTopicMessage && topic.change == nil {
+		return updateTopic{}, false
+	}
+
+	return topic, topic.threadID != 0
+}
+
+// pollUpdates feeds ch from getUpdates until stop is closed, then closes ch.
+func (r *realTelegramAPI) pollUpdates(cfg tgbotapi.UpdateConfig, ch chan tgbotapi.Update, stop <-chan struct{}) {
+	defer close(ch)
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		updates, err := r.getUpdates(cfg)
+		if err != nil {
+			if r.logger != nil {
+				r.logger.Warnf("failed to get updates, retrying in %s: %s", pollRetryDelay, err)
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(pollRetryDelay):
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			if update.UpdateID < cfg.Offset {
+				continue
+			}
+			cfg.Offset = update.UpdateID + 1
+			select {
+			case ch <- update:
+			case <-stop:
+				return
+			}
+		}
+	}
+}
+
+// getUpdates is BotAPI.GetUpdates that also keeps each update's topic fields.
+func (r *realTelegramAPI) getUpdates(cfg tgbotapi.UpdateConfig) ([]tgbotapi.Update, error) {
+	resp, err := r.bot.Request(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request updates: %w", err)
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(resp.Result, &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode updates: %w", err)
+	}
+
+	updates := make([]tgbotapi.Update, 0, len(raw))
+	for _, item := range raw {
+		var update tgbotapi.Update
+		if err := json.Unmarshal(item, &update); err != nil {
+			return nil, fmt.Errorf("failed to decode update: %w", err)
+		}
+		if topic, ok := decodeTopic(item); ok {
+			r.topics.Store(update.UpdateID, topic)
+		}
+		updates = append(updates, update)
+	}
+
+	return updates, nil
+}
+
+func (r *realTelegramAPI) takeTopic(updateID int) (updateTopic, bool) {
+	topic, ok := r.topics.LoadAndDelete(updateID)
+	if !ok {
+		return updateTopic{}, false
+	}
+
+	return topic.(updateTopic), true
+}
+
+// threadMessage is a sendMessage addressed to a forum topic. The vendored
+// MessageConfig has no message_thread_id, so realTelegramAPI sends it with
+// hand-built parameters.
+type threadMessage struct {
+	tgbotapi.MessageConfig
+	threadID int
+}
+
+// inThread addresses msg to a forum topic; thread 0 leaves it as it is.
+func inThread(msg tgbotapi.MessageConfig, threadID int) tgbotapi.Chattable {
+	if threadID == 0 {
+		return msg
+	}
+
+	return threadMessage{MessageConfig: msg, threadID: threadID}
+}
+
+// threadChatAction is a sendChatAction shown in a forum topic; like
+// threadMessage, it is sent with hand-built parameters.
+type threadChatAction struct {
+	tgbotapi.ChatActionConfig
+	threadID int
+}
+
+// actionInThread addresses action to a forum topic; thread 0 leaves it as it
+// is.
+func actionInThread(action tgbotapi.ChatActionConfig, threadID int) tgbotapi.Chattable {
+	if threadID == 0 {
+		return action
+	}
+
+	return threadChatAction{ChatActionConfig: action, threadID: threadID}
+}
+
+func (r *realTelegramAPI) requestThreadChatAction(action threadChatAction) (*tgbotapi.APIResponse, error) {
+	params := tgbotapi.Params{}
+	if err := params.AddFirstValid("chat_id", action.ChatID, action.ChannelUsername); err != nil {
+		return nil, fmt.Errorf("failed to encode chat: %w", err)
+	}
+	params.AddNonZero("message_thread_id", action.threadID)
+	params.AddNonEmpty("action", action.Action)
+
+	return r.bot.MakeRequest("sendChatAction", params)
+}
+
+func (r *realTelegramAPI) sendThreadMessage(msg threadMessage) (tgbotapi.Message, error) {
+	params := tgbotapi.Params{}
+	if err := params.AddFirstValid("chat_id", msg.ChatID, msg.ChannelUsername); err != nil {
+		return tgbotapi.Message{}, fmt.Errorf("failed to encode chat: %w", err)
+	}
+	params.AddNonZero("message_thread_id", msg.threadID)
+	params.AddNonZero("reply_to_message_id", msg.ReplyToMessageID)
+	params.AddBool("disable_notification", msg.DisableNotification)
+	params.AddBool("allow_sending_without_reply", msg.AllowSendingWithoutReply)
+	params.AddNonEmpty("text", msg.Text)
+	params.AddBool("disable_web_page_preview", msg.DisableWebPagePreview)
+	params.AddNonEmpty("parse_mode", msg.ParseMode)
+	if err := params.AddInterface("reply_markup", msg.ReplyMarkup); err != nil {
+		return tgbotapi.Message{}, fmt.Errorf("failed to encode markup: %w", err)
+	}
+	if err := params.AddInterface("entities", msg.Entities); err != nil {
+		return tgbotapi.Message{}, fmt.Errorf("failed to encode entities: %w", err)
+	}
+
+	resp, err := r.bot.MakeRequest("sendMessage", params)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+
+	var sent tgbotapi.Message
+	if err := json.Unmarshal(resp.Result, &sent); err != nil {
+		return tgbotapi.Message{}, fmt.Errorf("failed to decode message: %w", err)
+	}
+
+	return sent, nil
+}
+
+// applyTopic adds the forum topic fields of update to event, turning topic
+// service messages into EventKindForumTopic.
+func (b *ChatBotImpl) applyTopic(event *Event, update tgbotapi.Update) {
+	reader, ok := b.tgbot.(topicReader)
+	if !ok {
+		return
+	}
+	topic, ok := reader.takeTopic(update.UpdateID)
+	if !ok {
+		return
+	}
+
+	event.ThreadID = topic.threadID
+	if topic.change != nil {
+		event.Kind = EventKindForumTopic
+		event.Text = ""
+		event.ForumTopic = topic.change
+	}
+}
+
+// threadKey is the context key under which handleUpdate passes the forum
+// topic of the event being handled.
+type threadKey struct{}
+
+func withThread(ctx context.Context, threadID int) context.Context {
+	return context.WithValue(ctx, threadKey{}, threadID)
+}
+
+// contextThread is the topic of the event whose handler ctx belongs to, or 0.
+func contextThread(ctx context.Context) int {
+	thread, _ := ctx.Value(threadKey{}).(int)
+	return thread
+}
+
+// layerThread is the topic a layer is sent to when thread is the topic of
+// the event it answers.
+func layerThread(layer *HandlerLayer, thread int) int {
+	if layer.scope.threadID != 0 {
+		return layer.scope.threadID
+	}
+
+	return thread
+}
+
+// RegisterTopic installs layer for one forum topic of a chat. Its handlers
+// serve the topic's events that no one-shot layer handles, before the
+// default layer; like the default layer it is never wiped. A nil layer
+// removes the registration.
+func (b *ChatBotImpl) RegisterTopic(chatID int64, threadID int, layer *HandlerLayer) {
+	key := scopeKey{chatID: chatID, threadID: threadID}
+
+	b.topicLayersMutex.Lock()
+	defer b.topicLayersMutex.Unlock()
+
+	if layer == nil {
+		delete(b.topicLayers, key)
+		return
+	}
+	b.topicLayers[key] = layer
+}
+
+// topicLayer returns the RegisterTopic layer for the event's topic, or nil.
+func (b *ChatBotImpl) topicLayer(event Event) *HandlerLayer {
+	if event.ThreadID == 0 {
+		return nil
+	}
+
+	b.topicLayersMutex.RLock()
+	defer b.topicLayersMutex.RUnlock()
+
+	return b.topicLayers[scopeKey{chatID: event.ChatID, threadID: event.ThreadID}]
+}
+
+// RegisterForumTopic binds a handler to forum topic changes (Event.ForumTopic)
+// on this layer.
+func (hl *HandlerLayer) RegisterForumTopic(handler HandlerFunc) {
+	hl.forumTopicHandler = handler
+}
+
+// RegisterForumTopic binds a forum topic change handler on the default layer.
+func (b *ChatBotImpl) RegisterForumTopic(handler HandlerFunc) {
+	b.defaultLayerMutex.Lock()
+	b.defaultHandlerLayer.RegisterForumTopic(handler)
+	b.defaultLayerMutex.Unlock()
+}
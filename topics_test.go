@@ -0,0 +1,313 @@
+package bf
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// topicUpdate is a text message from userID in topic threadID of forum -200.
+// The mock serves the topic fields under the update's ID.
+func topicUpdate(mock *mockTelegramAPI, updateID, threadID int, userID int64, text string) tgbotapi.Update {
+	mock.mu.Lock()
+	if mock.topics == nil {
+		mock.topics = map[int]updateTopic{}
+	}
+	mock.topics[updateID] = updateTopic{threadID: threadID}
+	mock.mu.Unlock()
+
+	return tgbotapi.Update{
+		UpdateID: updateID,
+		Message: &tgbotapi.Message{
+			Text: text,
+			Chat: &tgbotapi.Chat{ID: -200, Type: "supergroup"},
+			From: &tgbotapi.User{ID: userID},
+		},
+	}
+}
+
+func lastThread(t *testing.T, mock *mockTelegramAPI) int {
+	t.Helper()
+	switch msg := mock.lastSent().(type) {
+	case threadMessage:
+		return msg.threadID
+	case tgbotapi.MessageConfig:
+		return 0
+	}
+	t.Fatalf("last sent is %T, not a message", mock.lastSent())
+	return 0
+}
+
+func TestDecodeTopic(t *testing.T) {
+	cases := []struct {
+		name   string
+		raw    string
+		ok     bool
+		thread int
+		action ForumTopicAction
+	}{
+		{"topic message", `{"message":{"message_thread_id":5,"is_topic_message":true}}`, true, 5, ""},
+		{"group reply", `{"message":{"message_thread_id":5}}`, false, 0, ""},
+		{"plain message", `{"message":{"text":"hi"}}`, false, 0, ""},
+		{"button in topic", `{"callback_query":{"message":{"message_thread_id":9,"is_topic_message":true}}}`, true, 9, ""},
+		{"created", `{"message":{"message_thread_id":3,"is_topic_message":true,` +
+			`"forum_topic_created":{"name":"Bugs","icon_color":7322096}}}`, true, 3, ForumTopicCreated},
+		{"closed", `{"message":{"message_thread_id":3,"is_topic_message":true,"forum_topic_closed":{}}}`,
+			true, 3, ForumTopicClosed},
+	}
+	for _, tc := range cases {
+		topic, ok := decodeTopic([]byte(tc.raw))
+		if ok != tc.ok || topic.threadID != tc.thread {
+			t.Fatalf("%s: got %+v ok=%v", tc.name, topic, ok)
+		}
+		if tc.action == "" {
+			if topic.change != nil {
+				t.Fatalf("%s: unexpected change %+v", tc.name, topic.change)
+			}
+			continue
+		}
+		if topic.change == nil || topic.change.Action != tc.action {
+			t.Fatalf("%s: change %+v", tc.name, topic.change)
+		}
+	}
+
+	topic, _ := decodeTopic([]byte(`{"message":{"message_thread_id":3,"is_topic_message":true,` +
+		`"forum_topic_created":{"name":"Bugs","icon_color":7322096,"icon_custom_emoji_id":"e"}}}`))
+	if c := topic.change; c.Name != "Bugs" || c.IconColor != 7322096 || c.IconCustomEmojiID != "e" {
+		t.Fatalf("created fields: %+v", c)
+	}
+}
+
+func TestTopics_RepliesGoToTheEventThread(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	var got Event
+	bot.RegisterDefaultHandler(func(_ context.Context, ev Event) error {
+		got = ev
+		return bot.ReplyText(ev, "pong")
+	})
+
+	bot.handleUpdate(context.Background(), c, topicUpdate(mock, 1, 5, 1, "ping"))
+	if got.ThreadID != 5 {
+		t.Fatalf("ThreadID: %d", got.ThreadID)
+	}
+	if thread := lastThread(t, mock); thread != 5 {
+		t.Fatalf("reply sent to thread %d", thread)
+	}
+
+	// The general topic has no thread ID.
+	bot.handleUpdate(context.Background(), c, topicUpdate(mock, 2, 0, 1, "ping"))
+	if thread := lastThread(t, mock); thread != 0 {
+		t.Fatalf("general topic reply sent to thread %d", thread)
+	}
+
+	// SendText has no event and posts to the general topic.
+	if err := bot.SendText(-200, "news"); err != nil {
+		t.Fatal(err)
+	}
+	if thread := lastThread(t, mock); thread != 0 {
+		t.Fatalf("SendText sent to thread %d", thread)
+	}
+
+	// An explicit topic scope wins over the event's topic.
+	layer := bot.NewLayer("Elsewhere")
+	layer.SetScope(ScopeThread(8))
+	if err := bot.Reply(Event{ChatID: -200, ThreadID: 5}, layer); err != nil {
+		t.Fatal(err)
+	}
+	if thread := lastThread(t, mock); thread != 8 {
+		t.Fatalf("scoped layer sent to thread %d", thread)
+	}
+}
+
+func TestTopics_ConcurrentHandlersKeepTheirThread(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	release := make(chan struct{})
+	bot.RegisterDefaultHandler(func(_ context.Context, ev Event) error {
+		if ev.ThreadID == 5 {
+			<-release
+		}
+		return bot.ReplyText(ev, "pong")
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bot.handleUpdate(context.Background(), c, topicUpdate(mock, 1, 5, 1, "slow"))
+	}()
+	// Another member speaks in another topic while the first handler runs.
+	bot.handleUpdate(context.Background(), c, topicUpdate(mock, 2, 6, 2, "fast"))
+	if thread := lastThread(t, mock); thread != 6 {
+		t.Fatalf("second reply sent to thread %d", thread)
+	}
+	close(release)
+	waitDone(t, done)
+	if thread := lastThread(t, mock); thread != 5 {
+		t.Fatalf("first reply sent to thread %d", thread)
+	}
+}
+
+func TestTopics_LayersArePerTopic(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	var answers []int
+	bot.RegisterCommand("/ask", func(_ context.Context, ev Event) error {
+		layer := bot.NewLayer("Question?")
+		layer.layerDefaultHandler = func(_ context.Context, ev Event) error {
+			answers = append(answers, ev.ThreadID)
+			return nil
+		}
+		return bot.Reply(ev, layer)
+	})
+
+	ask := topicUpdate(mock, 1, 5, 1, "/ask")
+	ask.Message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Length: 4}}
+	bot.handleUpdate(context.Background(), c, ask)
+
+	// A message in another topic neither answers nor consumes the layer.
+	bot.handleUpdate(context.Background(), c, topicUpdate(mock, 2, 6, 1, "elsewhere"))
+	bot.handleUpdate(context.Background(), c, topicUpdate(mock, 3, 5, 1, "answer"))
+
+	if len(answers) != 1 || answers[0] != 5 {
+		t.Fatalf("answers: %v", answers)
+	}
+}
+
+func TestTopics_RegisterTopicAndForumTopicEvents(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	var hits []string
+	support := bot.NewLayer()
+	support.RegisterText(AnyText, func(context.Context, Event) error {
+		hits = append(hits, "support")
+		return nil
+	})
+	bot.RegisterTopic(-200, 5, support)
+	bot.RegisterDefaultHandler(func(context.Context, Event) error {
+		hits = append(hits, "default")
+		return nil
+	})
+	var change *ForumTopicEvent
+	bot.RegisterForumTopic(func(_ context.Context, ev Event) error {
+		change = ev.ForumTopic
+		return nil
+	})
+
+	bot.handleUpdate(context.Background(), c, topicUpdate(mock, 1, 5, 1, "help"))
+	bot.handleUpdate(context.Background(), c, topicUpdate(mock, 2, 6, 1, "help"))
+	bot.RegisterTopic(-200, 5, nil)
+	bot.handleUpdate(context.Background(), c, topicUpdate(mock, 3, 5, 1, "help"))
+	if strings.Join(hits, ",") != "support,default,default" {
+		t.Fatalf("hits: %v", hits)
+	}
+
+	closed := topicUpdate(mock, 4, 5, 1, "")
+	mock.topics[4] = updateTopic{threadID: 5, change: &ForumTopicEvent{Action: ForumTopicClosed}}
+	bot.handleUpdate(context.Background(), c, closed)
+	if change == nil || change.Action != ForumTopicClosed {
+		t.Fatalf("forum topic change: %+v", change)
+	}
+}
+
+func TestTopics_RouteOnTopicLayer(t *testing.T) {
+	bot, mock := newTestBot()
+	c := newChatController(context.Background())
+
+	var got *CallbackPayload
+	support := bot.NewLayer()
+	support.RegisterIButtonRoute("ticket", func(_ context.Context, ev Event) error {
+		got = ev.Payload
+		return nil
+	})
+	bot.RegisterTopic(-200, 5, support)
+
+	tap := topicUpdate(mock, 1, 5, 1, "")
+	tap.CallbackQuery = &tgbotapi.CallbackQuery{
+		ID:      "cb",
+		From:    tap.Message.From,
+		Message: tap.Message,
+		Data:    "ticket:7",
+	}
+	tap.Message = nil
+	bot.handleUpdate(context.Background(), c, tap)
+
+	if got == nil || got.String(0) != "7" {
+		t.Fatalf("route on the topic layer did not run: %+v, sent %v", got, mock.sent)
+	}
+}
+
+func TestRealTelegramAPI_TopicFieldsRoundTrip(t *testing.T) {
+	var (
+		mu           sync.Mutex
+		thread       string
+		actionThread string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getMe"):
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"Stub","username":"stub_bot"}}`))
+		case strings.HasSuffix(r.URL.Path, "/getUpdates"):
+			_, _ = w.Write([]byte(`{"ok":true,"result":[{"update_id":10,"message":{"message_id":1,"date":0,` +
+				`"chat":{"id":-200,"type":"supergroup"},"text":"hi","message_thread_id":5,"is_topic_message":true}}]}`))
+		case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+			mu.Lock()
+			thread = r.FormValue("message_thread_id")
+			mu.Unlock()
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":2,"date":0,"chat":{"id":-200,"type":"supergroup"}}}`))
+		case strings.HasSuffix(r.URL.Path, "/sendChatAction"):
+			mu.Lock()
+			actionThread = r.FormValue("message_thread_id")
+			mu.Unlock()
+			_, _ = w.Write([]byte(`{"ok":true,"result":true}`))
+		}
+	}))
+	defer srv.Close()
+
+	api, err := tgbotapi.NewBotAPIWithAPIEndpoint("token", srv.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter := &realTelegramAPI{bot: api}
+
+	updates, err := adapter.getUpdates(tgbotapi.UpdateConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 1 || updates[0].Message.Text != "hi" {
+		t.Fatalf("updates: %+v", updates)
+	}
+	topic, ok := adapter.takeTopic(10)
+	if !ok || topic.threadID != 5 {
+		t.Fatalf("topic: %+v ok=%v", topic, ok)
+	}
+	if _, ok := adapter.takeTopic(10); ok {
+		t.Fatal("topic fields must be taken once")
+	}
+
+	sent, err := adapter.Send(inThread(tgbotapi.NewMessage(-200, "reply"), 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := adapter.Request(actionInThread(tgbotapi.NewChatAction(-200, tgbotapi.ChatTyping), 5)); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if sent.MessageID != 2 || thread != "5" {
+		t.Fatalf("sent %d to thread %q", sent.MessageID, thread)
+	}
+	if actionThread != "5" {
+		t.Fatalf("chat action sent to thread %q", actionThread)
+	}
+}
@@ -1,6 +1,7 @@
 package bf
 
 import (
+	"context"
 	"errors"
 	"sync/atomic"
 	"time"
@@ -18,29 +19,44 @@ func init() {
 
 func cleanerTick() time.Duration { return time.Duration(cleanerTickInterval.Load()) }
 
-// getAndDeleteLayer atomically returns and deletes the layer for chatID.
+// getAndDeleteLayer atomically returns and deletes the layer in slot key.
 // Combining the two operations under one lock prevents a TOCTOU race
 // where two goroutines could read and serve the same layer.
-func (b *ChatBotImpl) getAndDeleteLayer(chatID int64) (*HandlerLayer, bool) {
+// Sticky layers are returned but stay installed.
+func (b *ChatBotImpl) getAndDeleteLayer(key scopeKey) (*HandlerLayer, bool) {
 	b.layersMutex.Lock()
 	defer b.layersMutex.Unlock()
 
-	layer, ok := b.chatHandlerLayers[chatID]
-	if ok {
-		delete(b.chatHandlerLayers, chatID)
+	layer, ok := b.chatHandlerLayers[key]
+	if ok && !layer.sticky {
+		delete(b.chatHandlerLayers, key)
 	}
 
 	return layer, ok
 }
 
+// dropLayer removes the layer installed for chatID and topic thread (in the
+// layer's scope) only if it is still the given layer, so a caller abandoning
+// its prompt never wipes a newer one.
+func (b *ChatBotImpl) dropLayer(chatID int64, thread int, layer *HandlerLayer) {
+	key := layer.scope.key(chatID, thread)
+
+	b.layersMutex.Lock()
+	defer b.layersMutex.Unlock()
+
+	if b.chatHandlerLayers[key] == layer {
+		delete(b.chatHandlerLayers, key)
+	}
+}
+
 // sweepExpiredLayers removes every chat layer whose TTL has elapsed.
 // Extracted from cleaner so it can be invoked synchronously from tests.
 func (b *ChatBotImpl) sweepExpiredLayers() {
 	b.layersMutex.Lock()
 	defer b.layersMutex.Unlock()
-	for chatID, layer := range b.chatHandlerLayers {
+	for key, layer := range b.chatHandlerLayers {
 		if layer.IsExpired() {
-			delete(b.chatHandlerLayers, chatID)
+			delete(b.chatHandlerLayers, key)
 		}
 	}
 }
@@ -56,6 +72,9 @@ func (b *ChatBotImpl) cleaner() {
 			return
 		case <-ticker.C:
 			b.sweepExpiredLayers()
+			b.sweepExpiredNavigation()
+			b.sweepExpiredMessageLayers()
+			b.sweepExpiredReplyKeyboards()
 		}
 	}
 }
@@ -98,6 +117,28 @@ func (b *ChatBotImpl) buildInlineKeyboard(
 	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: iButtons}
 }
 
+// splitRows cuts items into consecutive rows of the given sizes. Items left
+// over once sizes is exhausted get a row each; non-positive sizes are skipped.
+func splitRows[T any](items []T, sizes []int) [][]T {
+	rows := make([][]T, 0, len(sizes))
+	for _, size := range sizes {
+		if len(items) == 0 {
+			break
+		}
+		if size <= 0 {
+			continue
+		}
+		size = min(size, len(items))
+		rows = append(rows, items[:size])
+		items = items[size:]
+	}
+	for _, item := range items {
+		rows = append(rows, []T{item})
+	}
+
+	return rows
+}
+
 func (b *ChatBotImpl) applyMiddlewares(handlerFunc HandlerFunc) HandlerFunc {
 	b.middlewaresMutex.RLock()
 	mws := b.middlewares
@@ -111,7 +152,8 @@ func (b *ChatBotImpl) applyMiddlewares(handlerFunc HandlerFunc) HandlerFunc {
 }
 
 // availableHandlerFromLayers picks the handler that should run for event,
-// preferring the chat-specific layer and falling back to the default layer.
+// preferring the chat-specific layer, then the layer registered for the
+// event's forum topic (RegisterTopic), and falling back to the default layer.
 // The default layer is read under defaultLayerMutex so concurrent Register*
 // calls do not race the dispatcher.
 func (b *ChatBotImpl) availableHandlerFromLayers(event Event, chatLayer, defaultLayer *HandlerLayer) HandlerFunc {
@@ -120,6 +162,11 @@ func (b *ChatBotImpl) availableHandlerFromLayers(event Event, chatLayer, default
 			return h
 		}
 	}
+	if topic := b.topicLayer(event); topic != nil {
+		if h := topic.Handler(event); h != nil {
+			return h
+		}
+	}
 	b.defaultLayerMutex.RLock()
 	defer b.defaultLayerMutex.RUnlock()
 	if defaultLayer == nil {
@@ -128,8 +175,19 @@ func (b *ChatBotImpl) availableHandlerFromLayers(event Event, chatLayer, default
 	return defaultLayer.Handler(event)
 }
 
-func (b *ChatBotImpl) setLayer(layer *HandlerLayer, chatID int64) {
+// keepLayerHandler returns a handler for inert buttons (labels, page
+// indicators, blank cells): tapping one re-installs layer so the widget keeps
+// working instead of falling through to the default layer.
+func (b *ChatBotImpl) keepLayerHandler(layer *HandlerLayer) HandlerFunc {
+	return func(_ context.Context, event Event) error {
+		b.setLayer(layer, event.ChatID, event.ThreadID)
+		return nil
+	}
+}
+
+// setLayer installs layer for chatID and topic thread in the layer's scope.
+func (b *ChatBotImpl) setLayer(layer *HandlerLayer, chatID int64, thread int) {
 	b.layersMutex.Lock()
-	b.chatHandlerLayers[chatID] = layer
+	b.chatHandlerLayers[layer.scope.key(chatID, thread)] = layer
 	b.layersMutex.Unlock()
 }
@@ -119,7 +119,7 @@ func TestAvailableHandlerFromLayers_FallsBackToDefault(t *testing.T) {
 func TestGetAndDeleteLayer_Concurrent(t *testing.T) {
 	bot, _ := newTestBot()
 	for i := int64(0); i < 100; i++ {
-		bot.setLayer(bot.NewLayer(), i)
+		bot.setLayer(bot.NewLayer(), i, 0)
 	}
 
 	var wg sync.WaitGroup
@@ -127,7 +127,7 @@ func TestGetAndDeleteLayer_Concurrent(t *testing.T) {
 	for i := int64(0); i < 100; i++ {
 		go func(id int64) {
 			defer wg.Done()
-			_, _ = bot.getAndDeleteLayer(id)
+			_, _ = bot.getAndDeleteLayer(chatKey(id))
 		}(i)
 	}
 	wg.Wait()
@@ -140,12 +140,12 @@ func TestGetAndDeleteLayer_Concurrent(t *testing.T) {
 func TestSetLayerAndRetrieve(t *testing.T) {
 	bot, _ := newTestBot()
 	l := bot.NewLayer()
-	bot.setLayer(l, 99)
-	got, ok := bot.getAndDeleteLayer(99)
+	bot.setLayer(l, 99, 0)
+	got, ok := bot.getAndDeleteLayer(chatKey(99))
 	if !ok || got != l {
 		t.Fatal("layer not retrievable after setLayer")
 	}
-	if _, ok := bot.getAndDeleteLayer(99); ok {
+	if _, ok := bot.getAndDeleteLayer(chatKey(99)); ok {
 		t.Fatal("layer not deleted after retrieval")
 	}
 }
@@ -154,25 +154,25 @@ func TestCleaner_RemovesExpired(t *testing.T) {
 	bot, _ := newTestBot()
 	expired := bot.NewLayer()
 	expired.ttl = time.Now().Add(-time.Hour)
-	bot.setLayer(expired, 1)
+	bot.setLayer(expired, 1, 0)
 
 	fresh := bot.NewLayer()
 	fresh.ttl = time.Now().Add(time.Hour)
-	bot.setLayer(fresh, 2)
+	bot.setLayer(fresh, 2, 0)
 
 	// Run one cleaner iteration manually (avoid 10-min ticker).
 	bot.layersMutex.Lock()
-	for chatID, layer := range bot.chatHandlerLayers {
+	for key, layer := range bot.chatHandlerLayers {
 		if layer.IsExpired() {
-			delete(bot.chatHandlerLayers, chatID)
+			delete(bot.chatHandlerLayers, key)
 		}
 	}
 	bot.layersMutex.Unlock()
 
-	if _, ok := bot.chatHandlerLayers[1]; ok {
+	if _, ok := bot.chatHandlerLayers[chatKey(1)]; ok {
 		t.Fatal("expired layer not removed")
 	}
-	if _, ok := bot.chatHandlerLayers[2]; !ok {
+	if _, ok := bot.chatHandlerLayers[chatKey(2)]; !ok {
 		t.Fatal("fresh layer removed by mistake")
 	}
 }